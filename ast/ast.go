@@ -11,6 +11,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos is the position of the token of the node.
+	Pos() token.Position
 }
 
 type Statement interface {
@@ -29,9 +31,19 @@ type Program struct {
 
 // String implements Node.
 func (p *Program) String() string {
+	return statementsString(p.Statements)
+}
+
+// statementsString concatenates stmts, ending expression statements
+// followed by another statement with a semicolon, for the String of
+// programs and blocks to parse back to the same statements.
+func statementsString(stmts []Statement) string {
 	var out bytes.Buffer
-	for _, s := range p.Statements {
+	for i, s := range stmts {
 		out.WriteString(s.String())
+		if _, ok := s.(*ExpressionStatement); ok && i < len(stmts)-1 {
+			out.WriteString(";")
+		}
 	}
 	return out.String()
 }
@@ -45,9 +57,18 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
+	// Type is the annotated type of the binding, if any.
+	Type  TypeExpr
 	Value Expression
 }
 
@@ -55,11 +76,16 @@ var _ Statement = &LetStatement{}
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral())
 	out.WriteString(" ")
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": ")
+		out.WriteString(ls.Type.String())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -71,29 +97,40 @@ func (ls *LetStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	// Rbrace is the position of the closing brace, or of the end of input
+	// when it is missing.
+	Rbrace token.Position
 }
 
 var _ Statement = &BlockStatement{}
 
 func (n *BlockStatement) statementNode()       {}
 func (n *BlockStatement) TokenLiteral() string { return n.Token.Literal }
+func (n *BlockStatement) Pos() token.Position  { return n.Token.Pos }
 func (n *BlockStatement) String() string {
-	var out bytes.Buffer
-	for _, stmt := range n.Statements {
-		out.WriteString(stmt.String())
+	if len(n.Statements) == 0 {
+		return "{ }"
 	}
-	return out.String()
+	return "{ " + statementsString(n.Statements) + " }"
 }
 
 type Identifier struct {
 	Token token.Token
 	Value string
+	// Local is set by the resolver when the identifier names a parameter or
+	// let of a function or catch clause. The variable is then in slot Slot of
+	// the function or catch clause Depth levels out, see Locals. Other
+	// identifiers are looked up by name.
+	Local bool
+	Depth int
+	Slot  int
 }
 
 var _ Expression = &Identifier{}
 
 func (n *Identifier) expressionNode()      {}
 func (n *Identifier) TokenLiteral() string { return n.Token.Literal }
+func (n *Identifier) Pos() token.Position  { return n.Token.Pos }
 func (n *Identifier) String() string {
 	return n.Value
 }
@@ -107,6 +144,7 @@ var _ Expression = &IntegerLiteral{}
 
 func (n *IntegerLiteral) expressionNode()      {}
 func (n *IntegerLiteral) TokenLiteral() string { return n.Token.Literal }
+func (n *IntegerLiteral) Pos() token.Position  { return n.Token.Pos }
 func (n *IntegerLiteral) String() string       { return n.Token.Literal }
 
 type Boolean struct {
@@ -118,6 +156,7 @@ var _ Expression = &Boolean{}
 
 func (n *Boolean) expressionNode()      {}
 func (n *Boolean) TokenLiteral() string { return n.Token.Literal }
+func (n *Boolean) Pos() token.Position  { return n.Token.Pos }
 func (n *Boolean) String() string       { return n.Token.Literal }
 
 type StringLiteral struct {
@@ -129,7 +168,19 @@ var _ Expression = &StringLiteral{}
 
 func (n *StringLiteral) expressionNode()      {}
 func (n *StringLiteral) TokenLiteral() string { return n.Token.Literal }
-func (n *StringLiteral) String() string       { return n.Token.Literal }
+func (n *StringLiteral) Pos() token.Position  { return n.Token.Pos }
+func (n *StringLiteral) String() string       { return `"` + n.Token.Literal + `"` }
+
+type Null struct {
+	Token token.Token
+}
+
+var _ Expression = &Null{}
+
+func (n *Null) expressionNode()      {}
+func (n *Null) TokenLiteral() string { return n.Token.Literal }
+func (n *Null) Pos() token.Position  { return n.Token.Pos }
+func (n *Null) String() string       { return n.Token.Literal }
 
 type IfExpression struct {
 	Token       token.Token
@@ -142,14 +193,15 @@ var _ Expression = &IfExpression{}
 
 func (n *IfExpression) expressionNode()      {}
 func (n *IfExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *IfExpression) Pos() token.Position  { return n.Token.Pos }
 func (n *IfExpression) String() string {
 	var out bytes.Buffer
-	out.WriteString("if")
+	out.WriteString("if (")
 	out.WriteString(n.Condition.String())
-	out.WriteString(" ")
+	out.WriteString(") ")
 	out.WriteString(n.Consequence.String())
 	if n.Alternative != nil {
-		out.WriteString("else ")
+		out.WriteString(" else ")
 		out.WriteString(n.Alternative.String())
 	}
 	return out.String()
@@ -158,23 +210,47 @@ func (n *IfExpression) String() string {
 type FunctionLiteral struct {
 	Token  token.Token
 	Params []*Identifier
-	Body   *BlockStatement
+	// ParamTypes are the annotated types of Params, nil for those without
+	// one. It is nil when no parameter is annotated.
+	ParamTypes []TypeExpr
+	// ReturnType is the annotated return type, if any.
+	ReturnType TypeExpr
+	Body       *BlockStatement
+	// Locals are the names of the parameters and lets of the function, by
+	// slot, as set by the resolver.
+	Locals []string
+	// Name is the name of the let binding the function is defined in, if any.
+	Name string
 }
 
 var _ Expression = &FunctionLiteral{}
 
 func (n *FunctionLiteral) expressionNode()      {}
 func (n *FunctionLiteral) TokenLiteral() string { return n.Token.Literal }
+func (n *FunctionLiteral) Pos() token.Position  { return n.Token.Pos }
 func (n *FunctionLiteral) String() string {
+	return n.Signature() + " " + n.Body.String()
+}
+
+// Signature is the function without its body, like `fn(a: int, b) -> int`.
+func (n *FunctionLiteral) Signature() string {
 	var out bytes.Buffer
 	params := []string{}
-	for _, p := range n.Params {
-		params = append(params, p.String())
+	for i, p := range n.Params {
+		param := p.String()
+		if i < len(n.ParamTypes) && n.ParamTypes[i] != nil {
+			param += ": " + n.ParamTypes[i].String()
+		}
+		params = append(params, param)
 	}
 	out.WriteString(n.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(")")
+	if n.ReturnType != nil {
+		out.WriteString(" -> ")
+		out.WriteString(n.ReturnType.String())
+	}
 	return out.String()
 }
 
@@ -187,6 +263,7 @@ var _ Expression = &ArrayLiteral{}
 
 func (n *ArrayLiteral) expressionNode()      {}
 func (n *ArrayLiteral) TokenLiteral() string { return n.Token.Literal }
+func (n *ArrayLiteral) Pos() token.Position  { return n.Token.Pos }
 func (n *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	last := len(n.Elems) - 1
@@ -205,17 +282,20 @@ func (n *ArrayLiteral) String() string {
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	// Keys lists the keys of Pairs in source order.
+	Keys []Expression
 }
 
 var _ Expression = &HashLiteral{}
 
 func (n *HashLiteral) expressionNode()      {}
 func (n *HashLiteral) TokenLiteral() string { return n.Token.Literal }
+func (n *HashLiteral) Pos() token.Position  { return n.Token.Pos }
 func (n *HashLiteral) String() string {
 	var out bytes.Buffer
 	pairs := []string{}
-	for k, v := range n.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s:%s", k.String(), v.String()))
+	for _, k := range n.Keys {
+		pairs = append(pairs, fmt.Sprintf("%s:%s", k.String(), n.Pairs[k].String()))
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -227,16 +307,22 @@ type IndexExpression struct {
 	Token token.Token
 	Left  Expression
 	Index Expression
+	// Optional is set for `left?[index]`, which is null when left is null.
+	Optional bool
 }
 
 var _ Expression = &IndexExpression{}
 
 func (n *IndexExpression) expressionNode()      {}
 func (n *IndexExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *IndexExpression) Pos() token.Position  { return n.Token.Pos }
 func (n *IndexExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
 	out.WriteString(n.Left.String())
+	if n.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(n.Index.String())
 	out.WriteString("])")
@@ -248,24 +334,59 @@ type SliceExpression struct {
 	Left       Expression
 	IndexLeft  Expression
 	IndexRight Expression
+	// Optional is set for `left?[i:j]`, which is null when left is null.
+	Optional bool
 }
 
 var _ Expression = &SliceExpression{}
 
 func (n *SliceExpression) expressionNode()      {}
 func (n *SliceExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *SliceExpression) Pos() token.Position  { return n.Token.Pos }
 func (n *SliceExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
 	out.WriteString(n.Left.String())
+	if n.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
-	out.WriteString(n.IndexLeft.String())
+	if n.IndexLeft != nil {
+		out.WriteString(n.IndexLeft.String())
+	}
 	out.WriteString(":")
-	out.WriteString(n.IndexRight.String())
+	if n.IndexRight != nil {
+		out.WriteString(n.IndexRight.String())
+	}
 	out.WriteString("])")
 	return out.String()
 }
 
+// MemberExpression is `object.property` or `object?.property`. On its own it
+// reads the string key property of a hash; as the function of a
+// CallExpression it is a method call.
+type MemberExpression struct {
+	Token    token.Token
+	Object   Expression
+	Property *Identifier
+	Optional bool
+}
+
+var _ Expression = &MemberExpression{}
+
+func (n *MemberExpression) expressionNode()      {}
+func (n *MemberExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *MemberExpression) Pos() token.Position  { return n.Token.Pos }
+func (n *MemberExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(n.Object.String())
+	out.WriteString(n.Token.Literal)
+	out.WriteString(n.Property.String())
+	out.WriteString(")")
+	return out.String()
+}
+
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
@@ -276,6 +397,7 @@ var _ Expression = &CallExpression{}
 
 func (n *CallExpression) expressionNode()      {}
 func (n *CallExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *CallExpression) Pos() token.Position  { return n.Token.Pos }
 func (n *CallExpression) String() string {
 	var out bytes.Buffer
 	args := []string{}
@@ -299,6 +421,7 @@ var _ Expression = &PrefixExpression{}
 
 func (n *PrefixExpression) expressionNode()      {}
 func (n *PrefixExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *PrefixExpression) Pos() token.Position  { return n.Token.Pos }
 func (n *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -319,6 +442,7 @@ var _ Expression = &InfixExpression{}
 
 func (n *InfixExpression) expressionNode()      {}
 func (n *InfixExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *InfixExpression) Pos() token.Position  { return n.Token.Pos }
 func (n *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -340,6 +464,7 @@ var _ Statement = &ReturnStatement{}
 
 func (n *ReturnStatement) statementNode()       {}
 func (n *ReturnStatement) TokenLiteral() string { return n.Token.Literal }
+func (n *ReturnStatement) Pos() token.Position  { return n.Token.Pos }
 func (n *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(n.TokenLiteral())
@@ -351,6 +476,106 @@ func (n *ReturnStatement) String() string {
 	return out.String()
 }
 
+// ImportStatement is `import "path" as name`, binding name to the module
+// loaded from path.
+type ImportStatement struct {
+	Token token.Token
+	Path  *StringLiteral
+	Name  *Identifier
+}
+
+var _ Statement = &ImportStatement{}
+
+func (n *ImportStatement) statementNode()       {}
+func (n *ImportStatement) TokenLiteral() string { return n.Token.Literal }
+func (n *ImportStatement) Pos() token.Position  { return n.Token.Pos }
+func (n *ImportStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(n.TokenLiteral())
+	out.WriteString(" ")
+	out.WriteString(n.Path.String())
+	out.WriteString(" as ")
+	out.WriteString(n.Name.String())
+	out.WriteString(";")
+	return out.String()
+}
+
+// ExportStatement is `export let name = value`, making name visible to
+// modules importing the current one.
+type ExportStatement struct {
+	Token token.Token
+	Let   *LetStatement
+}
+
+var _ Statement = &ExportStatement{}
+
+func (n *ExportStatement) statementNode()       {}
+func (n *ExportStatement) TokenLiteral() string { return n.Token.Literal }
+func (n *ExportStatement) Pos() token.Position  { return n.Token.Pos }
+func (n *ExportStatement) String() string {
+	return n.TokenLiteral() + " " + n.Let.String()
+}
+
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+var _ Statement = &ThrowStatement{}
+
+func (n *ThrowStatement) statementNode()       {}
+func (n *ThrowStatement) TokenLiteral() string { return n.Token.Literal }
+func (n *ThrowStatement) Pos() token.Position  { return n.Token.Pos }
+func (n *ThrowStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(n.TokenLiteral())
+	out.WriteString(" ")
+	if n.Value != nil {
+		out.WriteString(n.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// TryExpression is `try { } catch (param) { } finally { }`. Either Catch or
+// Finally may be nil, and the catch clause may omit Param.
+type TryExpression struct {
+	Token   token.Token
+	Block   *BlockStatement
+	Param   *Identifier
+	Catch   *BlockStatement
+	Finally *BlockStatement
+	// CatchLocals are the names of Param and the lets of Catch, by slot, as
+	// set by the resolver.
+	CatchLocals []string
+}
+
+var _ Expression = &TryExpression{}
+
+func (n *TryExpression) expressionNode()      {}
+func (n *TryExpression) TokenLiteral() string { return n.Token.Literal }
+func (n *TryExpression) Pos() token.Position  { return n.Token.Pos }
+func (n *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(n.Block.String())
+	if n.Catch != nil {
+		out.WriteString(" catch")
+		if n.Param != nil {
+			out.WriteString(" (")
+			out.WriteString(n.Param.String())
+			out.WriteString(")")
+		}
+		out.WriteString(" ")
+		out.WriteString(n.Catch.String())
+	}
+	if n.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(n.Finally.String())
+	}
+	return out.String()
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -360,6 +585,7 @@ var _ Statement = &ExpressionStatement{}
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
 func (es *ExpressionStatement) String() string {
 	// var out bytes.Buffer
 	if es.Expression != nil {
@@ -368,3 +594,57 @@ func (es *ExpressionStatement) String() string {
 	return ""
 	// return out.String()
 }
+
+// TypeExpr is a type annotation, see the types package.
+type TypeExpr interface {
+	Node
+	typeNode()
+}
+
+// TypeName is a named type, with the type arguments of generic types, e.g.
+// `int` or `hash<string, int>`.
+type TypeName struct {
+	Token token.Token
+	Name  string
+	Args  []TypeExpr
+}
+
+var _ TypeExpr = &TypeName{}
+
+func (n *TypeName) typeNode()            {}
+func (n *TypeName) TokenLiteral() string { return n.Token.Literal }
+func (n *TypeName) Pos() token.Position  { return n.Token.Pos }
+func (n *TypeName) String() string {
+	if len(n.Args) == 0 {
+		return n.Name
+	}
+	args := make([]string, len(n.Args))
+	for i, a := range n.Args {
+		args[i] = a.String()
+	}
+	return n.Name + "<" + strings.Join(args, ", ") + ">"
+}
+
+// FunctionType is the type of a function, e.g. `fn(int, int) -> bool`.
+type FunctionType struct {
+	Token  token.Token
+	Params []TypeExpr
+	Return TypeExpr
+}
+
+var _ TypeExpr = &FunctionType{}
+
+func (n *FunctionType) typeNode()            {}
+func (n *FunctionType) TokenLiteral() string { return n.Token.Literal }
+func (n *FunctionType) Pos() token.Position  { return n.Token.Pos }
+func (n *FunctionType) String() string {
+	params := make([]string, len(n.Params))
+	for i, p := range n.Params {
+		params[i] = p.String()
+	}
+	ret := ""
+	if n.Return != nil {
+		ret = n.Return.String()
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + ret
+}
@@ -0,0 +1,115 @@
+package ast
+
+// Inspect traverses the tree rooted at node depth-first in source order,
+// calling f for each node. The children of a node are skipped when f
+// returns false.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	for _, c := range Children(node) {
+		Inspect(c, f)
+	}
+}
+
+// Children returns the direct children of node in source order, leaving
+// out those missing after parse errors.
+func Children(node Node) []Node {
+	var nodes []Node
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			nodes = add(nodes, s)
+		}
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			nodes = add(nodes, s)
+		}
+	case *LetStatement:
+		nodes = add(nodes, n.Name)
+		nodes = add(nodes, n.Type)
+		nodes = add(nodes, n.Value)
+	case *ReturnStatement:
+		nodes = add(nodes, n.ReturnValue)
+	case *ExpressionStatement:
+		nodes = add(nodes, n.Expression)
+	case *ThrowStatement:
+		nodes = add(nodes, n.Value)
+	case *ImportStatement:
+		nodes = add(nodes, n.Path)
+		nodes = add(nodes, n.Name)
+	case *ExportStatement:
+		nodes = add(nodes, n.Let)
+	case *PrefixExpression:
+		nodes = add(nodes, n.Right)
+	case *InfixExpression:
+		nodes = add(nodes, n.Left)
+		nodes = add(nodes, n.Right)
+	case *IfExpression:
+		nodes = add(nodes, n.Condition)
+		nodes = add(nodes, n.Consequence)
+		nodes = add(nodes, n.Alternative)
+	case *FunctionLiteral:
+		for i, p := range n.Params {
+			nodes = add(nodes, p)
+			if i < len(n.ParamTypes) {
+				nodes = add(nodes, n.ParamTypes[i])
+			}
+		}
+		nodes = add(nodes, n.ReturnType)
+		nodes = add(nodes, n.Body)
+	case *CallExpression:
+		nodes = add(nodes, n.Function)
+		for _, a := range n.Arguments {
+			nodes = add(nodes, a)
+		}
+	case *ArrayLiteral:
+		for _, e := range n.Elems {
+			nodes = add(nodes, e)
+		}
+	case *HashLiteral:
+		for _, k := range n.Keys {
+			nodes = add(nodes, k)
+			nodes = add(nodes, n.Pairs[k])
+		}
+	case *IndexExpression:
+		nodes = add(nodes, n.Left)
+		nodes = add(nodes, n.Index)
+	case *SliceExpression:
+		nodes = add(nodes, n.Left)
+		nodes = add(nodes, n.IndexLeft)
+		nodes = add(nodes, n.IndexRight)
+	case *MemberExpression:
+		nodes = add(nodes, n.Object)
+		nodes = add(nodes, n.Property)
+	case *TryExpression:
+		nodes = add(nodes, n.Block)
+		nodes = add(nodes, n.Param)
+		nodes = add(nodes, n.Catch)
+		nodes = add(nodes, n.Finally)
+	case *TypeName:
+		for _, a := range n.Args {
+			nodes = add(nodes, a)
+		}
+	case *FunctionType:
+		for _, p := range n.Params {
+			nodes = add(nodes, p)
+		}
+		nodes = add(nodes, n.Return)
+	}
+	return nodes
+}
+
+// add appends n to nodes, unless it is nil. It is generic so that nil
+// pointers are left out as well as nil interfaces.
+func add[T interface {
+	Node
+	comparable
+}](nodes []Node, n T) []Node {
+	var zero T
+	if n == zero {
+		return nodes
+	}
+	return append(nodes, n)
+}
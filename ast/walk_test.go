@@ -0,0 +1,37 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	program := parser.FromInput(`let f = fn(a) { if (a) { a.b } else { [a, {1: 2}] } }; f(1)[0:2]`).ParseProgram()
+
+	var idents []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Identifier); ok {
+			idents = append(idents, id.Value)
+		}
+		return true
+	})
+	require.Equal(t, []string{"f", "a", "a", "a", "b", "a", "f"}, idents)
+
+	var count int
+	ast.Inspect(program, func(n ast.Node) bool {
+		count++
+		_, ok := n.(*ast.LetStatement)
+		return !ok
+	})
+	require.Equal(t, 9, count, "children of the let are skipped")
+}
+
+func TestInspectErrors(t *testing.T) {
+	program := parser.FromInput(`let x = ; let = 1; fn(`).ParseProgram()
+	require.NotPanics(t, func() {
+		ast.Inspect(program, func(ast.Node) bool { return true })
+	})
+}
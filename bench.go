@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/optimizer"
+	"github.com/EmilLaursen/wiig/parser"
+)
+
+// benchResult is the measurements of the runs of a script.
+type benchResult struct {
+	times  []time.Duration
+	bytes  uint64
+	allocs uint64
+}
+
+// benchFile implements `monkey bench`, returning the exit status. Each run
+// parses and evaluates the script, with its output discarded. The result is
+// printed in the format of `go test -bench`, so that benchstat can compare
+// runs, e.g. of different engines with `benchstat -col /engine`.
+func benchFile(argv []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	allow := flags.String("allow", "", "comma separated capabilities granted to the script")
+	runs := flags.Int("n", 10, "number of runs measured")
+	warmup := flags.Int("warmup", 1, "number of runs before those measured")
+	optimize := flags.Bool("O", false, "optimize the script and its imports before evaluating them")
+	if err := flags.Parse(argv); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 || *runs < 1 {
+		fmt.Fprintf(stderr, usage, progName())
+		return 2
+	}
+	caps, err := eval.ParseCapabilities(*allow)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	name, src, err := readScript(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "read file: %s\n", err)
+		return 1
+	}
+	args := stringArray(flags.Args()[1:])
+
+	engine := "eval"
+	if *optimize {
+		engine = "eval-O"
+	}
+	runOnce := func() error {
+		interp, err := newInterpreter(caps, strings.NewReader(""), io.Discard, io.Discard)
+		if err != nil {
+			return err
+		}
+		p := parser.FromInput(src)
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return fmt.Errorf("%s: %s", name, strings.Join(p.Errors(), "; "))
+		}
+		if *optimize {
+			program = optimizer.Optimize(program)
+			interp.Optimize = optimizer.Optimize
+		}
+		env := object.NewEnv()
+		env.Set("args", args)
+		switch val := interp.EvalFile(name, program, env).(type) {
+		case *object.Error:
+			printError(stderr, name, val)
+			return fmt.Errorf("%s failed", name)
+		case *object.Exit:
+			if val.Code != 0 {
+				return fmt.Errorf("%s exited with status %d", name, val.Code)
+			}
+		}
+		return nil
+	}
+
+	for i := 0; i < *warmup; i++ {
+		if err := runOnce(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	var res benchResult
+	var before, after runtime.MemStats
+	for i := 0; i < *runs; i++ {
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		err := runOnce()
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		res.times = append(res.times, elapsed)
+		res.bytes += after.TotalAlloc - before.TotalAlloc
+		res.allocs += after.Mallocs - before.Mallocs
+	}
+
+	fmt.Fprintf(stdout, "BenchmarkRun/file=%s/engine=%s\t%s\n", path.Base(name), engine, res.format())
+	return 0
+}
+
+// format formats res as the measurements of a line of benchmark output.
+func (res *benchResult) format() string {
+	n := len(res.times)
+	sorted := append([]time.Duration(nil), res.times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, t := range sorted {
+		total += t
+	}
+	return fmt.Sprintf("%d\t%d ns/op\t%d p50-ns/op\t%d p90-ns/op\t%d p99-ns/op\t%d B/op\t%d allocs/op",
+		n, total.Nanoseconds()/int64(n),
+		percentile(sorted, 50).Nanoseconds(), percentile(sorted, 90).Nanoseconds(), percentile(sorted, 99).Nanoseconds(),
+		res.bytes/uint64(n), res.allocs/uint64(n))
+}
+
+// percentile returns the p-th percentile of sorted, by the nearest rank.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
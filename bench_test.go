@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBench(t *testing.T) {
+	var stdout, stderr strings.Builder
+	status := benchFile([]string{"-n", "3", "-O", "examples/map_reduce.mnk"}, &stdout, &stderr)
+	require.Equal(t, 0, status, stderr.String())
+	require.Regexp(t, regexp.MustCompile(`^BenchmarkRun/file=map_reduce.mnk/engine=eval-O\t3\t\d+ ns/op\t\d+ p50-ns/op\t\d+ p90-ns/op\t\d+ p99-ns/op\t\d+ B/op\t\d+ allocs/op\n$`), stdout.String())
+
+	// Scripts are read relative to the working directory.
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	failing, err := os.CreateTemp(wd, "bench*.mnk")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.Remove(failing.Name()) })
+	_, err = failing.WriteString("1 + true")
+	require.NoError(t, err)
+	require.NoError(t, failing.Close())
+
+	name := filepath.Base(failing.Name())
+	tests := []struct {
+		argv   []string
+		status int
+		stderr string
+	}{
+		{[]string{name}, 1, name + ":1:3: type mismatch: INTEGER + BOOLEAN\n" + name + " failed\n"},
+		{[]string{"missing.mnk"}, 1, "read file: open missing.mnk: no such file or directory\n"},
+		{[]string{"-n", "0", "examples/map_reduce.mnk"}, 2, ""},
+	}
+	for _, tt := range tests {
+		stdout.Reset()
+		stderr.Reset()
+		status := benchFile(tt.argv, &stdout, &stderr)
+		require.Equal(t, tt.status, status, tt.argv)
+		require.Empty(t, stdout.String(), tt.argv)
+		if tt.stderr != "" {
+			require.Equal(t, tt.stderr, stderr.String(), tt.argv)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var times []time.Duration
+	for i := 1; i <= 10; i++ {
+		times = append(times, time.Duration(i))
+	}
+	require.Equal(t, time.Duration(5), percentile(times, 50))
+	require.Equal(t, time.Duration(9), percentile(times, 90))
+	require.Equal(t, time.Duration(10), percentile(times, 99))
+	require.Equal(t, time.Duration(1), percentile(times[:1], 50))
+}
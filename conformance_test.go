@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/lexer"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/optimizer"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/token"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of TestConformance")
+
+// conformanceDir holds the programs of TestConformance. For NAME.mnk,
+// NAME.out is its expected output and NAME.err its expected error; either
+// is absent when empty.
+const conformanceDir = "testdata/conformance"
+
+// conformanceModes are the ways every program is run, which must agree.
+var conformanceModes = []struct {
+	name     string
+	optimize bool
+}{
+	{"eval", false},
+	{"eval-O", true},
+}
+
+// TestConformance lexes, parses and evaluates the programs of
+// conformanceDir, comparing their output and error with the golden files.
+// Run `go test -run TestConformance . -update` to rewrite them.
+func TestConformance(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(conformanceDir, "*.mnk"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".mnk")
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		t.Run(name, func(t *testing.T) {
+			for i, mode := range conformanceModes {
+				t.Run(mode.name, func(t *testing.T) {
+					out, errText := runConformance(t, name+".mnk", string(data), mode.optimize)
+					// Goldens are written from the first mode, and the
+					// others compared with them.
+					if *update && i == 0 {
+						writeGolden(t, name+".out", out)
+						writeGolden(t, name+".err", errText)
+					}
+					require.Equal(t, readGolden(t, name+".out"), out, "output")
+					require.Equal(t, readGolden(t, name+".err"), errText, "error")
+				})
+			}
+		})
+	}
+}
+
+// runConformance runs the program file with source src, returning its
+// output followed by its value, and its error, if any: the parse errors,
+// the runtime error with its stack trace, or the exit status if not 0.
+func runConformance(t *testing.T, file, src string, optimize bool) (string, string) {
+	t.Helper()
+	l := lexer.New(src)
+	for i := 0; l.NextToken().Type != token.EOF; i++ {
+		if i > len(src) {
+			t.Fatal("lexer did not reach EOF")
+		}
+	}
+
+	p := parser.FromInput(src)
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		var b strings.Builder
+		for _, err := range errs {
+			fmt.Fprintf(&b, "%s:%s\n", file, err)
+		}
+		return "", b.String()
+	}
+
+	var out bytes.Buffer
+	interp := eval.New()
+	interp.FS = os.DirFS(conformanceDir)
+	interp.Allow(eval.CapIO, eval.CapProcess)
+	interp.Stdout, interp.Stderr = &out, &out
+	if optimize {
+		program = optimizer.Optimize(program)
+		interp.Optimize = optimizer.Optimize
+	}
+	env := object.NewEnv()
+	env.Set("args", &object.Array{})
+
+	switch val := interp.EvalFile(file, program, env).(type) {
+	case *object.Error:
+		var b strings.Builder
+		printError(&b, file, val)
+		return out.String(), b.String()
+	case *object.Exit:
+		if val.Code != 0 {
+			return out.String(), fmt.Sprintf("exit status %d\n", val.Code)
+		}
+	case nil:
+	default:
+		// The value of the program, as printed by monkey run.
+		fmt.Fprintln(&out, val.Inspect())
+	}
+	return out.String(), ""
+}
+
+func readGolden(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(conformanceDir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ""
+	}
+	require.NoError(t, err)
+	return string(data)
+}
+
+// writeGolden writes content to the golden file name, or removes it if
+// content is empty.
+func writeGolden(t *testing.T, name, content string) {
+	t.Helper()
+	path := filepath.Join(conformanceDir, name)
+	if content == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			t.Fatal(err)
+		}
+		return
+	}
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
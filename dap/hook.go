@@ -0,0 +1,112 @@
+package dap
+
+import (
+	"path/filepath"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/object"
+)
+
+// hook tracks the stack of the program and stops it, implementing
+// eval.Hook.
+type hook struct {
+	s *Server
+}
+
+func (h hook) Before(node ast.Node, env *object.Environment) object.Object {
+	s := h.s
+	s.mu.Lock()
+	if s.abort {
+		s.mu.Unlock()
+		return &object.Exit{Code: 1}
+	}
+	top := s.frames[len(s.frames)-1]
+	top.env = env
+	if !isStatement(node) {
+		s.mu.Unlock()
+		return nil
+	}
+
+	pos, file := node.Pos(), s.interp.File()
+	newLine := pos.Line != top.pos.Line || file != top.file
+	top.pos, top.file = pos, file
+	reason := s.stopReason(newLine, env)
+	if reason == "" {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopped = true
+	s.entry, s.pause = false, false
+	s.mu.Unlock()
+
+	s.event("stopped", StoppedEventBody{Reason: reason, ThreadID: threadID, AllThreadsStopped: true})
+	<-s.resume
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.abort {
+		return &object.Exit{Code: 1}
+	}
+	return nil
+}
+
+// stopReason returns why the program stops before the statement, or "" if
+// it does not. Stepping and breakpoints stop at the first statement of a
+// line.
+func (s *Server) stopReason(newLine bool, env *object.Environment) string {
+	switch {
+	case s.entry:
+		return "entry"
+	case s.pause:
+		return "pause"
+	case !newLine:
+		return ""
+	case s.hitBreakpoint(env):
+		return "breakpoint"
+	case s.mode == modeStepIn,
+		s.mode == modeStepOver && len(s.frames) <= s.depth,
+		s.mode == modeStepOut && len(s.frames) < s.depth:
+		return "step"
+	}
+	return ""
+}
+
+// hitBreakpoint reports whether a breakpoint is set on the line of the top
+// frame, with its condition, if any, true in env. Conditions are evaluated
+// without capabilities, and an erroneous one is false.
+func (s *Server) hitBreakpoint(env *object.Environment) bool {
+	top := s.frames[len(s.frames)-1]
+	bp, ok := s.breakpoints[filepath.Clean(s.source(top.file))][top.pos.Line]
+	if !ok {
+		return false
+	}
+	if bp.condition == nil {
+		return true
+	}
+	val := eval.New().Eval(bp.condition, env)
+	return val != nil && val != eval.FALSE && val != eval.NULL && val.Type() != object.ERROR_OBJ
+}
+
+func (h hook) Enter(fn object.Object, args []object.Object) {
+	f, ok := fn.(*object.Function)
+	if !ok {
+		return
+	}
+	name := f.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	h.s.mu.Lock()
+	h.s.frames = append(h.s.frames, &frame{name: name})
+	h.s.mu.Unlock()
+}
+
+func (h hook) Leave(fn object.Object, result object.Object) {
+	if _, ok := fn.(*object.Function); !ok {
+		return
+	}
+	h.s.mu.Lock()
+	h.s.frames = h.s.frames[:len(h.s.frames)-1]
+	h.s.mu.Unlock()
+}
@@ -0,0 +1,594 @@
+// Package dap implements a debugger for Monkey, speaking the Debug Adapter
+// Protocol. It supports line and conditional breakpoints, stepping in, over
+// and out of functions, stack traces and inspecting variables.
+//
+// Breakpoints and stack frames refer to the file of the code they are in,
+// the launched program or an imported module. Module paths are given to
+// the client relative to the directory of the program, as they are in FS.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/jsonrpc"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+// threadID is the only thread, running the program.
+const threadID = 1
+
+// Server debugs a single program, launched by the client.
+type Server struct {
+	// FS is the filesystem programs are read from and run with.
+	FS fs.FS
+	// Resolve, if not nil, converts the program path given by the client to
+	// a path in FS.
+	Resolve func(path string) (string, error)
+
+	w   io.Writer
+	wmu sync.Mutex
+	seq int
+
+	// configured is set by configurationDone; the program starts once it is
+	// configured and launched.
+	configured bool
+	launched   bool
+	done       chan struct{}
+
+	// path is the program as named by the client, and file its path in FS.
+	path    string
+	file    string
+	program *ast.Program
+	args    []string
+	interp  *eval.Interpreter
+
+	// mu guards the state below, shared with the goroutine running the
+	// program.
+	mu          sync.Mutex
+	breakpoints map[string]map[int]*breakpoint
+	frames      []*frame
+	mode        stepMode
+	// depth is the number of frames when stepping over or out started.
+	depth   int
+	entry   bool
+	pause   bool
+	abort   bool
+	stopped bool
+	resume  chan struct{}
+	// refs are the environments and values of variable references, which
+	// are valid while stopped.
+	refs []any
+}
+
+type stepMode int
+
+const (
+	modeRun stepMode = iota
+	modeStepIn
+	modeStepOver
+	modeStepOut
+)
+
+type breakpoint struct {
+	line      int
+	condition ast.Expression
+}
+
+// frame is a call of a function, or the program itself at the bottom of the
+// stack.
+type frame struct {
+	name string
+	env  *object.Environment
+	// file and pos are the file in FS and position of the statement being
+	// evaluated.
+	file string
+	pos  token.Position
+}
+
+func NewServer() *Server {
+	return &Server{
+		breakpoints: map[string]map[int]*breakpoint{},
+		resume:      make(chan struct{}, 1),
+	}
+}
+
+// Serve handles the requests read from r, writing responses and events to
+// w, until the client disconnects or r ends. A running program is stopped
+// first.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.w = w
+	br := bufio.NewReader(r)
+	for {
+		body, err := jsonrpc.ReadFrame(br)
+		if err == io.EOF {
+			s.terminate()
+			return nil
+		}
+		if err != nil {
+			s.terminate()
+			return err
+		}
+		var req Request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.terminate()
+			return fmt.Errorf("invalid request: %w", err)
+		}
+		if req.Command == "disconnect" {
+			s.terminate()
+			return s.respond(&req, nil, nil)
+		}
+		if err := s.handle(&req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) send(msg any) error {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	s.seq++
+	switch msg := msg.(type) {
+	case *Response:
+		msg.Seq = s.seq
+	case *Event:
+		msg.Seq = s.seq
+	}
+	return jsonrpc.WriteFrame(s.w, msg)
+}
+
+func (s *Server) respond(req *Request, body any, err error) error {
+	resp := &Response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	return s.send(resp)
+}
+
+func (s *Server) event(name string, body any) {
+	s.send(&Event{Type: "event", Event: name, Body: body})
+}
+
+// handle answers req, then acts on it.
+func (s *Server) handle(req *Request) error {
+	var (
+		body any
+		err  error
+		then func()
+	)
+	switch req.Command {
+	case "initialize":
+		body = Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsConditionalBreakpoints:   true,
+			SupportsEvaluateForHovers:        true,
+		}
+		then = func() { s.event("initialized", nil) }
+	case "launch":
+		var args LaunchArguments
+		if err = decode(req, &args); err == nil {
+			err = s.launch(&args)
+		}
+		then = s.start
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err = decode(req, &args); err == nil {
+			body = s.setBreakpoints(&args)
+		}
+	case "configurationDone":
+		s.configured = true
+		then = s.start
+	case "threads":
+		body = ThreadsResponseBody{Threads: []Thread{{ID: threadID, Name: "main"}}}
+	case "stackTrace":
+		body, err = s.stackTrace()
+	case "scopes":
+		var args ScopesArguments
+		if err = decode(req, &args); err == nil {
+			body, err = s.scopes(&args)
+		}
+	case "variables":
+		var args VariablesArguments
+		if err = decode(req, &args); err == nil {
+			body, err = s.variables(&args)
+		}
+	case "evaluate":
+		var args EvaluateArguments
+		if err = decode(req, &args); err == nil {
+			body, err = s.evaluate(&args)
+		}
+	case "continue", "next", "stepIn", "stepOut":
+		then, err = s.step(req.Command)
+		if req.Command == "continue" {
+			body = map[string]bool{"allThreadsContinued": true}
+		}
+	case "pause":
+		s.mu.Lock()
+		s.pause = true
+		s.mu.Unlock()
+	default:
+		err = fmt.Errorf("unsupported request %q", req.Command)
+	}
+
+	if err := s.respond(req, body, err); err != nil {
+		return err
+	}
+	if err == nil && then != nil {
+		then()
+	}
+	return nil
+}
+
+func decode(req *Request, args any) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+	return json.Unmarshal(req.Arguments, args)
+}
+
+// launch loads the program. It starts running once configured.
+func (s *Server) launch(args *LaunchArguments) error {
+	if s.launched {
+		return fmt.Errorf("a program is already launched")
+	}
+	if s.FS == nil {
+		return fmt.Errorf("no filesystem available")
+	}
+	caps, err := eval.ParseCapabilities(args.Allow)
+	if err != nil {
+		return err
+	}
+	file := args.Program
+	if s.Resolve != nil {
+		if file, err = s.Resolve(args.Program); err != nil {
+			return err
+		}
+	}
+	src, err := fs.ReadFile(s.FS, file)
+	if err != nil {
+		return err
+	}
+	p := parser.FromInput(string(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return fmt.Errorf("errors in %s: %s", args.Program, strings.Join(p.Errors(), "; "))
+	}
+
+	s.launched = true
+	s.path, s.file, s.program, s.args = args.Program, file, program, args.Args
+	s.interp = eval.New()
+	s.interp.FS = s.FS
+	s.interp.Allow(append(caps, eval.CapIO, eval.CapProcess)...)
+	s.interp.Stdout = &output{s, "stdout"}
+	s.interp.Stderr = &output{s, "stderr"}
+	s.interp.Stdin = strings.NewReader("")
+	s.interp.Hook = hook{s}
+	s.entry = args.StopOnEntry
+	return nil
+}
+
+// start runs the program in the background, once launched and configured.
+func (s *Server) start() {
+	if !s.launched || !s.configured || s.done != nil {
+		return
+	}
+	s.done = make(chan struct{})
+
+	env := object.NewEnv()
+	args := make([]object.Object, len(s.args))
+	for i, arg := range s.args {
+		args[i] = &object.String{Value: arg}
+	}
+	env.Set("args", &object.Array{Elems: args})
+	s.frames = []*frame{{name: "main", env: env, file: s.file}}
+
+	go func() {
+		defer close(s.done)
+		code := 0
+		switch res := s.interp.EvalFile(s.file, s.program, env).(type) {
+		case *object.Exit:
+			code = res.Code
+		case *object.Error:
+			code = 1
+			msg := fmt.Sprintf("%s:%s: %s\n", s.source(res.File), res.Pos, res.Msg)
+			for _, frame := range res.Stack {
+				msg += fmt.Sprintf("\tat %s\n", frame)
+			}
+			s.event("output", OutputEventBody{Category: "stderr", Output: msg})
+		}
+		s.event("exited", ExitedEventBody{ExitCode: code})
+		s.event("terminated", nil)
+	}()
+}
+
+// terminate stops the program, if running, and waits for it to end.
+func (s *Server) terminate() {
+	if s.done == nil {
+		return
+	}
+	s.mu.Lock()
+	s.abort = true
+	if s.stopped {
+		s.stopped = false
+		s.resume <- struct{}{}
+	}
+	s.mu.Unlock()
+	<-s.done
+}
+
+func (s *Server) setBreakpoints(args *SetBreakpointsArguments) SetBreakpointsResponseBody {
+	// lines with a statement, when the source is the program
+	var lines map[int]bool
+	path := filepath.Clean(args.Source.Path)
+	if s.program != nil && path == filepath.Clean(s.path) {
+		lines = statementLines(s.program)
+	}
+
+	bps := map[int]*breakpoint{}
+	result := []Breakpoint{}
+	for _, sb := range args.Breakpoints {
+		bp := Breakpoint{Verified: true, Line: sb.Line}
+		var cond ast.Expression
+		if sb.Condition != "" {
+			p := parser.FromInput(sb.Condition)
+			program := p.ParseProgram()
+			stmt, ok := singleExpression(program)
+			if len(p.Errors()) > 0 || !ok {
+				bp.Verified, bp.Message = false, "invalid condition"
+				result = append(result, bp)
+				continue
+			}
+			cond = stmt
+		}
+		if lines != nil && !lines[sb.Line] {
+			bp.Verified, bp.Message = false, fmt.Sprintf("no statement on line %d", sb.Line)
+		} else {
+			bps[sb.Line] = &breakpoint{line: sb.Line, condition: cond}
+		}
+		result = append(result, bp)
+	}
+
+	s.mu.Lock()
+	s.breakpoints[path] = bps
+	s.mu.Unlock()
+	return SetBreakpointsResponseBody{Breakpoints: result}
+}
+
+// source returns the path of file, a file in FS, as the client names it.
+// The program is named as launched, and other files relative to it when
+// Resolve mapped the program to a path it ends with, and as in FS otherwise.
+func (s *Server) source(file string) string {
+	if file == "" || file == s.file {
+		return s.path
+	}
+	root, ok := strings.CutSuffix(filepath.ToSlash(s.path), s.file)
+	if !ok {
+		return filepath.FromSlash(file)
+	}
+	return filepath.FromSlash(root + file)
+}
+
+// statementLines returns the lines statements of program start on.
+func statementLines(program *ast.Program) map[int]bool {
+	lines := map[int]bool{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if isStatement(n) {
+			lines[n.Pos().Line] = true
+		}
+		return true
+	})
+	return lines
+}
+
+func isStatement(n ast.Node) bool {
+	_, stmt := n.(ast.Statement)
+	_, block := n.(*ast.BlockStatement)
+	return stmt && !block
+}
+
+// singleExpression returns the expression program consists of.
+func singleExpression(program *ast.Program) (ast.Expression, bool) {
+	if len(program.Statements) != 1 {
+		return nil, false
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok || stmt == nil || stmt.Expression == nil {
+		return nil, false
+	}
+	return stmt.Expression, true
+}
+
+// step resumes the program stopped, returning the function resuming it, to
+// be called once the request is answered.
+func (s *Server) step(command string) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		return nil, fmt.Errorf("not stopped")
+	}
+	switch command {
+	case "continue":
+		s.mode = modeRun
+	case "next":
+		s.mode = modeStepOver
+	case "stepIn":
+		s.mode = modeStepIn
+	case "stepOut":
+		s.mode = modeStepOut
+	}
+	s.depth = len(s.frames)
+	return func() {
+		s.mu.Lock()
+		s.stopped = false
+		s.refs = nil
+		s.mu.Unlock()
+		s.resume <- struct{}{}
+	}, nil
+}
+
+// stoppedFrame returns the frame with id while stopped, the top frame for
+// id 0.
+func (s *Server) stoppedFrame(id int) (*frame, error) {
+	if !s.stopped {
+		return nil, fmt.Errorf("not stopped")
+	}
+	if id == 0 {
+		id = len(s.frames)
+	}
+	if id < 1 || id > len(s.frames) {
+		return nil, fmt.Errorf("unknown frame %d", id)
+	}
+	return s.frames[id-1], nil
+}
+
+// stackTrace lists the frames, innermost first. Frame ids are 1 for the
+// program and counting up for each call.
+func (s *Server) stackTrace() (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		return nil, fmt.Errorf("not stopped")
+	}
+	frames := []StackFrame{}
+	for i := len(s.frames) - 1; i >= 0; i-- {
+		f := s.frames[i]
+		src := s.source(f.file)
+		frames = append(frames, StackFrame{
+			ID:     i + 1,
+			Name:   f.name,
+			Source: Source{Name: filepath.Base(src), Path: src},
+			Line:   f.pos.Line,
+			Column: f.pos.Column,
+		})
+	}
+	return StackTraceResponseBody{StackFrames: frames, TotalFrames: len(frames)}, nil
+}
+
+// scopes lists the environments of a frame, from its locals out to the
+// globals.
+func (s *Server) scopes(args *ScopesArguments) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.stoppedFrame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []Scope{}
+	for env := f.env; env != nil; env = env.Outer() {
+		name := "Closure"
+		switch {
+		case env.Outer() == nil:
+			name = "Globals"
+		case env == f.env:
+			name = "Locals"
+		}
+		scopes = append(scopes, Scope{Name: name, VariablesReference: s.ref(env)})
+	}
+	return ScopesResponseBody{Scopes: scopes}, nil
+}
+
+// ref returns a new variable reference to v.
+func (s *Server) ref(v any) int {
+	s.refs = append(s.refs, v)
+	return len(s.refs)
+}
+
+func (s *Server) variables(args *VariablesArguments) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := args.VariablesReference
+	if !s.stopped || id < 1 || id > len(s.refs) {
+		return nil, fmt.Errorf("unknown variables reference %d", id)
+	}
+
+	vars := []Variable{}
+	switch v := s.refs[id-1].(type) {
+	case *object.Environment:
+		for _, name := range v.Names() {
+			val, _ := v.Get(name)
+			vars = append(vars, s.variable(name, val))
+		}
+	case *object.Array:
+		for i, elem := range v.Elems {
+			vars = append(vars, s.variable(fmt.Sprintf("[%d]", i), elem))
+		}
+	case *object.Hash:
+		for _, pair := range v.Pairs() {
+			vars = append(vars, s.variable(pair.Key.Inspect(), pair.Value))
+		}
+	case *object.Module:
+		for _, pair := range v.Exports.Pairs() {
+			vars = append(vars, s.variable(pair.Key.(*object.String).Value, pair.Value))
+		}
+		sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	}
+	return VariablesResponseBody{Variables: vars}, nil
+}
+
+func (s *Server) variable(name string, val object.Object) Variable {
+	return Variable{Name: name, Value: val.Inspect(), Type: string(val.Type()), VariablesReference: s.children(val)}
+}
+
+// children returns a reference to the contents of val, or 0 if it has none.
+func (s *Server) children(val object.Object) int {
+	switch val := val.(type) {
+	case *object.Array:
+		if len(val.Elems) > 0 {
+			return s.ref(val)
+		}
+	case *object.Hash:
+		if val.Len() > 0 {
+			return s.ref(val)
+		}
+	case *object.Module:
+		return s.ref(val)
+	}
+	return 0
+}
+
+// evaluate evaluates an expression in a frame, with an interpreter without
+// capabilities so that inspecting the program has no side effects.
+func (s *Server) evaluate(args *EvaluateArguments) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.stoppedFrame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.FromInput(args.Expression)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(p.Errors(), "; "))
+	}
+	val := eval.New().Eval(program, f.env)
+	if val == nil {
+		val = eval.NULL
+	}
+	if err, ok := val.(*object.Error); ok {
+		return nil, fmt.Errorf("%s", err.Msg)
+	}
+	return EvaluateResponseBody{Result: val.Inspect(), Type: string(val.Type()), VariablesReference: s.children(val)}, nil
+}
+
+// output sends what the program writes to one of its streams as output
+// events.
+type output struct {
+	s        *Server
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.s.event("output", OutputEventBody{Category: o.category, Output: string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,341 @@
+package dap
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"bufio"
+	"github.com/EmilLaursen/wiig/jsonrpc"
+	"github.com/stretchr/testify/require"
+)
+
+const program = `let add = fn(a, b) {
+    let sum = a + b;
+    sum
+};
+let fact = fn(n) {
+    if (n == 0) { return 1; }
+    n * fact(n - 1)
+};
+let x = add(1, 2);
+let y = fact(3);
+puts(x + y);
+`
+
+// message is any message from the server.
+type message struct {
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client drives a server running in-process over pipes, reading its
+// messages in the background.
+type client struct {
+	t        *testing.T
+	w        io.Writer
+	messages chan message
+	seq      int
+	// events are received but not yet waited for.
+	events []message
+	output string
+	done   chan error
+}
+
+func newClient(t *testing.T, files fstest.MapFS) *client {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	c := &client{t: t, w: clientW, messages: make(chan message), done: make(chan error, 1)}
+
+	srv := NewServer()
+	srv.FS = files
+	go func() {
+		c.done <- srv.Serve(serverR, serverW)
+		serverW.Close()
+	}()
+	go func() {
+		defer close(c.messages)
+		r := bufio.NewReader(clientR)
+		for {
+			body, err := jsonrpc.ReadFrame(r)
+			if err != nil {
+				return
+			}
+			var msg message
+			if json.Unmarshal(body, &msg) == nil {
+				c.messages <- msg
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		clientW.Close()
+		clientR.Close()
+	})
+	return c
+}
+
+func (c *client) next() message {
+	msg, ok := <-c.messages
+	require.True(c.t, ok, "connection closed")
+	if msg.Event == "output" {
+		var body OutputEventBody
+		require.NoError(c.t, json.Unmarshal(msg.Body, &body))
+		c.output += body.Output
+	}
+	return msg
+}
+
+// request sends a request and returns its response, decoding the body into
+// body if not nil.
+func (c *client) request(command string, args, body any) message {
+	c.seq++
+	raw, err := json.Marshal(args)
+	require.NoError(c.t, err)
+	require.NoError(c.t, jsonrpc.WriteFrame(c.w, Request{Seq: c.seq, Type: "request", Command: command, Arguments: raw}))
+	for {
+		msg := c.next()
+		if msg.Type == "event" {
+			c.events = append(c.events, msg)
+			continue
+		}
+		require.Equal(c.t, c.seq, msg.RequestSeq)
+		require.Equal(c.t, command, msg.Command)
+		if body != nil && msg.Success {
+			require.NoError(c.t, json.Unmarshal(msg.Body, body))
+		}
+		return msg
+	}
+}
+
+// call is request for requests expected to succeed.
+func (c *client) call(command string, args, body any) {
+	resp := c.request(command, args, body)
+	require.True(c.t, resp.Success, "%s: %s", command, resp.Message)
+}
+
+// wait returns the next event named name, skipping other events.
+func (c *client) wait(name string, body any) {
+	for {
+		var msg message
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.next()
+			require.Equal(c.t, "event", msg.Type)
+		}
+		if msg.Event == name {
+			if body != nil {
+				require.NoError(c.t, json.Unmarshal(msg.Body, body))
+			}
+			return
+		}
+	}
+}
+
+// stopped waits until the program stops, returning the reason and the
+// line of the top frame.
+func (c *client) stopped() (string, int) {
+	var ev StoppedEventBody
+	c.wait("stopped", &ev)
+	var trace StackTraceResponseBody
+	c.call("stackTrace", StackTraceArguments{ThreadID: threadID}, &trace)
+	return ev.Reason, trace.StackFrames[0].Line
+}
+
+func (c *client) start(launch LaunchArguments, bps ...SourceBreakpoint) SetBreakpointsResponseBody {
+	c.call("initialize", map[string]string{"adapterID": "monkey"}, nil)
+	c.wait("initialized", nil)
+	c.call("launch", launch, nil)
+	var set SetBreakpointsResponseBody
+	c.call("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: launch.Program}, Breakpoints: bps}, &set)
+	c.call("configurationDone", nil, nil)
+	return set
+}
+
+func (c *client) exitCode() int {
+	var exited ExitedEventBody
+	c.wait("exited", &exited)
+	c.wait("terminated", nil)
+	return exited.ExitCode
+}
+
+func TestBreakpoints(t *testing.T) {
+	c := newClient(t, fstest.MapFS{"main.mnk": {Data: []byte(program)}})
+	set := c.start(LaunchArguments{Program: "main.mnk"},
+		SourceBreakpoint{Line: 2},
+		SourceBreakpoint{Line: 6, Condition: "n == 1"},
+		SourceBreakpoint{Line: 4},
+		SourceBreakpoint{Line: 7, Condition: "n =="},
+	)
+	require.Equal(t, []Breakpoint{
+		{Verified: true, Line: 2},
+		{Verified: true, Line: 6},
+		{Verified: false, Line: 4, Message: "no statement on line 4"},
+		{Verified: false, Line: 7, Message: "invalid condition"},
+	}, set.Breakpoints)
+
+	reason, line := c.stopped()
+	require.Equal(t, "breakpoint", reason)
+	require.Equal(t, 2, line)
+
+	var trace StackTraceResponseBody
+	c.call("stackTrace", StackTraceArguments{ThreadID: threadID}, &trace)
+	require.Equal(t, []StackFrame{
+		{ID: 2, Name: "add", Source: Source{Name: "main.mnk", Path: "main.mnk"}, Line: 2, Column: 5},
+		{ID: 1, Name: "main", Source: Source{Name: "main.mnk", Path: "main.mnk"}, Line: 9, Column: 1},
+	}, trace.StackFrames)
+
+	var scopes ScopesResponseBody
+	c.call("scopes", ScopesArguments{FrameID: 2}, &scopes)
+	require.Equal(t, []string{"Locals", "Globals"}, []string{scopes.Scopes[0].Name, scopes.Scopes[1].Name})
+
+	var vars VariablesResponseBody
+	c.call("variables", VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &vars)
+	require.Equal(t, []Variable{
+		{Name: "a", Value: "1", Type: "INTEGER"},
+		{Name: "b", Value: "2", Type: "INTEGER"},
+	}, vars.Variables)
+
+	c.call("variables", VariablesArguments{VariablesReference: scopes.Scopes[1].VariablesReference}, &vars)
+	var names []string
+	for _, v := range vars.Variables {
+		names = append(names, v.Name)
+	}
+	require.Equal(t, []string{"add", "args", "fact"}, names)
+	require.Equal(t, "FUNCTION", vars.Variables[0].Type)
+
+	c.call("continue", map[string]int{"threadId": threadID}, nil)
+	reason, line = c.stopped()
+	require.Equal(t, "breakpoint", reason)
+	require.Equal(t, 6, line)
+	c.call("stackTrace", StackTraceArguments{ThreadID: threadID}, &trace)
+	require.Len(t, trace.StackFrames, 4, "main and fact for 3, 2 and 1")
+
+	var res EvaluateResponseBody
+	c.call("evaluate", EvaluateArguments{Expression: "[n, n * 10]", FrameID: 0}, &res)
+	require.Equal(t, "[1, 10]", res.Result)
+	require.NotZero(t, res.VariablesReference)
+	c.call("evaluate", EvaluateArguments{Expression: "n", FrameID: 2}, &res)
+	require.Equal(t, "3", res.Result)
+	resp := c.request("evaluate", EvaluateArguments{Expression: "puts(n)"}, nil)
+	require.Equal(t, "capability 'io' not granted", resp.Message)
+
+	c.call("continue", map[string]int{"threadId": threadID}, nil)
+	require.Equal(t, 0, c.exitCode())
+	require.Equal(t, "9\n", c.output)
+
+	c.call("disconnect", nil, nil)
+	require.NoError(t, <-c.done)
+}
+
+func TestStepping(t *testing.T) {
+	c := newClient(t, fstest.MapFS{"main.mnk": {Data: []byte(program)}})
+	c.start(LaunchArguments{Program: "main.mnk", StopOnEntry: true})
+
+	reason, line := c.stopped()
+	require.Equal(t, "entry", reason)
+	require.Equal(t, 1, line)
+
+	steps := []struct {
+		command string
+		line    int
+	}{
+		{"next", 5},
+		{"next", 9},
+		{"stepIn", 2},
+		{"next", 3},
+		{"stepOut", 10},
+		{"stepIn", 6},
+		{"stepIn", 7},
+		{"stepIn", 6},
+		{"stepOut", 11}, // fact(3) returns with the value of fact(2)
+	}
+	for _, step := range steps {
+		c.call(step.command, map[string]int{"threadId": threadID}, nil)
+		reason, line = c.stopped()
+		require.Equal(t, "step", reason, step.command)
+		require.Equal(t, step.line, line, step.command)
+	}
+
+	c.call("continue", map[string]int{"threadId": threadID}, nil)
+	require.Equal(t, 0, c.exitCode())
+	resp := c.request("next", map[string]int{"threadId": threadID}, nil)
+	require.Equal(t, "not stopped", resp.Message)
+}
+
+func TestModules(t *testing.T) {
+	files := fstest.MapFS{
+		"app/main.mnk":  {Data: []byte("import \"lib/m.mnk\" as m;\nlet x = m.double(2);\nm.fail();\n")},
+		"app/lib/m.mnk": {Data: []byte("export let double = fn(n) {\n    n * 2\n};\nexport let fail = fn() { 1 / 0 };\n")},
+	}
+	c := newClient(t, files)
+	c.call("initialize", map[string]string{"adapterID": "monkey"}, nil)
+	c.wait("initialized", nil)
+	c.call("launch", LaunchArguments{Program: "app/main.mnk"}, nil)
+	c.call("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: "app/lib/m.mnk"}, Breakpoints: []SourceBreakpoint{{Line: 2}}}, nil)
+	c.call("configurationDone", nil, nil)
+
+	reason, line := c.stopped()
+	require.Equal(t, "breakpoint", reason)
+	require.Equal(t, 2, line)
+	var trace StackTraceResponseBody
+	c.call("stackTrace", StackTraceArguments{ThreadID: threadID}, &trace)
+	require.Equal(t, []StackFrame{
+		{ID: 2, Name: "double", Source: Source{Name: "m.mnk", Path: "app/lib/m.mnk"}, Line: 2, Column: 5},
+		{ID: 1, Name: "main", Source: Source{Name: "main.mnk", Path: "app/main.mnk"}, Line: 2, Column: 1},
+	}, trace.StackFrames)
+
+	c.call("continue", map[string]int{"threadId": threadID}, nil)
+	require.Equal(t, 1, c.exitCode())
+	require.Equal(t, "app/lib/m.mnk:4:28: division by zero\n\tat fail (app/main.mnk:3:3)\n", c.output)
+}
+
+func TestDisconnectWhileStopped(t *testing.T) {
+	c := newClient(t, fstest.MapFS{"main.mnk": {Data: []byte(program)}})
+	c.start(LaunchArguments{Program: "main.mnk", StopOnEntry: true})
+	c.stopped()
+
+	c.call("disconnect", nil, nil)
+	require.NoError(t, <-c.done)
+	require.Empty(t, c.output)
+}
+
+func TestLaunch(t *testing.T) {
+	files := fstest.MapFS{
+		"bad.mnk":  {Data: []byte("let = 1;")},
+		"fail.mnk": {Data: []byte("let f = fn() { 1 + true };\nf();")},
+		"exit.mnk": {Data: []byte("puts(args); exit(3);")},
+		"time.mnk": {Data: []byte("now(); 1")},
+	}
+
+	c := newClient(t, files)
+	c.call("initialize", nil, nil)
+	resp := c.request("launch", LaunchArguments{Program: "bad.mnk"}, nil)
+	require.Equal(t, "errors in bad.mnk: expected next token to be IDENT, got = instead; no prefix parse function for = found", resp.Message)
+	resp = c.request("launch", LaunchArguments{Program: "missing.mnk"}, nil)
+	require.Equal(t, "open missing.mnk: file does not exist", resp.Message)
+	resp = c.request("launch", LaunchArguments{Program: "time.mnk", Allow: "clock"}, nil)
+	require.Equal(t, `unknown capability "clock"`, resp.Message)
+	resp = c.request("restart", nil, nil)
+	require.Equal(t, `unsupported request "restart"`, resp.Message)
+
+	c = newClient(t, files)
+	c.start(LaunchArguments{Program: "fail.mnk"})
+	require.Equal(t, 1, c.exitCode())
+	require.Equal(t, "fail.mnk:1:18: type mismatch: INTEGER + BOOLEAN\n\tat f (fail.mnk:2:1)\n", c.output)
+
+	c = newClient(t, files)
+	c.start(LaunchArguments{Program: "exit.mnk", Args: []string{"a"}})
+	require.Equal(t, 3, c.exitCode())
+	require.Equal(t, "[a]\n", c.output)
+
+	c = newClient(t, files)
+	c.start(LaunchArguments{Program: "time.mnk", Allow: "time"})
+	require.Equal(t, 0, c.exitCode())
+}
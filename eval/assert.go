@@ -0,0 +1,106 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/EmilLaursen/wiig/object"
+)
+
+// The assertion builtins, for tests run by `monkey test`. A failed
+// assertion raises an error, which may be caught like any other.
+
+func builtinAssert(_ object.Context, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newErr("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	if isTruthy(args[0]) {
+		return NULL
+	}
+	return assertionErr("assert", args[1:], "")
+}
+
+func builtinAssertEq(_ object.Context, args ...object.Object) object.Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newErr("wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+	if object.Equal(args[0], args[1]) {
+		return NULL
+	}
+	return assertionErr("assertEq", args[2:], inspectDiff(args[0].Inspect(), args[1].Inspect()))
+}
+
+// builtinAssertError calls its function argument without arguments, and
+// returns the error it raises as it would be bound in a catch clause. If a
+// string is given, the message of the error must contain it.
+func builtinAssertError(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newErr("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	switch args[0].(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return newErr("argument to `assertError` must be FUNCTION, got %s", args[0].Type())
+	}
+	var want *object.String
+	if len(args) == 2 {
+		str, ok := args[1].(*object.String)
+		if !ok {
+			return newErr("second argument to `assertError` must be STRING, got %s", args[1].Type())
+		}
+		want = str
+	}
+
+	switch res := ctx.Apply(args[0]).(type) {
+	case *object.Exit:
+		return res
+	case *object.Error:
+		if want != nil && !strings.Contains(res.Msg, want.Value) {
+			return newErr("assertError failed: error %q does not contain %q", res.Msg, want.Value)
+		}
+		return errorValue(res)
+	default:
+		return newErr("assertError failed: no error raised")
+	}
+}
+
+// assertionErr is the error of the failed assertion name, with the message
+// in msg, if any, and detail on the lines after.
+func assertionErr(name string, msg []object.Object, detail string) *object.Error {
+	text := name + " failed"
+	if len(msg) > 0 {
+		if str, ok := msg[0].(*object.String); ok {
+			text += ": " + str.Value
+		} else {
+			text += ": " + msg[0].Inspect()
+		}
+	}
+	if detail != "" {
+		text += "\n" + detail
+	}
+	return newErr("%s", text)
+}
+
+// inspectDiff shows the inspected values got and want one above the other,
+// marking where they first differ.
+func inspectDiff(got, want string) string {
+	if strings.Contains(got, "\n") || strings.Contains(want, "\n") {
+		gotLines, wantLines := strings.Split(got, "\n"), strings.Split(want, "\n")
+		line := 0
+		for line < len(gotLines) && line < len(wantLines) && gotLines[line] == wantLines[line] {
+			line++
+		}
+		return fmt.Sprintf("got:\n%s\nwant:\n%s\nfirst difference on line %d", got, want, line+1)
+	}
+	col := 0
+	for g, w := got, want; g != "" && w != ""; col++ {
+		gr, gn := utf8.DecodeRuneInString(g)
+		wr, wn := utf8.DecodeRuneInString(w)
+		if gr != wr {
+			break
+		}
+		g, w = g[gn:], w[wn:]
+	}
+	return fmt.Sprintf("got:  %s\nwant: %s\n      %s^", got, want, strings.Repeat(" ", col))
+}
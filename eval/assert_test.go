@@ -0,0 +1,42 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`assert(1 < 2)`, `null`},
+		{`assert(null)`, `ERROR: assert failed`},
+		{`assert(false, "one is " + "small")`, `ERROR: assert failed: one is small`},
+		{`assert()`, `ERROR: wrong number of arguments. got=0, want=1 or 2`},
+		{`assertEq([1, {"a": 2}], [1, {"a": 2}])`, `null`},
+		{`assertEq([1, 2, 3], [1, 2, 4])`, "ERROR: assertEq failed\ngot:  [1, 2, 3]\nwant: [1, 2, 4]\n             ^"},
+		{`assertEq("æbc", "æbd", "strings")`, "ERROR: assertEq failed: strings\ngot:  æbc\nwant: æbd\n        ^"},
+		{`assertEq(1, "1")`, "ERROR: assertEq failed\ngot:  1\nwant: 1\n       ^"},
+		{`assertEq(1)`, `ERROR: wrong number of arguments. got=1, want=2 or 3`},
+		{`assertError(fn() { 1 / "a" }).message`, `type mismatch: INTEGER / STRING`},
+		{`assertError(fn() { throw "boom" }, "oo").value`, `boom`},
+		{`assertError(fn() { throw "boom" }, "bang")`, `ERROR: assertError failed: error "boom" does not contain "bang"`},
+		{`assertError(fn() { 1 })`, `ERROR: assertError failed: no error raised`},
+		{`assertError(1)`, "ERROR: argument to `assertError` must be FUNCTION, got INTEGER"},
+		{`try { assert(false) } catch (e) { e.message }`, `assert failed`},
+	}
+
+	for _, tt := range tests {
+		got := testEval(tt.input)
+		require.NotNil(t, got, tt.input)
+		require.Equal(t, tt.want, got.Inspect(), tt.input)
+	}
+}
+
+func TestInspectDiff(t *testing.T) {
+	require.Equal(t, "got:  abc\nwant: abd\n        ^", inspectDiff("abc", "abd"))
+	require.Equal(t, "got:  ab\nwant: abc\n        ^", inspectDiff("ab", "abc"))
+	require.Equal(t, "got:\na\nb\nwant:\na\nc\nfirst difference on line 2", inspectDiff("a\nb", "a\nc"))
+}
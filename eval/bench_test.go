@@ -0,0 +1,25 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/testutils"
+)
+
+// BenchmarkEval evaluates the programs of the benchmarks directory, parsed
+// beforehand: fib.mnk, map_reduce.mnk, strings.mnk and hashes.mnk.
+func BenchmarkEval(b *testing.B) {
+	for _, prog := range testutils.BenchPrograms(b) {
+		b.Run(prog.Name, func(b *testing.B) {
+			program := parser.FromInput(prog.Src).ParseProgram()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if res := Eval(program, object.NewEnv()); isError(res) {
+					b.Fatal(res.Inspect())
+				}
+			}
+		})
+	}
+}
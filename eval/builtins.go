@@ -2,13 +2,25 @@ package eval
 
 import (
 	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/EmilLaursen/wiig/object"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": {
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs:   1,
+		MaxArgs:   1,
+		Signature: "len(value)",
+		Doc:       "Returns the length of a string or array.",
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newErr("wrong number of arguments. got=%d, want=1", len(args))
 			}
@@ -25,7 +37,12 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"push": {
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs:        2,
+		MaxArgs:        2,
+		Signature:      "push(array, value)",
+		Doc:            "Returns a new array with value appended.",
+		TypeAnnotation: "fn(array<T>, T) -> array<T>",
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
 			if len(args) != 2 {
 				return newErr("wrong number of arguments. got=%d, want=2", len(args))
 			}
@@ -45,11 +62,449 @@ var builtins = map[string]*object.Builtin{
 	},
 
 	"puts": {
-		Fn: func(args ...object.Object) object.Object {
+		MinArgs:    0,
+		MaxArgs:    -1,
+		Signature:  "puts(values...)",
+		Doc:        "Prints each value on its own line. Requires the io capability.",
+		Capability: string(CapIO),
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(ctx.Output(), arg.Inspect())
 			}
 			return NULL
 		},
 	},
+
+	"print": {
+		MinArgs:    0,
+		MaxArgs:    -1,
+		Signature:  "print(values...)",
+		Doc:        "Prints the values separated by spaces, without a newline. Requires the io capability.",
+		Capability: string(CapIO),
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			fmt.Fprint(ctx.Output(), joinInspect(args))
+			return NULL
+		},
+	},
+
+	"eprint": {
+		MinArgs:    0,
+		MaxArgs:    -1,
+		Signature:  "eprint(values...)",
+		Doc:        "Prints like print, to standard error. Requires the io capability.",
+		Capability: string(CapIO),
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			fmt.Fprint(ctx.ErrOutput(), joinInspect(args))
+			return NULL
+		},
+	},
+
+	"readLine": {
+		MinArgs:        0,
+		MaxArgs:        0,
+		Signature:      "readLine()",
+		Doc:            "Reads a line from standard input, or returns null at the end of input. Requires the io capability.",
+		TypeAnnotation: "fn() -> string",
+		Capability:     string(CapIO),
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newErr("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			line, err := ctx.Input().ReadString('\n')
+			if err != nil && err != io.EOF {
+				return newErr("readLine: %s", err)
+			}
+			if err == io.EOF && line == "" {
+				return NULL
+			}
+			line = strings.TrimSuffix(line, "\n")
+			return &object.String{Value: strings.TrimSuffix(line, "\r")}
+		},
+	},
+
+	"keys": {
+		MinArgs:        1,
+		MaxArgs:        1,
+		Signature:      "keys(hash)",
+		Doc:            "Returns the keys of a hash in insertion order.",
+		TypeAnnotation: "fn(hash<K, V>) -> array<K>",
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newErr("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newErr("argument to `keys` must be HASH, got %s", args[0].Type())
+			}
+
+			pairs := args[0].(*object.Hash).Pairs()
+			elems := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elems[i] = pair.Key
+			}
+			return &object.Array{Elems: elems}
+		},
+	},
+
+	"values": {
+		MinArgs:        1,
+		MaxArgs:        1,
+		Signature:      "values(hash)",
+		Doc:            "Returns the values of a hash in insertion order.",
+		TypeAnnotation: "fn(hash<K, V>) -> array<V>",
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newErr("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newErr("argument to `values` must be HASH, got %s", args[0].Type())
+			}
+
+			pairs := args[0].(*object.Hash).Pairs()
+			elems := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elems[i] = pair.Value
+			}
+			return &object.Array{Elems: elems}
+		},
+	},
+
+	"items": {
+		MinArgs:        1,
+		MaxArgs:        1,
+		Signature:      "items(hash)",
+		Doc:            "Returns the [key, value] pairs of a hash in insertion order.",
+		TypeAnnotation: "fn(hash<K, K>) -> array<array<K>>",
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newErr("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newErr("argument to `items` must be HASH, got %s", args[0].Type())
+			}
+
+			pairs := args[0].(*object.Hash).Pairs()
+			elems := make([]object.Object, len(pairs))
+			for i, pair := range pairs {
+				elems[i] = &object.Array{Elems: []object.Object{pair.Key, pair.Value}}
+			}
+			return &object.Array{Elems: elems}
+		},
+	},
+
+	"has": {
+		MinArgs:        2,
+		MaxArgs:        2,
+		Signature:      "has(hash, key)",
+		Doc:            "Reports whether hash contains key.",
+		TypeAnnotation: "fn(hash<K, V>, K) -> bool",
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newErr("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newErr("argument to `has` must be HASH, got %s", args[0].Type())
+			}
+			key, ok := object.AsHashable(args[1])
+			if !ok {
+				return newErr("unusable as hash key: %s", args[1].Type())
+			}
+
+			_, ok = args[0].(*object.Hash).Get(key)
+			return nativeBoolToBoolObj(ok)
+		},
+	},
+
+	"delete": {
+		MinArgs:        2,
+		MaxArgs:        2,
+		Signature:      "delete(hash, key)",
+		Doc:            "Returns a new hash without key.",
+		TypeAnnotation: "fn(hash<K, V>, K) -> hash<K, V>",
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newErr("wrong number of arguments. got=%d, want=2", len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newErr("argument to `delete` must be HASH, got %s", args[0].Type())
+			}
+			key, ok := object.AsHashable(args[1])
+			if !ok {
+				return newErr("unusable as hash key: %s", args[1].Type())
+			}
+
+			// like push, leave the argument untouched and return a new hash
+			hash := args[0].(*object.Hash).Copy()
+			hash.Delete(key)
+			return hash
+		},
+	},
+
+	"merge": {
+		MinArgs:   1,
+		MaxArgs:   -1,
+		Signature: "merge(hashes...)",
+		Doc:       "Returns a new hash with the pairs of every hash, later ones winning.",
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newErr("wrong number of arguments. got=%d, want>=1", len(args))
+			}
+
+			hash := object.NewHash()
+			for _, arg := range args {
+				if arg.Type() != object.HASH_OBJ {
+					return newErr("argument to `merge` must be HASH, got %s", arg.Type())
+				}
+				for _, pair := range arg.(*object.Hash).Pairs() {
+					hash.Set(pair.Key.(object.Hashable), pair.Value)
+				}
+			}
+			return hash
+		},
+	},
+
+	"size": {
+		MinArgs:        1,
+		MaxArgs:        1,
+		Signature:      "size(hash)",
+		Doc:            "Returns the number of pairs in a hash.",
+		TypeAnnotation: "fn(hash<K, V>) -> int",
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newErr("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if args[0].Type() != object.HASH_OBJ {
+				return newErr("argument to `size` must be HASH, got %s", args[0].Type())
+			}
+			return &object.Integer{Value: int64(args[0].(*object.Hash).Len())}
+		},
+	},
+
+	"readFile": {
+		MinArgs:        1,
+		MaxArgs:        1,
+		Signature:      "readFile(path)",
+		Doc:            "Returns the contents of a file. Requires the fs capability.",
+		TypeAnnotation: "fn(string) -> string",
+		Capability:     string(CapFS),
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			name, errObj := fileArg(ctx, "readFile", args)
+			if errObj != nil {
+				return errObj
+			}
+			data, err := fs.ReadFile(ctx.FileSystem(), name)
+			if err != nil {
+				return newErr("readFile: %s", err)
+			}
+			return &object.String{Value: string(data)}
+		},
+	},
+
+	"listDir": {
+		MinArgs:        1,
+		MaxArgs:        1,
+		Signature:      "listDir(path)",
+		Doc:            "Returns the names of the entries of a directory. Requires the fs capability.",
+		TypeAnnotation: "fn(string) -> array<string>",
+		Capability:     string(CapFS),
+		Fn: func(ctx object.Context, args ...object.Object) object.Object {
+			name, errObj := fileArg(ctx, "listDir", args)
+			if errObj != nil {
+				return errObj
+			}
+			entries, err := fs.ReadDir(ctx.FileSystem(), name)
+			if err != nil {
+				return newErr("listDir: %s", err)
+			}
+			elems := make([]object.Object, len(entries))
+			for i, e := range entries {
+				elems[i] = &object.String{Value: e.Name()}
+			}
+			return &object.Array{Elems: elems}
+		},
+	},
+
+	"now": {
+		MinArgs:        0,
+		MaxArgs:        0,
+		Signature:      "now()",
+		Doc:            "Returns the current Unix time in milliseconds. Requires the time capability.",
+		TypeAnnotation: "fn() -> int",
+		Capability:     string(CapTime),
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newErr("wrong number of arguments. got=%d, want=0", len(args))
+			}
+			return &object.Integer{Value: time.Now().UnixMilli()}
+		},
+	},
+
+	"random": {
+		MinArgs:        1,
+		MaxArgs:        1,
+		Signature:      "random(n)",
+		Doc:            "Returns a random integer in [0, n). Requires the random capability.",
+		TypeAnnotation: "fn(int) -> int",
+		Capability:     string(CapRandom),
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newErr("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			n, ok := args[0].(*object.Integer)
+			if !ok {
+				return newErr("argument to `random` must be INTEGER, got %s", args[0].Type())
+			}
+			if n.Value <= 0 {
+				return newErr("argument to `random` must be positive, got %d", n.Value)
+			}
+			return &object.Integer{Value: rand.Int64N(n.Value)}
+		},
+	},
+
+	"getenv": {
+		MinArgs:        1,
+		MaxArgs:        1,
+		Signature:      "getenv(name)",
+		Doc:            "Returns an environment variable, or null if unset. Requires the env capability.",
+		TypeAnnotation: "fn(string) -> string",
+		Capability:     string(CapEnv),
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newErr("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			name, ok := args[0].(*object.String)
+			if !ok {
+				return newErr("argument to `getenv` must be STRING, got %s", args[0].Type())
+			}
+			value, ok := os.LookupEnv(name.Value)
+			if !ok {
+				return NULL
+			}
+			return &object.String{Value: value}
+		},
+	},
+
+	"assert": {
+		MinArgs:   1,
+		MaxArgs:   2,
+		Signature: "assert(cond, message?)",
+		Doc:       "Raises an error unless cond is truthy.",
+		Fn:        builtinAssert,
+	},
+	"assertEq": {
+		MinArgs:   2,
+		MaxArgs:   3,
+		Signature: "assertEq(got, want, message?)",
+		Doc:       "Raises an error showing both values unless got and want are equal.",
+		Fn:        builtinAssertEq,
+	},
+	"assertError": {
+		MinArgs:   1,
+		MaxArgs:   2,
+		Signature: "assertError(fn, substring?)",
+		Doc:       "Calls fn and returns the error it raises, as bound in a catch clause, or raises an error if it raises none or its message lacks substring.",
+		Fn:        builtinAssertError,
+	},
+
+	"exit": {
+		MinArgs:    0,
+		MaxArgs:    1,
+		Signature:  "exit(code?)",
+		Doc:        "Ends the program with the exit status code, 0 by default. Requires the process capability.",
+		Capability: string(CapProcess),
+		Fn: func(_ object.Context, args ...object.Object) object.Object {
+			if len(args) > 1 {
+				return newErr("wrong number of arguments. got=%d, want=0 or 1", len(args))
+			}
+			if len(args) == 0 {
+				return &object.Exit{}
+			}
+			code, ok := args[0].(*object.Integer)
+			if !ok {
+				return newErr("argument to `exit` must be INTEGER, got %s", args[0].Type())
+			}
+			return &object.Exit{Code: int(code.Value)}
+		},
+	},
+}
+
+// BuiltinNames returns the sorted names of the builtins and builtin modules.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins)+len(builtinModules))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range builtinModules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupBuiltin returns the builtin or builtin module named name, a
+// *object.Builtin or *object.Module.
+func LookupBuiltin(name string) (object.Object, bool) {
+	if builtin, ok := builtins[name]; ok {
+		return builtin, true
+	}
+	if module, ok := builtinModules[name]; ok {
+		return module, true
+	}
+	return nil, false
+}
+
+// joinInspect joins the inspected args with spaces, for print and eprint.
+func joinInspect(args []object.Object) string {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = arg.Inspect()
+	}
+	return strings.Join(strs, " ")
+}
+
+// fileArg checks the arguments of a builtin taking a single path in the
+// filesystem of ctx.
+func fileArg(ctx object.Context, name string, args []object.Object) (string, *object.Error) {
+	if len(args) != 1 {
+		return "", newErr("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	path, ok := args[0].(*object.String)
+	if !ok {
+		return "", newErr("argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+	if ctx.FileSystem() == nil {
+		return "", newErr("%s: %s", name, errNoFS)
+	}
+	return path.Value, nil
+}
+
+var (
+	builtinNamesOnce sync.Once
+	// builtinNameOf names the builtins, builtin module functions and
+	// methods.
+	builtinNameOf map[*object.Builtin]string
+)
+
+// BuiltinName returns the name of b: that of a builtin, a function of a
+// builtin module like "json.parse", or a method like "array.map". It is
+// empty if b is none of those.
+func BuiltinName(b *object.Builtin) string {
+	builtinNamesOnce.Do(func() {
+		builtinNameOf = make(map[*object.Builtin]string)
+		for typ, ms := range methods {
+			for name, m := range ms {
+				builtinNameOf[m] = strings.ToLower(string(typ)) + "." + name
+			}
+		}
+		for modName, mod := range builtinModules {
+			for _, pair := range mod.Exports.Pairs() {
+				if fn, ok := pair.Value.(*object.Builtin); ok {
+					builtinNameOf[fn] = modName + "." + pair.Key.(*object.String).Value
+				}
+			}
+		}
+		for name, fn := range builtins {
+			builtinNameOf[fn] = name
+		}
+	})
+	return builtinNameOf[b]
 }
@@ -0,0 +1,63 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Capability names a group of builtins with access to the world outside the
+// interpreter. Builtins of a group may only be called once the group is
+// granted with Interpreter.Allow.
+type Capability string
+
+const (
+	CapIO      Capability = "io"      // puts, print, eprint, readLine
+	CapFS      Capability = "fs"      // readFile, listDir
+	CapTime    Capability = "time"    // now
+	CapRandom  Capability = "random"  // random
+	CapEnv     Capability = "env"     // getenv
+	CapProcess Capability = "process" // exit
+)
+
+// AllCapabilities lists every capability group.
+var AllCapabilities = []Capability{CapIO, CapFS, CapTime, CapRandom, CapEnv, CapProcess}
+
+// ParseCapabilities parses a comma separated list of capability groups, as
+// given to `monkey run --allow`. "all" grants every group.
+func ParseCapabilities(s string) ([]Capability, error) {
+	var caps []Capability
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+		case name == "all":
+			caps = append(caps, AllCapabilities...)
+		case isCapability(Capability(name)):
+			caps = append(caps, Capability(name))
+		default:
+			return nil, fmt.Errorf("unknown capability %q", name)
+		}
+	}
+	return caps, nil
+}
+
+func isCapability(c Capability) bool {
+	for _, known := range AllCapabilities {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Allow grants caps to programs run by in.
+func (in *Interpreter) Allow(caps ...Capability) {
+	for _, c := range caps {
+		in.granted[c] = true
+	}
+}
+
+// Allowed reports whether c is granted.
+func (in *Interpreter) Allowed(c Capability) bool {
+	return in.granted[c]
+}
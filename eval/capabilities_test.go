@@ -0,0 +1,60 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilities(t *testing.T) {
+	t.Setenv("MONKEY_TEST_VAR", "set")
+
+	tests := []struct {
+		input string
+		allow []Capability
+		want  string
+	}{
+		{`puts(1)`, nil, `ERROR: capability 'io' not granted`},
+		{`readFile("a")`, nil, `ERROR: capability 'fs' not granted`},
+		{`listDir(".")`, []Capability{CapIO}, `ERROR: capability 'fs' not granted`},
+		{`now()`, nil, `ERROR: capability 'time' not granted`},
+		{`now() > 0`, []Capability{CapTime}, `true`},
+		{`random(10)`, []Capability{CapTime}, `ERROR: capability 'random' not granted`},
+		{`let r = random(3); [0, 1, 2].map(fn(x) { x == r }).filter(fn(b) { b }).len() == 1`, []Capability{CapRandom}, `true`},
+		{`random(0)`, []Capability{CapRandom}, `ERROR: argument to ` + "`random`" + ` must be positive, got 0`},
+		{`getenv("MONKEY_TEST_VAR")`, nil, `ERROR: capability 'env' not granted`},
+		{`getenv("MONKEY_TEST_VAR")`, []Capability{CapEnv}, `set`},
+		{`getenv("MONKEY_TEST_UNSET")`, []Capability{CapEnv}, `null`},
+		{`let f = puts; try { f(1) } catch (e) { e.message }`, nil, `capability 'io' not granted`},
+		{`len("pure")`, nil, `4`},
+	}
+
+	for _, tt := range tests {
+		p := parser.FromInput(tt.input)
+		program := p.ParseProgram()
+		require.Empty(t, p.Errors(), tt.input)
+		in := New()
+		in.Allow(tt.allow...)
+		got := in.Eval(program, object.NewEnv())
+		require.Equal(t, tt.want, got.Inspect(), tt.input)
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	caps, err := ParseCapabilities("fs, time")
+	require.NoError(t, err)
+	require.Equal(t, []Capability{CapFS, CapTime}, caps)
+
+	caps, err = ParseCapabilities("all")
+	require.NoError(t, err)
+	require.Equal(t, AllCapabilities, caps)
+
+	caps, err = ParseCapabilities("")
+	require.NoError(t, err)
+	require.Empty(t, caps)
+
+	_, err = ParseCapabilities("fs,network")
+	require.EqualError(t, err, `unknown capability "network"`)
+}
@@ -2,9 +2,11 @@ package eval
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/EmilLaursen/wiig/ast"
 	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/token"
 )
 
 var (
@@ -13,24 +15,25 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// isError reports whether o aborts evaluation, being an error or an exit.
 func isError(o object.Object) bool {
-	return o != nil && o.Type() == object.ERROR_OBJ
+	return o != nil && (o.Type() == object.ERROR_OBJ || o.Type() == object.EXIT_OBJ)
 }
 
-func Eval(node ast.Node, env *object.Environment) object.Object {
+func (in *Interpreter) evalNode(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return in.evalProgram(node.Statements, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return in.Eval(node.Expression, env)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return in.evalBlockStatement(node, env)
 
 	case *ast.IfExpression:
-		return evalIfExp(node, env)
+		return in.evalIfExp(node, env)
 
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
@@ -41,61 +44,148 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
+	case *ast.Null:
+		return NULL
+
 	case *ast.ReturnStatement:
-		v := Eval(node.ReturnValue, env)
+		v := in.Eval(node.ReturnValue, env)
 		if isError(v) {
 			return v
 		}
 		return &object.ReturnValue{Value: v}
 
+	case *ast.ThrowStatement:
+		v := in.Eval(node.Value, env)
+		if isError(v) {
+			return v
+		}
+		return throw(v)
+
+	case *ast.TryExpression:
+		return in.evalTryExpression(node, env)
+
 	case *ast.LetStatement:
-		v := Eval(node.Value, env)
+		v := in.Eval(node.Value, env)
 		if isError(v) {
 			return v
 		}
 
-		env.Set(node.Name.Value, v)
+		define(env, node.Name, v)
+
+	case *ast.ImportStatement:
+		return in.evalImport(node, env)
+
+	case *ast.ExportStatement:
+		return in.Eval(node.Let, env)
 
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
 	case *ast.FunctionLiteral:
 		return &object.Function{
+			Name:   node.Name,
+			File:   in.file,
+			Pos:    node.Pos(),
 			Params: node.Params,
 			Body:   node.Body,
+			Locals: node.Locals,
 			Env:    env,
 		}
 
-	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+	case *ast.IndexExpression, *ast.SliceExpression, *ast.MemberExpression, *ast.CallExpression:
+		// The root of a chain evaluates to null if the chain was cut short.
+		if res := in.evalLink(node.(ast.Expression), env); res != skipped {
+			return res
+		}
+		return NULL
+
+	case *ast.ArrayLiteral:
+		elems := in.evalExpressions(node.Elems, env)
+		if len(elems) == 1 && isError(elems[0]) {
+			return elems[0]
+		}
+		return &object.Array{Elems: elems}
+
+	case *ast.HashLiteral:
+		return in.evalHashLiteral(node, env)
+
+	case *ast.PrefixExpression:
+		right := in.Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExp(node.Operator, right)
+
+	case *ast.InfixExpression:
+		left := in.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
+		if node.Operator == "??" {
+			if left != NULL {
+				return left
+			}
+			return in.Eval(node.Right, env)
+		}
+		right := in.Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalInfixExp(node.Operator, left, right)
+
+	}
+
+	return nil
+}
+
+// skipped is what the links of a chain of member, index, slice and call
+// expressions evaluate to after an optional link, like `a?.b`, found null.
+// The rest of the chain is skipped, and its root evaluates to null. It has
+// a type of its own, pointers to the empty Null being possibly equal.
+var skipped object.Object = &skippedLink{}
 
-		index := Eval(node.Index, env)
+type skippedLink struct{ object.Null }
+
+// evalLink evaluates node, a link of a chain, to skipped if the chain was
+// cut short.
+func (in *Interpreter) evalLink(node ast.Expression, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.IndexExpression:
+		left := in.evalOperand(node.Left, env)
+		if isError(left) || left == skipped {
+			return left
+		}
+		if node.Optional && left == NULL {
+			return skipped
+		}
+
+		index := in.Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
 
 	case *ast.SliceExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
+		left := in.evalOperand(node.Left, env)
+		if isError(left) || left == skipped {
 			return left
 		}
+		if node.Optional && left == NULL {
+			return skipped
+		}
 
 		var ileft object.Object = NULL
 		var iright object.Object = NULL
 
 		if node.IndexLeft != nil {
-			ileft = Eval(node.IndexLeft, env)
+			ileft = in.Eval(node.IndexLeft, env)
 			if isError(ileft) {
 				return ileft
 			}
 		}
 
 		if node.IndexRight != nil {
-			iright = Eval(node.IndexRight, env)
+			iright = in.Eval(node.IndexRight, env)
 			if isError(iright) {
 				return iright
 			}
@@ -103,69 +193,173 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		return evalSliceExpression(left, ileft, iright)
 
+	case *ast.MemberExpression:
+		obj := in.evalOperand(node.Object, env)
+		if isError(obj) || obj == skipped {
+			return obj
+		}
+		if node.Optional && obj == NULL {
+			return skipped
+		}
+		return evalMemberExpression(obj, node.Property.Value)
+
 	case *ast.CallExpression:
-		fn := Eval(node.Function, env)
-		if isError(fn) {
+		if member, ok := node.Function.(*ast.MemberExpression); ok {
+			return in.evalMethodCall(member, node.Arguments, env)
+		}
+
+		fn := in.evalOperand(node.Function, env)
+		if isError(fn) || fn == skipped {
 			return fn
 		}
 
-		args := evalExpressions(node.Arguments, env)
+		args := in.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return applyfunction(fn, args)
+		return in.addFrame(in.applyfunction(fn, args), functionName(fn), node.Function.Pos())
 
-	case *ast.ArrayLiteral:
-		elems := evalExpressions(node.Elems, env)
-		if len(elems) == 1 && isError(elems[0]) {
-			return elems[0]
+	}
+	return nil
+}
+
+// evalOperand evaluates the left operand of a link of a chain, which is
+// skipped if it is itself a link cut short.
+func (in *Interpreter) evalOperand(node ast.Expression, env *object.Environment) object.Object {
+	switch node.(type) {
+	case *ast.IndexExpression, *ast.SliceExpression, *ast.MemberExpression, *ast.CallExpression:
+	default:
+		return in.Eval(node, env)
+	}
+	// As Eval, without turning skipped into null.
+	if in.Hook != nil {
+		if res := in.Hook.Before(node, env); res != nil {
+			return res
 		}
-		return &object.Array{Elems: elems}
+	}
+	res := in.evalLink(node, env)
+	if err, ok := res.(*object.Error); ok && !err.Pos.IsValid() {
+		err.File, err.Pos = in.file, node.Pos()
+	}
+	return res
+}
 
-	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+func (in *Interpreter) applyfunction(fn object.Object, args []object.Object) object.Object {
+	if in.Hook == nil {
+		return in.call(fn, args)
+	}
+	in.Hook.Enter(fn, args)
+	res := in.call(fn, args)
+	in.Hook.Leave(fn, res)
+	return res
+}
 
-	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
-		if isError(right) {
-			return right
+func (in *Interpreter) call(fn object.Object, args []object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if len(args) < len(fn.Params) {
+			return newErr("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Params))
 		}
-		return evalPrefixExp(node.Operator, right)
-
-	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
-		if isError(left) {
-			return left
+		scope := object.NewFrame(fn.Env, fn.Locals)
+		for i, p := range fn.Params {
+			define(scope, p, args[i])
 		}
-		right := Eval(node.Right, env)
-		if isError(right) {
-			return right
+		outer := in.file
+		in.file = fn.File
+		res := in.Eval(fn.Body, scope)
+		in.file = outer
+		return orNull(unwrapReturn(res))
+
+	case *object.Builtin:
+		if fn.Capability != "" && !in.Allowed(Capability(fn.Capability)) {
+			return newErr("capability '%s' not granted", fn.Capability)
 		}
-		return evalInfixExp(node.Operator, left, right)
+		return fn.Fn(in, args...)
+	default:
+		return newErr("not a function: %s", fn.Type())
+	}
+}
 
+// evalMethodCall evaluates `object.name(args)`. Methods registered for the
+// type of object take precedence, after which a hash field holding a
+// function is called.
+func (in *Interpreter) evalMethodCall(member *ast.MemberExpression, argExps []ast.Expression, env *object.Environment) object.Object {
+	obj := in.evalOperand(member.Object, env)
+	if isError(obj) || obj == skipped {
+		return obj
+	}
+	if member.Optional && obj == NULL {
+		return skipped
 	}
 
-	return nil
+	name := member.Property.Value
+	args := in.evalExpressions(argExps, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	if method, ok := methods[obj.Type()][name]; ok {
+		res := in.applyfunction(method, append([]object.Object{obj}, args...))
+		return in.addFrame(res, name, member.Property.Pos())
+	}
+	if hash := fieldsOf(obj); hash != nil {
+		if field, ok := hash.Get(&object.String{Value: name}); ok {
+			return in.addFrame(in.applyfunction(field, args), name, member.Property.Pos())
+		}
+	}
+	return newErr("unknown method: %s.%s", obj.Type(), name)
 }
 
-func applyfunction(fn object.Object, args []object.Object) object.Object {
+// fieldsOf returns the hash holding the fields of obj readable with dot
+// access, or nil.
+func fieldsOf(obj object.Object) *object.Hash {
+	switch obj := obj.(type) {
+	case *object.Hash:
+		return obj
+	case *object.Module:
+		return obj.Exports
+	default:
+		return nil
+	}
+}
+
+// functionName names fn in stack traces.
+func functionName(fn object.Object) string {
 	switch fn := fn.(type) {
 	case *object.Function:
-		scope := object.NewScope(fn.Env)
-		for i, p := range fn.Params {
-			scope.Set(p.Value, args[i])
+		if fn.Name != "" {
+			return fn.Name
 		}
-		ret := Eval(fn.Body, scope)
-		return unwrapReturn(ret)
-
+		return "<anonymous>"
 	case *object.Builtin:
-		return fn.Fn(args...)
+		if name := BuiltinName(fn); name != "" {
+			return name
+		}
+		return "<builtin>"
 	default:
-		return newErr("not a function: %s", fn.Type())
+		return "<" + strings.ToLower(string(fn.Type())) + ">"
 	}
 }
 
+// addFrame records the call of function at pos, in the current file, on the
+// stack of res, if res is an error.
+func (in *Interpreter) addFrame(res object.Object, function string, pos token.Position) object.Object {
+	if err, ok := res.(*object.Error); ok {
+		err.Stack = append(err.Stack, object.Frame{Function: function, File: in.file, Pos: pos})
+	}
+	return res
+}
+
+// orNull returns o, or null if o is nil, the value of empty blocks and
+// blocks ending with a let, where an expression needs a value.
+func orNull(o object.Object) object.Object {
+	if o == nil {
+		return NULL
+	}
+	return o
+}
+
 func unwrapReturn(o object.Object) object.Object {
 	if r, ok := o.(*object.ReturnValue); ok {
 		return r.Value
@@ -173,10 +367,10 @@ func unwrapReturn(o object.Object) object.Object {
 	return o
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (in *Interpreter) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var r []object.Object
 	for _, e := range exps {
-		evald := Eval(e, env)
+		evald := in.Eval(e, env)
 		if isError(evald) {
 			return []object.Object{evald}
 		}
@@ -185,16 +379,16 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return r
 }
 
-func evalIfExp(n *ast.IfExpression, env *object.Environment) object.Object {
-	cond := Eval(n.Condition, env)
+func (in *Interpreter) evalIfExp(n *ast.IfExpression, env *object.Environment) object.Object {
+	cond := in.Eval(n.Condition, env)
 	if isError(cond) {
 		return cond
 	}
 
 	if isTruthy(cond) {
-		return Eval(n.Consequence, env)
+		return orNull(in.Eval(n.Consequence, env))
 	} else if n.Alternative != nil {
-		return Eval(n.Alternative, env)
+		return orNull(in.Eval(n.Alternative, env))
 	} else {
 		return NULL
 	}
@@ -213,33 +407,78 @@ func isTruthy(o object.Object) bool {
 	}
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+func throw(v object.Object) *object.Error {
+	msg := v.Inspect()
+	if str, ok := v.(*object.String); ok {
+		msg = str.Value
+	}
+	return &object.Error{Msg: msg, Value: v}
+}
+
+func (in *Interpreter) evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	res := in.Eval(node.Block, env)
+
+	if err, ok := res.(*object.Error); ok && node.Catch != nil {
+		scope := object.NewFrame(env, node.CatchLocals)
+		if node.Param != nil {
+			define(scope, node.Param, errorValue(err))
+		}
+		res = in.Eval(node.Catch, scope)
+	}
+
+	if node.Finally != nil {
+		fin := in.Eval(node.Finally, env)
+		if isError(fin) || (fin != nil && fin.Type() == object.RETURN_VALUE_OBJ) {
+			return fin
+		}
+	}
+	return orNull(res)
+}
+
+// errorValue is the value a caught error is bound to in a catch clause.
+func errorValue(err *object.Error) object.Object {
+	stack := make([]object.Object, len(err.Stack))
+	for i, f := range err.Stack {
+		stack[i] = &object.String{Value: f.String()}
+	}
+
+	var value object.Object = NULL
+	if err.Value != nil {
+		value = err.Value
+	}
+
+	hash := object.NewHash()
+	hash.Set(&object.String{Value: "message"}, &object.String{Value: err.Msg})
+	hash.Set(&object.String{Value: "line"}, &object.Integer{Value: int64(err.Pos.Line)})
+	hash.Set(&object.String{Value: "column"}, &object.Integer{Value: int64(err.Pos.Column)})
+	hash.Set(&object.String{Value: "stack"}, &object.Array{Elems: stack})
+	hash.Set(&object.String{Value: "value"}, value)
+	return hash
+}
+
+func (in *Interpreter) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
 
-	for kn, vn := range node.Pairs {
-		key := Eval(kn, env)
+	for _, kn := range node.Keys {
+		key := in.Eval(kn, env)
 		if isError(key) {
 			return key
 		}
 
-		hashKey, ok := key.(object.Hashable)
+		hashKey, ok := object.AsHashable(key)
 		if !ok {
 			return newErr("key is not hashable: %s", key.Type())
 		}
 
-		value := Eval(vn, env)
+		value := in.Eval(node.Pairs[kn], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{
-			Key:   key,
-			Value: value,
-		}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalSliceExpression(left, ileft, rleft object.Object) object.Object {
@@ -323,39 +562,54 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hsh := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
+	key, ok := object.AsHashable(index)
 	if !ok {
 		return newErr("unusable as hash key: %s", index.Type())
 	}
-	pair, ok := hsh.Pairs[key.HashKey()]
+	val, ok := hsh.Get(key)
 	if !ok {
 		return NULL
 	}
-	return pair.Value
+	return val
 }
 
-func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Hash:
+		return evalHashIndexExpression(obj, &object.String{Value: name})
+	case *object.Module:
+		val, ok := obj.Exports.Get(&object.String{Value: name})
+		if !ok {
+			return newErr("module %s has no export %s", obj.Path, name)
+		}
+		return val
+	default:
+		return newErr("member access not supported: %s.%s", obj.Type(), name)
+	}
+}
+
+func (in *Interpreter) evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var res object.Object
 	for _, stmt := range stmts {
-		res = Eval(stmt, env)
+		res = in.Eval(stmt, env)
 
 		switch r := res.(type) {
 		case *object.ReturnValue:
 			return r.Value
-		case *object.Error:
+		case *object.Error, *object.Exit:
 			return r
 		}
 	}
 	return res
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (in *Interpreter) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var r object.Object
 	for _, statement := range block.Statements {
-		r = Eval(statement, env)
+		r = in.Eval(statement, env)
 		if r != nil {
 			rt := r.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || isError(r) {
 				return r
 			}
 		}
@@ -376,6 +630,9 @@ func evalPrefixExp(op string, right object.Object) object.Object {
 
 func evalInfixExp(op string, left object.Object, right object.Object) object.Object {
 	switch {
+	case (left == NULL || right == NULL) && (op == "==" || op == "!="):
+		return nativeBoolToBoolObj((left == right) == (op == "=="))
+
 	case left.Type() != right.Type():
 		return newErr("type mismatch: %s %s %s", left.Type(), op, right.Type())
 
@@ -388,6 +645,9 @@ func evalInfixExp(op string, left object.Object, right object.Object) object.Obj
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExp(op, left, right)
 
+	case left.Type() == object.ARRAY_OBJ || left.Type() == object.HASH_OBJ:
+		return evalStructuralInfixExp(op, left, right)
+
 	default:
 		return newErr("unknown operator: %s %s %s", left.Type(), op, right.Type())
 	}
@@ -405,6 +665,9 @@ func evalIntInfixExp(op string, left object.Object, right object.Object) object.
 	case "*":
 		res = l * r
 	case "/":
+		if r == 0 {
+			return newErr("division by zero")
+		}
 		res = l / r
 	case ">":
 		return nativeBoolToBoolObj(l > r)
@@ -442,6 +705,18 @@ func evalStringInfixExp(op string, left object.Object, right object.Object) obje
 	return &object.String{Value: l + r}
 }
 
+// evalStructuralInfixExp compares arrays and hashes by their contents.
+func evalStructuralInfixExp(op string, left object.Object, right object.Object) object.Object {
+	switch op {
+	case "==":
+		return nativeBoolToBoolObj(object.Equal(left, right))
+	case "!=":
+		return nativeBoolToBoolObj(!object.Equal(left, right))
+	default:
+		return newErr("unknown operator: %s %s %s", left.Type(), op, right.Type())
+	}
+}
+
 func evalMinusOpExp(right object.Object) object.Object {
 	if right.Type() != object.INTEGER_OBJ {
 		return newErr("unknown operator: -%s", right.Type())
@@ -463,13 +738,29 @@ func evalBangOperatorExp(right object.Object) object.Object {
 	}
 }
 
+// define binds the variable named by ident to val, in its slot if it is a
+// local one.
+func define(env *object.Environment, ident *ast.Identifier, val object.Object) {
+	if !ident.Local || !env.SetSlot(ident.Slot, val) {
+		env.Set(ident.Value, val)
+	}
+}
+
+// evalIdentifier looks up local variables by slot. They are looked up by
+// name like globals while their slot is unset, as the identifier may name
+// a variable of an outer scope until the let of the local one.
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Local {
+		if v := env.GetSlot(node.Depth, node.Slot); v != nil {
+			return v
+		}
+	}
 	v, ok := env.Get(node.Value)
 	if ok {
 		return v
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := LookupBuiltin(node.Value); ok {
 		return builtin
 	}
 
@@ -2,11 +2,13 @@ package eval
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/EmilLaursen/wiig/object"
 	"github.com/EmilLaursen/wiig/parser"
 	"github.com/EmilLaursen/wiig/testutils"
+	"github.com/EmilLaursen/wiig/token"
 	"github.com/stretchr/testify/require"
 )
 
@@ -235,6 +237,18 @@ if (10 > 1) {
 			`"Hello" - "World"`,
 			"unknown operator: STRING - STRING",
 		},
+		{
+			"1 / 0",
+			"division by zero",
+		},
+		{
+			"let f = fn(a, b) { a }; f(1)",
+			"wrong number of arguments. got=1, want=2",
+		},
+		{
+			"[1, 2].map(fn(a, b) { a })",
+			"wrong number of arguments. got=1, want=2",
+		},
 	}
 
 	for i, tt := range tests {
@@ -268,7 +282,7 @@ func TestLetStatements(t *testing.T) {
 func TestFunctionObject(t *testing.T) {
 	input := `fn(x) {x + 2; };`
 
-	wantBody := "(x + 2)"
+	wantBody := "{ (x + 2) }"
 
 	o := testEval(input)
 	fn := testutils.IsType[*object.Function](t, o)
@@ -288,6 +302,7 @@ func TestFunctionApplication(t *testing.T) {
 		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
 		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
 		{"fn(x) { x; }(5)", 5},
+		{"let double = fn(x: int) -> int { x * 2 }; let y: int = double(5); y", 10},
 	}
 
 	for _, tt := range tests {
@@ -307,6 +322,33 @@ addTwo(2);
 	testIntegerObj(t, 4, testEval(input))
 }
 
+func TestLocalVariables(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`let f = fn(a, b) { let c = a + b; let d = c * 2; d }; f(1, 2)`, `6`},
+		{`let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f()`, `3`},
+		{`let f = fn(c) { if (c) { let z = 1 }; z }; f(true)`, `1`},
+		{`let f = fn(c) { if (c) { let z = 1 }; z }; f(false)`, `ERROR: identifier not found: z`},
+		{`let f = fn(x, x) { x }; f(1, 2)`, `2`},
+		{`let f = fn() {}; f()`, `null`},
+		{`let f = fn() { let x = 1 }; [f()]`, `[null]`},
+		{`[if (true) {}, try {} catch (e) {}, try { throw 1 } catch (e) { let x = e }]`, `[null, null, null]`},
+		{`let f = fn() { let g = fn() { h() }; let h = fn() { 5 }; g() }; f()`, `5`},
+		{`let f = fn(n) { let k = n; fn() { fn() { k + n } } }; f(2)()()`, `4`},
+		{`let f = fn() { try { throw 1 } catch (e) { let m = e.value; m + 1 } }; f()`, `2`},
+		{`let f = fn(e) { try { throw 1 } catch (e) { e.value }; e }; f(7)`, `7`},
+		{`let f = fn() { let x = 1; let x = x + 1; x }; f()`, `2`},
+		{`let counter = fn() { let n = 0; fn() { n + 1 } }; counter()()`, `1`},
+	}
+
+	for _, tt := range tests {
+		got := testEval(tt.input)
+		require.Equal(t, tt.want, got.Inspect(), tt.input)
+	}
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 	want := "Hello World!"
@@ -365,6 +407,42 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+// TestBuiltinDescriptions checks the descriptions of the builtins, and
+// that they take the number of arguments described.
+func TestBuiltinDescriptions(t *testing.T) {
+	var fns []*object.Builtin
+	for _, name := range BuiltinNames() {
+		switch b, _ := LookupBuiltin(name); b := b.(type) {
+		case *object.Builtin:
+			fns = append(fns, b)
+		case *object.Module:
+			for _, pair := range b.Exports.Pairs() {
+				fns = append(fns, pair.Value.(*object.Builtin))
+			}
+		}
+	}
+
+	for _, b := range fns {
+		name := BuiltinName(b)
+		require.True(t, strings.HasPrefix(b.Signature, name+"("), name)
+		require.NotEmpty(t, b.Doc, name)
+		require.True(t, b.MaxArgs < 0 || b.MaxArgs >= b.MinArgs, name)
+
+		wrongs := []int{b.MinArgs - 1}
+		if b.MaxArgs >= 0 {
+			wrongs = append(wrongs, b.MaxArgs+1)
+		}
+		for _, n := range wrongs {
+			if n < 0 {
+				continue
+			}
+			errObj, ok := b.Fn(nil, make([]object.Object, n)...).(*object.Error)
+			require.True(t, ok, "%s with %d arguments", name, n)
+			require.Contains(t, errObj.Msg, "wrong number of arguments", name)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1,2*2,3+3]"
 	res := testutils.IsType[*object.Array](t, testEval(input))
@@ -545,3 +623,335 @@ func TestArrayIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+{
+	"one": 10 - 9,
+	two: 1 + 1,
+	"thr" + "ee": 6 / 2,
+	4: 4,
+	true: 5,
+	false: 6
+}`
+	res := testutils.IsType[*object.Hash](t, testEval(input))
+	require.Equal(t, `{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}`, res.Inspect())
+
+	pairs := res.Pairs()
+	require.Len(t, pairs, 6)
+	testIntegerObj(t, 1, pairs[0].Value)
+	testIntegerObj(t, 6, pairs[5].Value)
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`keys({"a": 1, "b": 2, "c": 3})`, `[a, b, c]`},
+		{`values({"a": 1, "b": 2, "c": 3})`, `[1, 2, 3]`},
+		{`items({"a": 1, 2: true})`, `[[a, 1], [2, true]]`},
+		{`keys({})`, `[]`},
+		{`has({"a": 1}, "a")`, `true`},
+		{`has({"a": 1}, "b")`, `false`},
+		{`delete({"a": 1, "b": 2, "c": 3}, "b")`, `{a: 1, c: 3}`},
+		{`delete({"a": 1}, "b")`, `{a: 1}`},
+		{`let h = {"a": 1, "b": 2}; delete(h, "a"); h`, `{a: 1, b: 2}`},
+		{`let h = delete({"a": 1, "b": 2, "c": 3}, "a"); h["c"]`, `3`},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, `{a: 1, b: 3, c: 4}`},
+		{`merge({"a": 1})`, `{a: 1}`},
+		{`size({"a": 1, "b": 2})`, `2`},
+		{`size({})`, `0`},
+		{`keys(1)`, "ERROR: argument to `keys` must be HASH, got INTEGER"},
+		{`has({}, fn(x) { x })`, "ERROR: unusable as hash key: FUNCTION"},
+		{`merge({}, [])`, "ERROR: argument to `merge` must be HASH, got ARRAY"},
+		{`size({}, {})`, "ERROR: wrong number of arguments. got=2, want=1"},
+	}
+
+	for i, tt := range tests {
+		got := testEval(tt.input)
+		require.NotNil(t, got, "case %d, input=%s", i, tt.input)
+		require.Equal(t, tt.want, got.Inspect(), "case %d, input=%s", i, tt.input)
+	}
+}
+
+func TestHashEquality(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{`{} == {}`, true},
+		{`{"a": 1} == {"a": 1}`, true},
+		{`{"a": 1, "b": 2} == {"b": 2, "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{`{"a": {"b": 1}} == {"a": {"b": 1}}`, true},
+		{`{"a": 1} != {"a": 2}`, true},
+		{`{"a": 1} != {"a": 1}`, false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObj(t, tt.want, testEval(tt.input))
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{`[] == []`, true},
+		{`[1, 2] == [1, 2]`, true},
+		{`[1, 2] == [2, 1]`, false},
+		{`[1, 2] == [1, 2, 3]`, false},
+		{`[1, [2, "a"]] == [1, [2, "a"]]`, true},
+		{`[1, [2, "a"]] == [1, [2, "b"]]`, false},
+		{`[{"a": [1]}] == [{"a": [1]}]`, true},
+		{`[1, 2] != [1, 2]`, false},
+		{`[1, 2] != [1]`, true},
+		{`let f = fn(x) { x }; [f] == [f]`, true},
+		{`[fn(x) { x }] == [fn(x) { x }]`, false},
+	}
+
+	for i, tt := range tests {
+		got := testEval(tt.input)
+		require.NotNil(t, got, "case %d, input=%s", i, tt.input)
+		testBooleanObj(t, tt.want, got)
+	}
+}
+
+func TestStructuralHashKeys(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{[1, 2]: "x"}[[1, 2]]`, `x`},
+		{`{[1, 2]: "x"}[[2, 1]]`, `null`},
+		{`{[1, [2]]: "x"}[[1, [2]]]`, `x`},
+		{`{{"a": 1, "b": 2}: "x"}[{"b": 2, "a": 1}]`, `x`},
+		{`{[1, 2]: "x", [1, 2]: "y"}`, `{[1, 2]: y}`},
+		{`{[]: 1, [[]]: 2, "": 3}`, `{[]: 1, [[]]: 2, : 3}`},
+		{`has({[1]: 1}, [1])`, `true`},
+		{`delete({[1]: 1, [2]: 2}, [1])`, `{[2]: 2}`},
+		{`{[fn(x) { x }]: 1}`, `ERROR: key is not hashable: ARRAY`},
+		{`{{"a": fn(x) { x }}: 1}`, `ERROR: key is not hashable: HASH`},
+		{`{}[[fn(x) { x }]]`, `ERROR: unusable as hash key: ARRAY`},
+	}
+
+	for i, tt := range tests {
+		got := testEval(tt.input)
+		require.NotNil(t, got, "case %d, input=%s", i, tt.input)
+		require.Equal(t, tt.want, got.Inspect(), "case %d, input=%s", i, tt.input)
+	}
+}
+
+func TestNullOperators(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`null`, `null`},
+		{`null == null`, `true`},
+		{`null != null`, `false`},
+		{`1 == null`, `false`},
+		{`null != "a"`, `true`},
+		{`{"a": 1}["b"] == null`, `true`},
+		{`null ?? 1`, `1`},
+		{`2 ?? 1`, `2`},
+		{`false ?? 1`, `false`},
+		{`null ?? null ?? 3`, `3`},
+		{`{"a": 1}["b"] ?? 0`, `0`},
+		{`1 ?? foobar`, `1`},
+		{`null ?? foobar`, `ERROR: identifier not found: foobar`},
+		{`let h = {"a": {"b": 5}}; h?.a?.b`, `5`},
+		{`let h = {"a": {"b": 5}}; h?.x?.b`, `null`},
+		{`let h = null; h?.a`, `null`},
+		{`let h = null; h?[0]`, `null`},
+		{`let h = null; h?[1:]`, `null`},
+		{`let h = null; h?.a ?? "default"`, `default`},
+		{`[1, 2]?[0]`, `1`},
+		{`[1, 2]?[1:]`, `[2]`},
+		{`let h = null; h[0]`, `ERROR: index operator not supported: NULL`},
+		{`1?.a`, `ERROR: member access not supported: INTEGER.a`},
+		{`null?.b.c`, `null`},
+		{`null?[0][1]`, `null`},
+		{`null?.a[0][1:].b`, `null`},
+		{`null?.a.b()`, `null`},
+		{`null?.a.b().c[0]`, `null`},
+		{`let h = {"f": null}; h.f?.g(1)(2).x`, `null`},
+		{`let h = null; h?.a.b ?? "default"`, `default`},
+		{`let h = null; [h?.a.b, h?[0][0]]`, `[null, null]`},
+		{`let h = {"a": null}; h?.a.b`, `ERROR: member access not supported: NULL.b`},
+		{`let f = fn(x) { x }; f(null?.a).b`, `ERROR: member access not supported: NULL.b`},
+	}
+
+	for i, tt := range tests {
+		got := testEval(tt.input)
+		require.NotNil(t, got, "case %d, input=%s", i, tt.input)
+		require.Equal(t, tt.want, got.Inspect(), "case %d, input=%s", i, tt.input)
+	}
+}
+
+func TestDotAccessAndMethods(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`let h = {"a": 1}; h.a`, `1`},
+		{`let h = {"a": 1}; h.b`, `null`},
+		{`let h = {"a": {"b": [1, 2]}}; h.a.b[1]`, `2`},
+		{`[1, 2, 3].len()`, `3`},
+		{`"four".len()`, `4`},
+		{`[1, 2, 3].map(fn(x) { x * 2 })`, `[2, 4, 6]`},
+		{`[1, 2, 3, 4].filter(fn(x) { x > 2 })`, `[3, 4]`},
+		{`[1, 2, 3].reduce(0, fn(acc, x) { acc + x })`, `6`},
+		{`[1, 2, 3].map(fn(x) { x + 1 }).filter(fn(x) { x != 3 }).push(5)`, `[2, 4, 5]`},
+		{`[1, 2].first()`, `1`},
+		{`[1, 2].last()`, `2`},
+		{`[1, 2].rest()`, `[2]`},
+		{`[].first()`, `null`},
+		{`[1, "a", true].join(", ")`, `1, a, true`},
+		{`"Hello".upper()`, `HELLO`},
+		{`"Hello".lower()`, `hello`},
+		{`"  x ".trim()`, `x`},
+		{`"a,b".split(",")`, `[a, b]`},
+		{`"abc".contains("bc")`, `true`},
+		{`{"a": 1, "b": 2}.keys()`, `[a, b]`},
+		{`{"a": 1}.has("a")`, `true`},
+		{`let h = {"double": fn(x) { x * 2 }}; h.double(4)`, `8`},
+		{`let h = {"keys": fn() { 1 }}; h.keys()`, `[keys]`},
+		{`let h = null; h?.keys()`, `null`},
+		{`let h = null; h.keys()`, `ERROR: unknown method: NULL.keys`},
+		{`[1].nope()`, `ERROR: unknown method: ARRAY.nope`},
+		{`{"a": 1}.nope()`, `ERROR: unknown method: HASH.nope`},
+		{`{"a": 1}.a()`, `ERROR: not a function: INTEGER`},
+		{`[1].map(fn(x) { x + true })`, `ERROR: type mismatch: INTEGER + BOOLEAN`},
+		{`"a".split(1)`, "ERROR: argument to `split` must be STRING, got INTEGER"},
+		{`[1].map()`, `ERROR: wrong number of arguments. got=0, want=1`},
+		{`[1].len(1)`, `ERROR: wrong number of arguments. got=1, want=0`},
+		{`"ab".len(1, 2)`, `ERROR: wrong number of arguments. got=2, want=0`},
+		{`[1].push()`, `ERROR: wrong number of arguments. got=0, want=1`},
+		{`{}.keys(1)`, `ERROR: wrong number of arguments. got=1, want=0`},
+		{`{}.has()`, `ERROR: wrong number of arguments. got=0, want=1`},
+		{`{}.delete("a", "b")`, `ERROR: wrong number of arguments. got=2, want=1`},
+		{`{}.size(1)`, `ERROR: wrong number of arguments. got=1, want=0`},
+		{`{"a": 1}.merge({"b": 2}, {"a": 3})`, `{a: 3, b: 2}`},
+		{`{"a": 1}.merge()`, `{a: 1}`},
+		{`[1].len`, `ERROR: member access not supported: ARRAY.len`},
+	}
+
+	for i, tt := range tests {
+		got := testEval(tt.input)
+		require.NotNil(t, got, "case %d, input=%s", i, tt.input)
+		require.Equal(t, tt.want, got.Inspect(), "case %d, input=%s", i, tt.input)
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`try { 1 } catch (e) { 2 }`, `1`},
+		{`try { throw "boom"; 1 } catch (e) { e.message }`, `boom`},
+		{`try { throw {"code": 42} } catch (e) { e.value.code }`, `42`},
+		{`try { throw [1, 2] } catch (e) { e.message }`, `[1, 2]`},
+		{`try { 1 + true } catch (e) { e.message }`, `type mismatch: INTEGER + BOOLEAN`},
+		{`try { 1 + true } catch (e) { e.value }`, `null`},
+		{`try { {}[fn(x) { x }] } catch (e) { e.message }`, `unusable as hash key: FUNCTION`},
+		{`try { foobar } catch { "missing" }`, `missing`},
+		{`try {
+  1;
+     2 + "a"
+} catch (e) { [e.line, e.column] }`, `[3, 8]`},
+		{`let f = fn() { throw "x" };
+let g = fn() { f() };
+try { g() } catch (e) { e.stack }`, `[f (2:16), g (3:7)]`},
+		{`try { [1].map(fn(x) { x + true }) } catch (e) { e.stack }`, `[map (1:11)]`},
+		{`let x = try { throw 1 } catch (e) { e.value + 1 }; x`, `2`},
+		{`try { throw 1 } catch (e) { throw e.value + 1 }`, `ERROR: 2`},
+		{`try { try { throw 1 } catch (e) { throw 2 } } catch (e) { e.value }`, `2`},
+		{`try { throw 1 } finally { 5 }`, `ERROR: 1`},
+		{`try { 1 } finally { 5 }`, `1`},
+		{`try { throw 1 } catch (e) { 2 } finally { 3 }`, `2`},
+		{`try { 1 } finally { throw "fin" }`, `ERROR: fin`},
+		{`let f = fn() { try { return 1 } finally { 2 }; 3 }; f()`, `1`},
+		{`let f = fn() { try { 1 } finally { return 2 }; 3 }; f()`, `2`},
+		{`let log = []; let r = try { throw 1 } catch (e) { 2 } finally { let log = push(log, 3) }; [r, log]`, `[2, [3]]`},
+		{`try { throw 1 } catch (e) { 2 }; e`, `ERROR: identifier not found: e`},
+		{`throw "uncaught"; 5`, `ERROR: uncaught`},
+	}
+
+	for i, tt := range tests {
+		got := testEval(tt.input)
+		require.NotNil(t, got, "case %d, input=%s", i, tt.input)
+		require.Equal(t, tt.want, got.Inspect(), "case %d, input=%s", i, tt.input)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	input := `let f = fn(x) {
+  x / "a"
+};
+f(1)`
+	err := testutils.IsType[*object.Error](t, testEval(input))
+	require.Equal(t, "type mismatch: INTEGER / STRING", err.Msg)
+	require.Equal(t, token.Position{Line: 2, Column: 5}, err.Pos)
+	require.Equal(t, []object.Frame{{Function: "f", Pos: token.Position{Line: 4, Column: 1}}}, err.Stack)
+}
+
+func TestIOBuiltins(t *testing.T) {
+	tests := []struct {
+		input  string
+		stdin  string
+		want   string
+		stdout string
+		stderr string
+	}{
+		{`puts("a", 1, [2])`, "", `null`, "a\n1\n[2]\n", ""},
+		{`print("a", 1); print("b")`, "", `null`, "a 1b", ""},
+		{`eprint("oops", 1)`, "", `null`, "", "oops 1"},
+		{`[readLine(), readLine(), readLine()]`, "one\r\ntwo", `[one, two, null]`, "", ""},
+		{`readLine(1)`, "", `ERROR: wrong number of arguments. got=1, want=0`, "", ""},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		p := parser.FromInput(tt.input)
+		program := p.ParseProgram()
+		require.Empty(t, p.Errors(), tt.input)
+		in := New()
+		in.Allow(CapIO)
+		in.Stdout = &stdout
+		in.Stderr = &stderr
+		in.Stdin = strings.NewReader(tt.stdin)
+		got := in.Eval(program, object.NewEnv())
+		require.Equal(t, tt.want, got.Inspect(), tt.input)
+		require.Equal(t, tt.stdout, stdout.String(), tt.input)
+		require.Equal(t, tt.stderr, stderr.String(), tt.input)
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`exit(); 1`, `exit 0`},
+		{`let f = fn() { exit(3); 1 }; f(); 2`, `exit 3`},
+		{`try { exit(4) } catch (e) { 1 }`, `exit 4`},
+		{`[1].map(fn(x) { exit(5) })`, `exit 5`},
+		{`exit("1")`, "ERROR: argument to `exit` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		p := parser.FromInput(tt.input)
+		program := p.ParseProgram()
+		require.Empty(t, p.Errors(), tt.input)
+		in := New()
+		in.Allow(CapProcess)
+		got := in.Eval(program, object.NewEnv())
+		require.Equal(t, tt.want, got.Inspect(), tt.input)
+	}
+}
@@ -0,0 +1,44 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/testutils"
+)
+
+// budget is a Hook stopping the program after a number of steps, so that
+// fuzzed programs terminate.
+type budget struct {
+	steps int
+}
+
+func (b *budget) Before(ast.Node, *object.Environment) object.Object {
+	if b.steps--; b.steps < 0 {
+		return &object.Exit{Code: 1}
+	}
+	return nil
+}
+
+func (b *budget) Enter(object.Object, []object.Object) {}
+func (b *budget) Leave(object.Object, object.Object)   {}
+
+// FuzzEval checks that evaluating programs that parse never panics. The
+// programs run without capabilities and for at most 10000 steps.
+func FuzzEval(f *testing.F) {
+	testutils.AddSeeds(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.FromInput(input)
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return
+		}
+		in := New()
+		in.Hook = &budget{steps: 10000}
+		if res := in.Eval(program, object.NewEnv()); res != nil {
+			_ = res.Inspect()
+		}
+	})
+}
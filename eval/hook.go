@@ -0,0 +1,20 @@
+package eval
+
+import (
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/object"
+)
+
+// Hook observes evaluation, for debuggers and profilers. Set it on an
+// Interpreter before evaluating; it is called from the goroutine evaluating.
+type Hook interface {
+	// Before is called before each statement and expression is evaluated,
+	// with the environment it is evaluated in. Returning a non-nil object
+	// skips node, which evaluates to that object instead; an *object.Exit
+	// stops the program.
+	Before(node ast.Node, env *object.Environment) object.Object
+	// Enter is called when fn, a function or builtin, is called with args,
+	// and Leave when it returns result.
+	Enter(fn object.Object, args []object.Object)
+	Leave(fn object.Object, result object.Object)
+}
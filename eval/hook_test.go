@@ -0,0 +1,112 @@
+package eval
+
+import (
+	"fmt"
+	"testing"
+	"testing/fstest"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder records the statements evaluated and the calls made.
+type recorder struct {
+	events []string
+	// stopAt makes the statement on that line exit the program.
+	stopAt int
+}
+
+func (r *recorder) Before(node ast.Node, env *object.Environment) object.Object {
+	if _, ok := node.(*ast.LetStatement); ok {
+		r.events = append(r.events, fmt.Sprintf("let %d", node.Pos().Line))
+	}
+	if _, ok := node.(*ast.Identifier); ok && node.Pos().Line == r.stopAt {
+		return &object.Exit{Code: 7}
+	}
+	return nil
+}
+
+func (r *recorder) Enter(fn object.Object, args []object.Object) {
+	r.events = append(r.events, fmt.Sprintf("enter %s %d", functionName(fn), len(args)))
+}
+
+func (r *recorder) Leave(fn object.Object, result object.Object) {
+	r.events = append(r.events, fmt.Sprintf("leave %s %s", functionName(fn), result.Inspect()))
+}
+
+func TestHook(t *testing.T) {
+	input := `let f = fn(x) {
+  let y = x * 2;
+  y
+};
+let z = [1].map(f);
+`
+	program := parser.FromInput(input).ParseProgram()
+
+	r := &recorder{}
+	in := New()
+	in.Hook = r
+	in.Eval(program, object.NewEnv())
+	require.Equal(t, []string{
+		"let 1",
+		"let 5",
+		"enter array.map 2",
+		"enter f 1",
+		"let 2",
+		"leave f 2",
+		"leave array.map [2]",
+	}, r.events)
+
+	r = &recorder{stopAt: 2}
+	in.Hook = r
+	res := in.Eval(program, object.NewEnv())
+	require.Equal(t, &object.Exit{Code: 7}, res)
+	require.Equal(t, []string{"let 1", "let 5", "enter array.map 2", "enter f 1", "let 2", "leave f exit 7", "leave array.map exit 7"}, r.events)
+}
+
+// builtinNames records the names of the builtins called.
+type builtinNames struct{ names []string }
+
+func (b *builtinNames) Before(ast.Node, *object.Environment) object.Object { return nil }
+
+func (b *builtinNames) Enter(fn object.Object, _ []object.Object) {
+	if fn, ok := fn.(*object.Builtin); ok {
+		b.names = append(b.names, BuiltinName(fn))
+	}
+}
+
+func (b *builtinNames) Leave(object.Object, object.Object) {}
+
+func TestBuiltinName(t *testing.T) {
+	input := `len([1].map(fn(x) { x }));
+"a b".split(" ");
+json.parse("1");
+`
+	program := parser.FromInput(input).ParseProgram()
+	b := &builtinNames{}
+	in := New()
+	in.Hook = b
+	in.Eval(program, object.NewEnv())
+	require.Equal(t, []string{"array.map", "len", "string.split", "json.parse"}, b.names)
+	require.Equal(t, "", BuiltinName(&object.Builtin{}))
+}
+
+func TestFunctionPosition(t *testing.T) {
+	input := `let f = fn(x) {
+  fn() { x }
+};
+f(1)
+`
+	program := parser.FromInput(input).ParseProgram()
+	in := New()
+	in.FS = fstest.MapFS{"main.mnk": {Data: []byte(input)}}
+	res := in.EvalFile("main.mnk", program, object.NewEnv())
+	fn := res.(*object.Function)
+	require.Equal(t, "main.mnk", fn.File)
+	require.Equal(t, "2:3", fn.Pos.String())
+
+	res = in.Eval(program, object.NewEnv())
+	require.Equal(t, "", res.(*object.Function).File)
+}
@@ -0,0 +1,125 @@
+package eval
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/object"
+)
+
+// Interpreter evaluates programs. It holds the state shared by every module
+// of a program, so use a single Interpreter per program run.
+type Interpreter struct {
+	// FS is used for all file access: imports, readFile and listDir. Paths
+	// are slash separated and relative to the root of FS. With a nil FS no
+	// file can be read.
+	FS fs.FS
+	// SearchPath lists directories of FS searched for modules not found
+	// relative to the importing file.
+	SearchPath []string
+	// Stdout, Stderr and Stdin are the standard streams of programs run by
+	// the Interpreter. New sets them to the streams of the process.
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+	// Hook, if not nil, observes evaluation.
+	Hook Hook
+	// Optimize, if not nil, rewrites the programs of imported modules before
+	// they are evaluated, see optimizer.Optimize.
+	Optimize func(*ast.Program) *ast.Program
+
+	// stdin buffers Stdin for readLine.
+	stdin   *bufio.Reader
+	granted map[Capability]bool
+	modules map[string]*object.Module
+	// files is the stack of files being evaluated, innermost last.
+	files []string
+	// file is the file of the code being evaluated: that of the function
+	// called last, or of the program.
+	file string
+}
+
+// New returns an Interpreter without capabilities, see Allow.
+func New() *Interpreter {
+	return &Interpreter{
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+		Stdin:   os.Stdin,
+		granted: make(map[Capability]bool),
+		modules: make(map[string]*object.Module),
+	}
+}
+
+// Eval evaluates node with a fresh Interpreter, which has no filesystem.
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+func (in *Interpreter) Eval(node ast.Node, env *object.Environment) object.Object {
+	if in.Hook != nil {
+		if res := in.Hook.Before(node, env); res != nil {
+			return res
+		}
+	}
+	res := in.evalNode(node, env)
+	if err, ok := res.(*object.Error); ok && !err.Pos.IsValid() {
+		err.File, err.Pos = in.file, node.Pos()
+	}
+	return res
+}
+
+// EvalFile evaluates program, parsed from file in FS. Imports in program
+// are resolved relative to the directory of file.
+func (in *Interpreter) EvalFile(file string, program *ast.Program, env *object.Environment) object.Object {
+	in.files = append(in.files, path.Clean(file))
+	outer := in.file
+	in.file = path.Clean(file)
+	defer func() {
+		in.files = in.files[:len(in.files)-1]
+		in.file = outer
+	}()
+	return in.Eval(program, env)
+}
+
+// File returns the file in FS of the code being evaluated, as hooks see it,
+// or "" for code not evaluated from a file.
+func (in *Interpreter) File() string {
+	return in.file
+}
+
+// Apply implements object.Context.
+func (in *Interpreter) Apply(fn object.Object, args ...object.Object) object.Object {
+	return in.applyfunction(fn, args)
+}
+
+// FileSystem implements object.Context.
+func (in *Interpreter) FileSystem() fs.FS {
+	return in.FS
+}
+
+// Output implements object.Context.
+func (in *Interpreter) Output() io.Writer {
+	return in.Stdout
+}
+
+// ErrOutput implements object.Context.
+func (in *Interpreter) ErrOutput() io.Writer {
+	return in.Stderr
+}
+
+// Input implements object.Context. Stdin is wrapped in a bufio.Reader the
+// first time it is read, unless it is one already.
+func (in *Interpreter) Input() *bufio.Reader {
+	if in.stdin == nil {
+		if r, ok := in.Stdin.(*bufio.Reader); ok {
+			in.stdin = r
+		} else {
+			in.stdin = bufio.NewReader(in.Stdin)
+		}
+	}
+	return in.stdin
+}
@@ -0,0 +1,211 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/EmilLaursen/wiig/object"
+)
+
+// builtinModules are namespaces of builtins, available like imported
+// modules, e.g. `json.parse(s)`.
+var builtinModules = map[string]*object.Module{
+	"json": newBuiltinModule("json", map[string]*object.Builtin{
+		"parse": {
+			MinArgs:   1,
+			MaxArgs:   1,
+			Signature: "json.parse(string)",
+			Doc:       "Returns the value of a JSON document, objects becoming hashes.",
+			Fn:        jsonParse,
+		},
+		"stringify": {
+			MinArgs:   1,
+			MaxArgs:   2,
+			Signature: "json.stringify(value, indent?)",
+			Doc:       "Returns value as JSON, nested values indented by indent, a number of spaces or a string.",
+			Fn:        jsonStringify,
+		},
+	}),
+}
+
+func newBuiltinModule(name string, fns map[string]*object.Builtin) *object.Module {
+	names := make([]string, 0, len(fns))
+	for fnName := range fns {
+		names = append(names, fnName)
+	}
+	sort.Strings(names)
+	exports := object.NewHash()
+	for _, fnName := range names {
+		exports.Set(&object.String{Value: fnName}, fns[fnName])
+	}
+	return &object.Module{Path: name, Exports: exports}
+}
+
+// jsonParse is `json.parse(str)`. Objects become hashes keeping the order of
+// their keys, and numbers must be integers.
+func jsonParse(_ object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newErr("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newErr("argument to `json.parse` must be STRING, got %s", args[0].Type())
+	}
+
+	dec := json.NewDecoder(strings.NewReader(str.Value))
+	dec.UseNumber()
+	val, err := decodeJSON(dec)
+	if err == nil {
+		if _, err = dec.Token(); err == io.EOF {
+			return val
+		} else if err == nil {
+			err = errors.New("unexpected data after top-level value")
+		}
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return newErr("json.parse: %s", err)
+}
+
+func decodeJSON(dec *json.Decoder) (object.Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		if tok == '[' {
+			elems := []object.Object{}
+			for dec.More() {
+				e, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				elems = append(elems, e)
+			}
+			_, err := dec.Token()
+			return &object.Array{Elems: elems}, err
+		}
+
+		hash := object.NewHash()
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			val, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(&object.String{Value: key.(string)}, val)
+		}
+		_, err := dec.Token()
+		return hash, err
+	case json.Number:
+		n, err := strconv.ParseInt(string(tok), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("number %s is not an integer", tok)
+		}
+		return &object.Integer{Value: n}, nil
+	case string:
+		return &object.String{Value: tok}, nil
+	case bool:
+		return nativeBoolToBoolObj(tok), nil
+	default:
+		return NULL, nil
+	}
+}
+
+// jsonStringify is `json.stringify(value, indent?)`, where indent is the
+// number of spaces, or the string, to indent nested values with.
+func jsonStringify(_ object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newErr("wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	var buf bytes.Buffer
+	if err := encodeJSON(&buf, args[0]); err != nil {
+		return newErr("json.stringify: %s", err)
+	}
+	if len(args) == 1 {
+		return &object.String{Value: buf.String()}
+	}
+
+	var indent string
+	switch arg := args[1].(type) {
+	case *object.Integer:
+		indent = strings.Repeat(" ", int(max(arg.Value, 0)))
+	case *object.String:
+		indent = arg.Value
+	default:
+		return newErr("argument to `json.stringify` must be INTEGER or STRING, got %s", args[1].Type())
+	}
+	// json.Indent would put each value on a line of its own.
+	if indent == "" {
+		return &object.String{Value: buf.String()}
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", indent); err != nil {
+		return newErr("json.stringify: %s", err)
+	}
+	return &object.String{Value: out.String()}
+}
+
+func encodeJSON(buf *bytes.Buffer, obj object.Object) error {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		buf.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Boolean:
+		buf.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Null:
+		buf.WriteString("null")
+	case *object.String:
+		encodeJSONString(buf, obj.Value)
+	case *object.Array:
+		buf.WriteByte('[')
+		for i, e := range obj.Elems {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encodeJSON(buf, e); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case *object.Hash:
+		buf.WriteByte('{')
+		for i, pair := range obj.Pairs() {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return fmt.Errorf("hash key must be STRING, got %s", pair.Key.Type())
+			}
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			encodeJSONString(buf, key.Value)
+			buf.WriteByte(':')
+			if err := encodeJSON(buf, pair.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot encode %s", obj.Type())
+	}
+	return nil
+}
+
+func encodeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)               // strings always encode
+	buf.Truncate(buf.Len() - 1) // Encode appends a newline
+}
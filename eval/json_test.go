@@ -0,0 +1,52 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	// String literals have no escapes, so JSON text is bound to `s`.
+	tests := []struct {
+		s     string
+		input string
+		want  string
+	}{
+		{`{"b": [1, true, null], "a": {"x": "y"}}`, `json.parse(s)`, `{b: [1, true, null], a: {x: y}}`},
+		{`[]`, `json.parse(s)`, `[]`},
+		{` 42 `, `json.parse(s)`, `42`},
+		{`{"a": 1}`, `json.parse(s).a`, `1`},
+		{`1.5`, `json.parse(s)`, `ERROR: json.parse: number 1.5 is not an integer`},
+		{`[1,`, `json.parse(s)`, `ERROR: json.parse: unexpected end of JSON input`},
+		{``, `json.parse(s)`, `ERROR: json.parse: unexpected EOF`},
+		{`1 2`, `json.parse(s)`, `ERROR: json.parse: unexpected data after top-level value`},
+		{``, `json.parse(1)`, "ERROR: argument to `json.parse` must be STRING, got INTEGER"},
+		{``, `json.stringify({"b": [1, true, null], "a": "x"})`, `{"b":[1,true,null],"a":"x"}`},
+		{`<"q">`, `json.stringify(s)`, `"<\"q\">"`},
+		{``, `json.stringify({1: 2})`, `ERROR: json.stringify: hash key must be STRING, got INTEGER`},
+		{``, `json.stringify([fn(x) { x }])`, `ERROR: json.stringify: cannot encode FUNCTION`},
+		{``, `json.stringify(len)`, `ERROR: json.stringify: cannot encode BUILTIN`},
+		{``, `json.stringify([1, {"a": []}], 2)`, "[\n  1,\n  {\n    \"a\": []\n  }\n]"},
+		{"\t", `json.stringify({"a": 1}, s)`, "{\n\t\"a\": 1\n}"},
+		{``, `json.stringify([1, {"a": []}], 0)`, `[1,{"a":[]}]`},
+		{``, `json.stringify([1, {"a": []}], -2)`, `[1,{"a":[]}]`},
+		{``, `json.stringify([1, {"a": []}], s)`, `[1,{"a":[]}]`},
+		{``, `json.stringify(1, true)`, "ERROR: argument to `json.stringify` must be INTEGER or STRING, got BOOLEAN"},
+		{`{"z":1,"a":[2,{"m":null}],"s":"x"}`, `[json.stringify(json.parse(s))] == [s]`, `true`},
+		{``, `json`, `module json`},
+	}
+
+	for _, tt := range tests {
+		p := parser.FromInput(tt.input)
+		program := p.ParseProgram()
+		require.Empty(t, p.Errors(), tt.input)
+		env := object.NewEnv()
+		env.Set("s", &object.String{Value: tt.s})
+		got := Eval(program, env)
+		require.NotNil(t, got, tt.input)
+		require.Equal(t, tt.want, got.Inspect(), tt.input)
+	}
+}
@@ -0,0 +1,201 @@
+package eval
+
+import (
+	"strings"
+
+	"github.com/EmilLaursen/wiig/object"
+)
+
+// methods holds the builtins callable as `value.name(args)`, per type of
+// value. The receiver is passed as the first argument.
+var methods = map[object.ObjectType]map[string]*object.Builtin{
+	object.ARRAY_OBJ: {
+		"len":    builtinMethod("len", 0),
+		"push":   builtinMethod("push", 1),
+		"first":  {Fn: arrayFirst},
+		"last":   {Fn: arrayLast},
+		"rest":   {Fn: arrayRest},
+		"map":    {Fn: arrayMap},
+		"filter": {Fn: arrayFilter},
+		"reduce": {Fn: arrayReduce},
+		"join":   {Fn: arrayJoin},
+	},
+	object.STRING_OBJ: {
+		"len":      builtinMethod("len", 0),
+		"upper":    {Fn: stringUpper},
+		"lower":    {Fn: stringLower},
+		"trim":     {Fn: stringTrim},
+		"split":    {Fn: stringSplit},
+		"contains": {Fn: stringContains},
+	},
+	object.HASH_OBJ: {
+		"keys":   builtinMethod("keys", 0),
+		"values": builtinMethod("values", 0),
+		"items":  builtinMethod("items", 0),
+		"has":    builtinMethod("has", 1),
+		"delete": builtinMethod("delete", 1),
+		"merge":  builtinMethod("merge", -1),
+		"size":   builtinMethod("size", 0),
+	},
+}
+
+// builtinMethod makes the builtin name a method taking arity arguments
+// besides the receiver, or any number if arity is -1. The arguments are
+// checked here, for errors to count only those given explicitly.
+func builtinMethod(name string, arity int) *object.Builtin {
+	fn := builtins[name].Fn
+	return &object.Builtin{Fn: func(ctx object.Context, args ...object.Object) object.Object {
+		if arity >= 0 && len(args) != arity+1 {
+			return newErr("wrong number of arguments. got=%d, want=%d", len(args)-1, arity)
+		}
+		return fn(ctx, args...)
+	}}
+}
+
+func arrayFirst(_ object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newErr("wrong number of arguments. got=%d, want=0", len(args)-1)
+	}
+	elems := args[0].(*object.Array).Elems
+	if len(elems) == 0 {
+		return NULL
+	}
+	return elems[0]
+}
+
+func arrayLast(_ object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newErr("wrong number of arguments. got=%d, want=0", len(args)-1)
+	}
+	elems := args[0].(*object.Array).Elems
+	if len(elems) == 0 {
+		return NULL
+	}
+	return elems[len(elems)-1]
+}
+
+func arrayRest(_ object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newErr("wrong number of arguments. got=%d, want=0", len(args)-1)
+	}
+	elems := args[0].(*object.Array).Elems
+	if len(elems) == 0 {
+		return NULL
+	}
+	rest := make([]object.Object, len(elems)-1)
+	copy(rest, elems[1:])
+	return &object.Array{Elems: rest}
+}
+
+func arrayMap(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newErr("wrong number of arguments. got=%d, want=1", len(args)-1)
+	}
+	elems := args[0].(*object.Array).Elems
+	res := make([]object.Object, len(elems))
+	for i, e := range elems {
+		r := ctx.Apply(args[1], e)
+		if isError(r) {
+			return r
+		}
+		res[i] = r
+	}
+	return &object.Array{Elems: res}
+}
+
+func arrayFilter(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newErr("wrong number of arguments. got=%d, want=1", len(args)-1)
+	}
+	res := []object.Object{}
+	for _, e := range args[0].(*object.Array).Elems {
+		r := ctx.Apply(args[1], e)
+		if isError(r) {
+			return r
+		}
+		if isTruthy(r) {
+			res = append(res, e)
+		}
+	}
+	return &object.Array{Elems: res}
+}
+
+// arrayReduce is `arr.reduce(initial, f)`, with arguments in the same order
+// as fold in examples/map_reduce.mnk.
+func arrayReduce(ctx object.Context, args ...object.Object) object.Object {
+	if len(args) != 3 {
+		return newErr("wrong number of arguments. got=%d, want=2", len(args)-1)
+	}
+	acc := args[1]
+	for _, e := range args[0].(*object.Array).Elems {
+		acc = ctx.Apply(args[2], acc, e)
+		if isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+func arrayJoin(_ object.Context, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newErr("wrong number of arguments. got=%d, want=1", len(args)-1)
+	}
+	sep, ok := args[1].(*object.String)
+	if !ok {
+		return newErr("argument to `join` must be STRING, got %s", args[1].Type())
+	}
+	elems := args[0].(*object.Array).Elems
+	strs := make([]string, len(elems))
+	for i, e := range elems {
+		strs[i] = e.Inspect()
+	}
+	return &object.String{Value: strings.Join(strs, sep.Value)}
+}
+
+func stringUpper(_ object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newErr("wrong number of arguments. got=%d, want=0", len(args)-1)
+	}
+	return &object.String{Value: strings.ToUpper(args[0].(*object.String).Value)}
+}
+
+func stringLower(_ object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newErr("wrong number of arguments. got=%d, want=0", len(args)-1)
+	}
+	return &object.String{Value: strings.ToLower(args[0].(*object.String).Value)}
+}
+
+func stringTrim(_ object.Context, args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newErr("wrong number of arguments. got=%d, want=0", len(args)-1)
+	}
+	return &object.String{Value: strings.TrimSpace(args[0].(*object.String).Value)}
+}
+
+func stringSplit(_ object.Context, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newErr("wrong number of arguments. got=%d, want=1", len(args)-1)
+	}
+	sep, ok := args[1].(*object.String)
+	if !ok {
+		return newErr("argument to `split` must be STRING, got %s", args[1].Type())
+	}
+	parts := strings.Split(args[0].(*object.String).Value, sep.Value)
+	elems := make([]object.Object, len(parts))
+	for i, p := range parts {
+		elems[i] = &object.String{Value: p}
+	}
+	return &object.Array{Elems: elems}
+}
+
+func stringContains(_ object.Context, args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newErr("wrong number of arguments. got=%d, want=1", len(args)-1)
+	}
+	sub, ok := args[1].(*object.String)
+	if !ok {
+		return newErr("argument to `contains` must be STRING, got %s", args[1].Type())
+	}
+	return nativeBoolToBoolObj(strings.Contains(args[0].(*object.String).Value, sub.Value))
+}
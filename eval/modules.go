@@ -0,0 +1,98 @@
+package eval
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+)
+
+func (in *Interpreter) evalImport(node *ast.ImportStatement, env *object.Environment) object.Object {
+	mod := in.importModule(node.Path.Value)
+	if isError(mod) {
+		return mod
+	}
+	define(env, node.Name, mod)
+	return nil
+}
+
+// importModule loads, evaluates and caches the module at name. Each module
+// is evaluated once per Interpreter.
+func (in *Interpreter) importModule(name string) object.Object {
+	file, err := in.resolveModule(name)
+	if err != nil {
+		return newErr("import %q: %s", name, err)
+	}
+
+	if mod, ok := in.modules[file]; ok {
+		return mod
+	}
+	for i, f := range in.files {
+		if f == file {
+			cycle := append(append([]string{}, in.files[i:]...), file)
+			return newErr("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	data, err := fs.ReadFile(in.FS, file)
+	if err != nil {
+		return newErr("import %q: %s", name, err)
+	}
+	p := parser.FromInput(string(data))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return newErr("import %q: parse errors: %s", name, strings.Join(p.Errors(), "; "))
+	}
+	if in.Optimize != nil {
+		program = in.Optimize(program)
+	}
+
+	env := object.NewEnv()
+	if res := in.EvalFile(file, program, env); isError(res) {
+		return res
+	}
+
+	exports := object.NewHash()
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			name := export.Let.Name.Value
+			val, _ := env.Get(name)
+			exports.Set(&object.String{Value: name}, val)
+		}
+	}
+
+	mod := &object.Module{Path: file, Exports: exports}
+	in.modules[file] = mod
+	return mod
+}
+
+var errNoFS = errors.New("no filesystem available")
+
+// resolveModule finds the file in the filesystem of the interpreter for name,
+// first relative to the directory of the file being evaluated, then in each
+// directory of the search path.
+func (in *Interpreter) resolveModule(name string) (string, error) {
+	if in.FS == nil {
+		return "", errNoFS
+	}
+
+	dir := "."
+	if len(in.files) > 0 {
+		dir = path.Dir(in.files[len(in.files)-1])
+	}
+
+	for _, d := range append([]string{dir}, in.SearchPath...) {
+		file := path.Join(d, name)
+		if !fs.ValidPath(file) {
+			continue
+		}
+		if info, err := fs.Stat(in.FS, file); err == nil && !info.IsDir() {
+			return file, nil
+		}
+	}
+	return "", errors.New("module not found")
+}
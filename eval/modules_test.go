@@ -0,0 +1,152 @@
+package eval
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+func mapFS(files map[string]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	return fsys
+}
+
+func testEvalFile(t *testing.T, in *Interpreter, file string) object.Object {
+	t.Helper()
+	data, err := fs.ReadFile(in.FS, file)
+	require.NoError(t, err)
+	p := parser.FromInput(string(data))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors())
+	return in.EvalFile(file, program, object.NewEnv())
+}
+
+func TestImports(t *testing.T) {
+	fsys := mapFS(map[string]string{
+		"lib/collections.mnk": `
+import "util.mnk" as u;
+export let map = fn(arr, f) { arr.map(f) };
+export let twice = fn(x) { u.double(x) };
+let hidden = 1;
+`,
+		"lib/util.mnk": `export let double = fn(x) { x * 2 };`,
+		"other.mnk": `
+import "lib/util.mnk" as u;
+export let double = u.double;
+`,
+		"path/found.mnk":  `export let x = "from search path";`,
+		"cycle/a.mnk":     `import "b.mnk" as b;`,
+		"cycle/b.mnk":     `import "a.mnk" as a;`,
+		"broken.mnk":      `let = ;`,
+		"failing.mnk":     `export let x = 1 + true;`,
+		"main.mnk":        `import "lib/collections.mnk" as c; c.map([1, 2], c.twice)`,
+		"hidden.mnk":      `import "lib/collections.mnk" as c; c.hidden`,
+		"cached.mnk":      `import "lib/util.mnk" as u; import "other.mnk" as o; [u.double] == [o.double]`,
+		"search.mnk":      `import "found.mnk" as f; f.x`,
+		"missing.mnk":     `import "nope.mnk" as n;`,
+		"cyclic.mnk":      `import "cycle/a.mnk" as a;`,
+		"parse_error.mnk": `import "broken.mnk" as b;`,
+		"eval_error.mnk":  `import "failing.mnk" as f;`,
+		"inspect.mnk":     `import "lib/util.mnk" as u; u`,
+	})
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"main.mnk", `[2, 4]`},
+		{"hidden.mnk", `ERROR: module lib/collections.mnk has no export hidden`},
+		{"cached.mnk", `true`},
+		{"search.mnk", `from search path`},
+		{"missing.mnk", `ERROR: import "nope.mnk": module not found`},
+		{"cyclic.mnk", `ERROR: import cycle: cycle/a.mnk -> cycle/b.mnk -> cycle/a.mnk`},
+		{"parse_error.mnk", `ERROR: import "broken.mnk": parse errors: expected next token to be IDENT, got = instead; no prefix parse function for = found`},
+		{"eval_error.mnk", `ERROR: type mismatch: INTEGER + BOOLEAN`},
+		{"inspect.mnk", `module lib/util.mnk`},
+	}
+
+	for _, tt := range tests {
+		in := New()
+		in.FS = fsys
+		in.SearchPath = []string{"path"}
+		got := testEvalFile(t, in, tt.file)
+		require.NotNil(t, got, tt.file)
+		require.Equal(t, tt.want, got.Inspect(), tt.file)
+	}
+}
+
+func TestModulesEvaluateOnce(t *testing.T) {
+	in := New()
+	in.FS = mapFS(map[string]string{
+		"counter.mnk": `export let id = fn(x) { x };`,
+		"main.mnk":    `import "counter.mnk" as a; a`,
+	})
+	first := testEvalFile(t, in, "main.mnk")
+	second := testEvalFile(t, in, "main.mnk")
+	require.IsType(t, &object.Module{}, first)
+	require.Same(t, first, second)
+}
+
+func TestErrorFile(t *testing.T) {
+	in := New()
+	in.FS = mapFS(map[string]string{
+		"lib/m.mnk": "export let half = fn(x) {\n  x / 0\n};",
+		"main.mnk":  "import \"lib/m.mnk\" as m;\nm.half(1)",
+	})
+	err := testutils.IsType[*object.Error](t, testEvalFile(t, in, "main.mnk"))
+	require.Equal(t, "lib/m.mnk", err.File)
+	require.Equal(t, "2:5", err.Pos.String())
+	require.Equal(t, []object.Frame{{Function: "half", File: "main.mnk", Pos: err.Stack[0].Pos}}, err.Stack)
+	require.Equal(t, "half (main.mnk:2:3)", err.Stack[0].String())
+
+	in = New()
+	in.FS = mapFS(map[string]string{
+		"lib/m.mnk": "export let bad = 1 + true;",
+		"top.mnk":   "\nimport \"lib/m.mnk\" as m;",
+	})
+	err = testutils.IsType[*object.Error](t, testEvalFile(t, in, "top.mnk"))
+	require.Equal(t, "lib/m.mnk", err.File)
+	require.Equal(t, "1:20", err.Pos.String())
+}
+
+func TestFileBuiltins(t *testing.T) {
+	fsys := mapFS(map[string]string{
+		"data/hello.txt": "hello",
+		"data/b.txt":     "",
+		"data/sub/c.txt": "",
+	})
+
+	tests := []struct {
+		input string
+		fsys  fs.FS
+		want  string
+	}{
+		{`readFile("data/hello.txt")`, fsys, `hello`},
+		{`listDir("data")`, fsys, `[b.txt, hello.txt, sub]`},
+		{`listDir(".")`, fsys, `[data]`},
+		{`readFile("missing.txt")`, fsys, `ERROR: readFile: open missing.txt: file does not exist`},
+		{`readFile("../etc/passwd")`, fsys, `ERROR: readFile: open ../etc/passwd: file does not exist`},
+		{`readFile(1)`, fsys, "ERROR: argument to `readFile` must be STRING, got INTEGER"},
+		{`readFile("data/hello.txt")`, nil, `ERROR: readFile: no filesystem available`},
+		{`listDir("data")`, nil, `ERROR: listDir: no filesystem available`},
+		{`import "data/x.mnk" as x;`, nil, `ERROR: import "data/x.mnk": no filesystem available`},
+	}
+
+	for _, tt := range tests {
+		p := parser.FromInput(tt.input)
+		program := p.ParseProgram()
+		require.Empty(t, p.Errors())
+		in := New()
+		in.FS = tt.fsys
+		in.Allow(CapFS)
+		got := in.Eval(program, object.NewEnv())
+		require.Equal(t, tt.want, got.Inspect(), tt.input)
+	}
+}
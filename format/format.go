@@ -0,0 +1,268 @@
+// Package format formats Monkey programs in a canonical style: four space
+// indentation, one statement per line, and parentheses only where needed.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/parser"
+)
+
+// maxInline is the length up to which a block of a single expression is
+// kept on one line, as in `fn(x) { x * 2 }`.
+const maxInline = 60
+
+// Source formats the program src.
+func Source(src string) (string, error) {
+	p := parser.FromInput(src)
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		return "", errs[0]
+	}
+	return Program(program), nil
+}
+
+// Program formats program, which must be free of parse errors. Single
+// blank lines between statements are kept.
+func Program(program *ast.Program) string {
+	var f formatter
+	f.statements(program.Statements, false)
+	return f.buf.String()
+}
+
+// Node formats a single statement or expression.
+func Node(node ast.Node) string {
+	var f formatter
+	switch n := node.(type) {
+	case *ast.BlockStatement:
+		f.block(n)
+	case ast.Statement:
+		f.statement(n)
+	case ast.Expression:
+		f.expr(n, parser.LOWEST)
+	}
+	return f.buf.String()
+}
+
+type formatter struct {
+	buf    bytes.Buffer
+	indent int
+}
+
+func (f *formatter) newline() {
+	f.buf.WriteByte('\n')
+	f.buf.WriteString(strings.Repeat("    ", f.indent))
+}
+
+// statements writes stmts one per line. In a block the value of a final
+// expression statement is written without semicolon.
+func (f *formatter) statements(stmts []ast.Statement, block bool) {
+	for i, stmt := range stmts {
+		if i > 0 {
+			if stmt.Pos().Line > endLine(stmts[i-1])+1 {
+				f.buf.WriteByte('\n')
+			}
+			f.newline()
+		}
+		f.statement(stmt)
+		if _, isExpr := stmt.(*ast.ExpressionStatement); !block || !isExpr || i < len(stmts)-1 {
+			f.buf.WriteByte(';')
+		}
+	}
+	if !block && len(stmts) > 0 {
+		f.buf.WriteByte('\n')
+	}
+}
+
+func (f *formatter) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.LetStatement:
+		f.buf.WriteString("let " + s.Name.Value)
+		if s.Type != nil {
+			f.buf.WriteString(": " + s.Type.String())
+		}
+		f.buf.WriteString(" = ")
+		f.expr(s.Value, parser.LOWEST)
+	case *ast.ExportStatement:
+		f.buf.WriteString("export ")
+		f.statement(s.Let)
+	case *ast.ReturnStatement:
+		f.buf.WriteString("return ")
+		f.expr(s.ReturnValue, parser.LOWEST)
+	case *ast.ThrowStatement:
+		f.buf.WriteString("throw ")
+		f.expr(s.Value, parser.LOWEST)
+	case *ast.ImportStatement:
+		fmt.Fprintf(&f.buf, "import \"%s\" as %s", s.Path.Value, s.Name.Value)
+	case *ast.ExpressionStatement:
+		f.expr(s.Expression, parser.LOWEST)
+	}
+}
+
+func (f *formatter) block(b *ast.BlockStatement) {
+	if len(b.Statements) == 0 {
+		f.buf.WriteString("{}")
+		return
+	}
+	if len(b.Statements) == 1 {
+		if s, ok := b.Statements[0].(*ast.ExpressionStatement); ok {
+			var inline formatter
+			inline.expr(s.Expression, parser.LOWEST)
+			if str := inline.buf.String(); len(str) <= maxInline && !strings.Contains(str, "\n") {
+				f.buf.WriteString("{ " + str + " }")
+				return
+			}
+		}
+	}
+
+	f.buf.WriteByte('{')
+	f.indent++
+	f.newline()
+	f.statements(b.Statements, true)
+	f.indent--
+	f.newline()
+	f.buf.WriteByte('}')
+}
+
+// precedence is the binding strength of exp, as in the parser. Literals and
+// compound expressions like `if` never need parentheses.
+func precedence(exp ast.Expression) parser.Precedence {
+	switch e := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.PrecedenceOf(e.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression, *ast.SliceExpression, *ast.MemberExpression:
+		return parser.INDEX
+	default:
+		return parser.INDEX + 1
+	}
+}
+
+// expr writes exp, in parentheses if it binds weaker than prec.
+func (f *formatter) expr(exp ast.Expression, prec parser.Precedence) {
+	if precedence(exp) < prec {
+		f.buf.WriteByte('(')
+		defer f.buf.WriteByte(')')
+	}
+
+	switch e := exp.(type) {
+	case *ast.Identifier:
+		f.buf.WriteString(e.Value)
+	case *ast.IntegerLiteral, *ast.Boolean, *ast.Null:
+		f.buf.WriteString(e.TokenLiteral())
+	case *ast.StringLiteral:
+		f.buf.WriteString(`"` + e.Value + `"`)
+	case *ast.PrefixExpression:
+		f.buf.WriteString(e.Operator)
+		f.expr(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		p := precedence(e)
+		f.expr(e.Left, p)
+		f.buf.WriteString(" " + e.Operator + " ")
+		f.expr(e.Right, p+1)
+	case *ast.ArrayLiteral:
+		f.buf.WriteByte('[')
+		f.exprs(e.Elems)
+		f.buf.WriteByte(']')
+	case *ast.HashLiteral:
+		f.buf.WriteByte('{')
+		for i, k := range e.Keys {
+			if i > 0 {
+				f.buf.WriteString(", ")
+			}
+			f.expr(k, parser.LOWEST)
+			f.buf.WriteString(": ")
+			f.expr(e.Pairs[k], parser.LOWEST)
+		}
+		f.buf.WriteByte('}')
+	case *ast.FunctionLiteral:
+		f.buf.WriteString(e.Signature() + " ")
+		f.block(e.Body)
+	case *ast.CallExpression:
+		f.expr(e.Function, parser.CALL)
+		f.buf.WriteByte('(')
+		f.exprs(e.Arguments)
+		f.buf.WriteByte(')')
+	case *ast.IndexExpression:
+		f.expr(e.Left, parser.CALL)
+		f.buf.WriteString(optional(e.Optional) + "[")
+		f.expr(e.Index, parser.LOWEST)
+		f.buf.WriteByte(']')
+	case *ast.SliceExpression:
+		f.expr(e.Left, parser.CALL)
+		f.buf.WriteString(optional(e.Optional) + "[")
+		if e.IndexLeft != nil {
+			f.expr(e.IndexLeft, parser.LOWEST)
+		}
+		f.buf.WriteByte(':')
+		if e.IndexRight != nil {
+			f.expr(e.IndexRight, parser.LOWEST)
+		}
+		f.buf.WriteByte(']')
+	case *ast.MemberExpression:
+		f.expr(e.Object, parser.CALL)
+		f.buf.WriteString(e.Token.Literal + e.Property.Value)
+	case *ast.IfExpression:
+		f.buf.WriteString("if (")
+		f.expr(e.Condition, parser.LOWEST)
+		f.buf.WriteString(") ")
+		f.block(e.Consequence)
+		if e.Alternative != nil {
+			f.buf.WriteString(" else ")
+			f.block(e.Alternative)
+		}
+	case *ast.TryExpression:
+		f.buf.WriteString("try ")
+		f.block(e.Block)
+		if e.Catch != nil {
+			f.buf.WriteString(" catch ")
+			if e.Param != nil {
+				f.buf.WriteString("(" + e.Param.Value + ") ")
+			}
+			f.block(e.Catch)
+		}
+		if e.Finally != nil {
+			f.buf.WriteString(" finally ")
+			f.block(e.Finally)
+		}
+	}
+}
+
+func (f *formatter) exprs(exps []ast.Expression) {
+	for i, e := range exps {
+		if i > 0 {
+			f.buf.WriteString(", ")
+		}
+		f.expr(e, parser.LOWEST)
+	}
+}
+
+func optional(opt bool) string {
+	if opt {
+		return "?"
+	}
+	return ""
+}
+
+// endLine returns the last line of node in the source.
+func endLine(node ast.Node) int {
+	line := 0
+	ast.Inspect(node, func(n ast.Node) bool {
+		line = max(line, n.Pos().Line)
+		switch n := n.(type) {
+		case *ast.BlockStatement:
+			line = max(line, n.Rbrace.Line)
+		case *ast.StringLiteral:
+			// Strings may span lines.
+			line = max(line, n.Pos().Line+strings.Count(n.Value, "\n"))
+		}
+		return true
+	})
+	return line
+}
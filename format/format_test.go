@@ -0,0 +1,55 @@
+package format
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`let x=1+2*3`, "let x = 1 + 2 * 3;\n"},
+		{`(1+2)*3;-(a+b);!(-a);a-(b-c);(a-b)-c`, "(1 + 2) * 3;\n-(a + b);\n!-a;\na - (b - c);\na - b - c;\n"},
+		{`(a ?? b) == c; a ?? (b == c)`, "(a ?? b) == c;\na ?? b == c;\n"},
+		{`let f=fn(a,b){a+b}; f(1,2)[0]; (f)(1); a?.b?[1]; a[1:]; a?[:2]; [1,[2]]; {"a":1,2:[]}`,
+			"let f = fn(a, b) { a + b };\nf(1, 2)[0];\nf(1);\na?.b?[1];\na[1:];\na?[:2];\n[1, [2]];\n{\"a\": 1, 2: []};\n"},
+		{"let a = 1;\n\n\nlet b = 2;\nlet c = 3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+		{`if(x<1){return 1;}else{let y=2;y}`, "if (x < 1) {\n    return 1;\n} else {\n    let y = 2;\n    y\n};\n"},
+		{`try{throw "e"}catch(e){e.message}finally{puts(1);2}`, "try {\n    throw \"e\";\n} catch (e) { e.message } finally {\n    puts(1);\n    2\n};\n"},
+		{`try { 1 } catch { 2 }`, "try { 1 } catch { 2 };\n"},
+		{`import "lib.mnk" as l; export let z = fn() {}; null`, "import \"lib.mnk\" as l;\nexport let z = fn() {};\nnull;\n"},
+		{`let x:int=1; let f=fn(a:array<int>,b)->hash<string,fn(int)->bool>{b}`,
+			"let x: int = 1;\nlet f = fn(a: array<int>, b) -> hash<string, fn(int) -> bool> { b };\n"},
+		{`let = 1`, ""},
+	}
+
+	for _, tt := range tests {
+		got, err := Source(tt.input)
+		if tt.want == "" {
+			require.EqualError(t, err, "1:5: expected next token to be IDENT, got = instead")
+			continue
+		}
+		require.NoError(t, err, tt.input)
+		require.Equal(t, tt.want, got, tt.input)
+
+		again, err := Source(got)
+		require.NoError(t, err, got)
+		require.Equal(t, got, again, "formatting is idempotent")
+	}
+}
+
+func TestExamples(t *testing.T) {
+	for _, file := range []string{"../examples/map_reduce.mnk", "../examples/lib/collections.mnk"} {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		got, err := Source(string(data))
+		require.NoError(t, err, file)
+		again, err := Source(got)
+		require.NoError(t, err, file)
+		require.Equal(t, got, again, file)
+	}
+}
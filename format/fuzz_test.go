@@ -0,0 +1,30 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+// FuzzRoundTrip checks that formatted programs parse back to the same
+// program: the String of the syntax trees, which is lossy, and the
+// formatted source agree.
+func FuzzRoundTrip(f *testing.F) {
+	testutils.AddSeeds(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.FromInput(input)
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return
+		}
+		src := Program(program)
+
+		p = parser.FromInput(src)
+		again := p.ParseProgram()
+		require.Empty(t, p.Errors(), src)
+		require.Equal(t, program.String(), again.String(), src)
+		require.Equal(t, src, Program(again))
+	})
+}
@@ -0,0 +1,158 @@
+// Package jsonrpc reads and writes JSON-RPC 2.0 messages framed with a
+// Content-Length header, as used by the Language Server Protocol. The
+// framing alone is shared with the Debug Adapter Protocol.
+package jsonrpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ReadFrame reads the body of the next message from r.
+func ReadFrame(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// WriteFrame writes v encoded as JSON to w, preceded by its header.
+func WriteFrame(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// Message is a request, response or notification. Requests have an ID and
+// a Method, notifications only a Method, responses an ID and a Result or
+// an Error.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// IsRequest reports whether m is a request, rather than a notification or
+// response.
+func (m *Message) IsRequest() bool { return m.Method != "" && m.ID != nil }
+
+// Error codes defined by JSON-RPC.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+)
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message) }
+
+// Conn is a connection exchanging messages. Writes may happen concurrently.
+type Conn struct {
+	r  *bufio.Reader
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{r: bufio.NewReader(r), w: w}
+}
+
+// Read reads the next message.
+func (c *Conn) Read() (*Message, error) {
+	body, err := ReadFrame(c.r)
+	if err != nil {
+		return nil, err
+	}
+	var msg Message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, &Error{Code: ParseError, Message: err.Error()}
+	}
+	return &msg, nil
+}
+
+func (c *Conn) write(msg *Message) error {
+	msg.JSONRPC = "2.0"
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return WriteFrame(c.w, msg)
+}
+
+// Notify sends the notification method with params.
+func (c *Conn) Notify(method string, params any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&Message{Method: method, Params: raw})
+}
+
+// Request sends the request method with params and id.
+func (c *Conn) Request(id int, method string, params any) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&Message{ID: json.RawMessage(strconv.Itoa(id)), Method: method, Params: raw})
+}
+
+// Reply sends the response to the request with id: an error if err is not
+// nil, and result otherwise.
+func (c *Conn) Reply(id json.RawMessage, result any, err error) error {
+	msg := &Message{ID: id}
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{Code: InternalError, Message: err.Error()}
+		}
+		msg.Error = rpcErr
+		return c.write(msg)
+	}
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	msg.Result = raw
+	return c.write(msg)
+}
@@ -0,0 +1,67 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrames(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteFrame(&buf, map[string]int{"a": 1}))
+	require.NoError(t, WriteFrame(&buf, []int{}))
+	require.Equal(t, "Content-Length: 7\r\n\r\n{\"a\":1}Content-Length: 2\r\n\r\n[]", buf.String())
+
+	r := bufio.NewReader(&buf)
+	body, err := ReadFrame(r)
+	require.NoError(t, err)
+	require.Equal(t, `{"a":1}`, string(body))
+	body, err = ReadFrame(r)
+	require.NoError(t, err)
+	require.Equal(t, `[]`, string(body))
+
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"Content-Type: x\r\n\r\n{}", "missing Content-Length header"},
+		{"Content-Length: two\r\n\r\n{}", `invalid Content-Length " two"`},
+		{"Content-Length: 5\r\n\r\n{}", "unexpected EOF"},
+		{"", "EOF"},
+	}
+	for _, tt := range tests {
+		_, err := ReadFrame(bufio.NewReader(strings.NewReader(tt.input)))
+		require.EqualError(t, err, tt.err, tt.input)
+	}
+}
+
+func TestConn(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewConn(&buf, &buf)
+	require.NoError(t, c.Request(1, "add", []int{1, 2}))
+	require.NoError(t, c.Notify("note", nil))
+	require.NoError(t, c.Reply(json.RawMessage("1"), 3, nil))
+	require.NoError(t, c.Reply(json.RawMessage("2"), nil, &Error{Code: MethodNotFound, Message: "no"}))
+
+	msg, err := c.Read()
+	require.NoError(t, err)
+	require.True(t, msg.IsRequest())
+	require.Equal(t, "add", msg.Method)
+	require.JSONEq(t, `[1,2]`, string(msg.Params))
+
+	msg, err = c.Read()
+	require.NoError(t, err)
+	require.False(t, msg.IsRequest())
+
+	msg, err = c.Read()
+	require.NoError(t, err)
+	require.Equal(t, "3", string(msg.Result))
+
+	msg, err = c.Read()
+	require.NoError(t, err)
+	require.Equal(t, &Error{Code: MethodNotFound, Message: "no"}, msg.Error)
+}
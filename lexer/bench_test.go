@@ -0,0 +1,22 @@
+package lexer
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/testutils"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+func BenchmarkLexer(b *testing.B) {
+	for _, prog := range testutils.BenchPrograms(b) {
+		b.Run(prog.Name, func(b *testing.B) {
+			b.SetBytes(int64(len(prog.Src)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				l := New(prog.Src)
+				for l.NextToken().Type != token.EOF {
+				}
+			}
+		})
+	}
+}
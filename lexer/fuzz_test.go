@@ -0,0 +1,25 @@
+package lexer
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/testutils"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+// FuzzLexer checks that the lexer reaches EOF, consuming input with every
+// token, and stays there.
+func FuzzLexer(f *testing.F) {
+	testutils.AddSeeds(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		l := New(input)
+		for i := 0; l.NextToken().Type != token.EOF; i++ {
+			if i >= len(input) {
+				t.Fatalf("no EOF after %d tokens", i+1)
+			}
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Fatalf("got %s after EOF", tok.Type)
+		}
+	})
+}
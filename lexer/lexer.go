@@ -9,20 +9,33 @@ type Lexer struct {
 	position     int
 	readPosition int
 	ch           byte
+
+	// line and column of ch
+	line   int
+	column int
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespace()
+	pos := token.Position{Line: l.line, Column: l.column}
+	tok := l.readToken()
+	tok.Pos = pos
+	return tok
+}
 
-	tok = token.Ch(string(l.ch))
+func (l *Lexer) readToken() token.Token {
+	if l.ch == 0 && l.position < len(l.input) {
+		// A NUL byte of the input rather than its end.
+		l.readChar()
+		return token.Token{Type: token.ILLEGAL, Literal: "\x00"}
+	}
+	tok := token.Ch(string(l.ch))
 	switch {
 
 	case tok.Type == token.ASSIGN:
@@ -34,6 +47,11 @@ func (l *Lexer) NextToken() token.Token {
 				Literal: string(ch) + string(l.ch),
 			}
 		}
+	case tok.Type == token.MINUS:
+		if l.peekChar() == '>' {
+			l.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: "->"}
+		}
 	case tok.Type == token.BANG:
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -77,6 +95,8 @@ func (l *Lexer) NextToken() token.Token {
 		case l.ch == ':':
 			tok.Type = token.COLON
 			tok.Literal = string(l.ch)
+		case l.ch == '?':
+			tok = l.readQuestion()
 		case isLetter(l.ch):
 			return token.Ident(l.readIdentifier())
 		case isDigit(l.ch):
@@ -88,6 +108,25 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+// readQuestion reads the two character operators starting with '?'. A lone
+// '?' is illegal.
+func (l *Lexer) readQuestion() token.Token {
+	var tp token.TokenType
+	switch l.peekChar() {
+	case '?':
+		tp = token.NULLISH
+	case '.':
+		tp = token.OPTCHAIN
+	case '[':
+		tp = token.OPTINDEX
+	default:
+		return token.Token{Type: token.ILLEGAL, Literal: string(l.ch)}
+	}
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: tp, Literal: string(ch) + string(l.ch)}
+}
+
 func (l *Lexer) peekChar() byte {
 	if l.readPosition >= len(l.input) {
 		return 0
@@ -132,6 +171,10 @@ func (l *Lexer) skipWhitespace() {
 func (l *Lexer) readChar() {
 	// only supports ascii
 	// TODO: add unicode support (and emojis)
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -139,6 +182,7 @@ func (l *Lexer) readChar() {
 	}
 	l.position = l.readPosition
 	l.readPosition++
+	l.column++
 }
 
 func isLetter(ch byte) bool {
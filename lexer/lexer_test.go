@@ -9,6 +9,11 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func noPos(tok token.Token) token.Token {
+	tok.Pos = token.Position{}
+	return tok
+}
+
 func TestNextSimple(t *testing.T) {
 	input := `=+(){},;`
 
@@ -52,45 +57,45 @@ func TestNextToken(t *testing.T) {
 		token.Ch(";"),
 		token.Ident("let"),
 		token.Ident("ten"),
-		// {token.LET, "let"},
-		// {token.IDENT, "five"},
-		// {token.ASSIGN, "="},
-		// {token.INT, "5"},
-		// {token.SEMICOLON, ";"},
-		// {token.LET, "let"},
-		// {token.IDENT, "ten"},
-		{token.ASSIGN, "="},
-		{token.INT, "10"},
-		{token.SEMICOLON, ";"},
+		// {Type: token.LET, Literal: "let"},
+		// {Type: token.IDENT, Literal: "five"},
+		// {Type: token.ASSIGN, Literal: "="},
+		// {Type: token.INT, Literal: "5"},
+		// {Type: token.SEMICOLON, Literal: ";"},
+		// {Type: token.LET, Literal: "let"},
+		// {Type: token.IDENT, Literal: "ten"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.INT, Literal: "10"},
+		{Type: token.SEMICOLON, Literal: ";"},
 
-		{token.LET, "let"},
-		{token.IDENT, "add"},
-		{token.ASSIGN, "="},
-		{token.FUNCTION, "fn"},
-		{token.LPAREN, "("},
-		{token.IDENT, "x"},
-		{token.COMMA, ","},
-		{token.IDENT, "y"},
-		{token.RPAREN, ")"},
-		{token.LBRACE, "{"},
-		{token.IDENT, "x"},
-		{token.PLUS, "+"},
-		{token.IDENT, "y"},
-		{token.SEMICOLON, ";"},
+		{Type: token.LET, Literal: "let"},
+		{Type: token.IDENT, Literal: "add"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.FUNCTION, Literal: "fn"},
+		{Type: token.LPAREN, Literal: "("},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.COMMA, Literal: ","},
+		{Type: token.IDENT, Literal: "y"},
+		{Type: token.RPAREN, Literal: ")"},
+		{Type: token.LBRACE, Literal: "{"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.PLUS, Literal: "+"},
+		{Type: token.IDENT, Literal: "y"},
+		{Type: token.SEMICOLON, Literal: ";"},
 
-		{token.RBRACE, "}"},
-		{token.SEMICOLON, ";"},
+		{Type: token.RBRACE, Literal: "}"},
+		{Type: token.SEMICOLON, Literal: ";"},
 
-		{token.LET, "let"},
-		{token.IDENT, "result"},
-		{token.ASSIGN, "="},
-		{token.IDENT, "add"},
-		{token.LPAREN, "("},
-		{token.IDENT, "five"},
-		{token.COMMA, ","},
-		{token.IDENT, "ten"},
-		{token.RPAREN, ")"},
-		{token.SEMICOLON, ";"},
+		{Type: token.LET, Literal: "let"},
+		{Type: token.IDENT, Literal: "result"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.IDENT, Literal: "add"},
+		{Type: token.LPAREN, Literal: "("},
+		{Type: token.IDENT, Literal: "five"},
+		{Type: token.COMMA, Literal: ","},
+		{Type: token.IDENT, Literal: "ten"},
+		{Type: token.RPAREN, Literal: ")"},
+		{Type: token.SEMICOLON, Literal: ";"},
 		token.Ch("!"),
 		token.Ch("-"),
 		token.Ch("/"),
@@ -117,40 +122,40 @@ func TestNextToken(t *testing.T) {
 		token.Ident("else"),
 		token.Ch("{"),
 		token.Ident("return"),
-		{token.FALSE, "false"},
+		{Type: token.FALSE, Literal: "false"},
 		token.Ch(";"),
 		token.Ch("}"),
 		token.Num("10"),
-		{token.EQ, "=="},
+		{Type: token.EQ, Literal: "=="},
 		token.Num("10"),
 		token.Ch(";"),
 		token.Num("10"),
-		{token.NOT_EQ, "!="},
+		{Type: token.NOT_EQ, Literal: "!="},
 		token.Num("9"),
 		token.Ch(";"),
-		{token.STRING, "foobar"},
+		{Type: token.STRING, Literal: "foobar"},
 		token.Ch(";"),
-		{token.STRING, "trololo"},
+		{Type: token.STRING, Literal: "trololo"},
 		token.Ch(";"),
-		{token.STRING, "foo bar"},
+		{Type: token.STRING, Literal: "foo bar"},
 		token.Ch(";"),
-		{token.STRING, ""},
+		{Type: token.STRING, Literal: ""},
 		token.Ch(";"),
-		{token.LBRACKET, "["},
-		{token.INT, "1"},
-		{token.COMMA, ","},
-		{token.INT, "2"},
-		{token.RBRACKET, "]"},
+		{Type: token.LBRACKET, Literal: "["},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.COMMA, Literal: ","},
+		{Type: token.INT, Literal: "2"},
+		{Type: token.RBRACKET, Literal: "]"},
 		token.Ch(";"),
 		token.Ident("arr"),
-		{token.LBRACKET, "["},
-		{token.INT, "1"},
-		{token.COLON, ":"},
-		{token.MINUS, "-"},
-		{token.INT, "1"},
-		{token.RBRACKET, "]"},
+		{Type: token.LBRACKET, Literal: "["},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.COLON, Literal: ":"},
+		{Type: token.MINUS, Literal: "-"},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.RBRACKET, Literal: "]"},
 		token.Ch(";"),
-		{token.EOF, ""},
+		{Type: token.EOF, Literal: ""},
 	}
 
 	input := `let five = 5;
@@ -190,7 +195,88 @@ arr[1:-1];
 		tok := l.NextToken()
 		start := min(n, l.position)
 		end := min(n, l.position+window)
-		require.Equal(t, tc, tok,
+		require.Equal(t, tc, noPos(tok),
 			fmt.Sprintf("pos=%d want=%+v window=%+v\n", i, tc, l.input[start:end]))
 	}
 }
+
+func TestNullOperators(t *testing.T) {
+	input := `null ?? a?.b?[0] ? c`
+
+	tests := []token.Token{
+		{Type: token.NULL, Literal: "null"},
+		{Type: token.NULLISH, Literal: "??"},
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.OPTCHAIN, Literal: "?."},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.OPTINDEX, Literal: "?["},
+		{Type: token.INT, Literal: "0"},
+		{Type: token.RBRACKET, Literal: "]"},
+		{Type: token.ILLEGAL, Literal: "?"},
+		{Type: token.IDENT, Literal: "c"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input)
+	for i, tc := range tests {
+		require.Equal(t, tc, noPos(l.NextToken()), "pos=%d", i)
+	}
+}
+
+func TestArrow(t *testing.T) {
+	input := `fn(a: int) -> int; a - >b`
+
+	tests := []token.Token{
+		{Type: token.FUNCTION, Literal: "fn"},
+		{Type: token.LPAREN, Literal: "("},
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.COLON, Literal: ":"},
+		{Type: token.IDENT, Literal: "int"},
+		{Type: token.RPAREN, Literal: ")"},
+		{Type: token.ARROW, Literal: "->"},
+		{Type: token.IDENT, Literal: "int"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.MINUS, Literal: "-"},
+		{Type: token.GT, Literal: ">"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input)
+	for i, tc := range tests {
+		require.Equal(t, tc, noPos(l.NextToken()), "pos=%d", i)
+	}
+}
+
+func TestPositions(t *testing.T) {
+	input := "let x = 5;\n\tfn(a) {\n  \"str\" == a\n}"
+
+	tests := []struct {
+		lit  string
+		line int
+		col  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"fn", 2, 2},
+		{"(", 2, 4},
+		{"a", 2, 5},
+		{")", 2, 6},
+		{"{", 2, 8},
+		{"str", 3, 3},
+		{"==", 3, 9},
+		{"a", 3, 12},
+		{"}", 4, 1},
+	}
+
+	l := New(input)
+	for i, tc := range tests {
+		tok := l.NextToken()
+		require.Equal(t, tc.lit, tok.Literal, "pos=%d", i)
+		require.Equal(t, token.Position{Line: tc.line, Column: tc.col}, tok.Pos, "pos=%d lit=%s", i, tc.lit)
+	}
+}
@@ -0,0 +1,34 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/object"
+)
+
+// builtinDoc returns the signature and description of the builtin or
+// builtin module name, shown on hover and completion.
+func builtinDoc(name string) (signature, doc string, ok bool) {
+	switch b, _ := eval.LookupBuiltin(name); b := b.(type) {
+	case *object.Builtin:
+		return b.Signature, b.Doc, true
+	case *object.Module:
+		var fns []string
+		for _, pair := range b.Exports.Pairs() {
+			if fn, ok := pair.Value.(*object.Builtin); ok {
+				fns = append(fns, fn.Signature)
+			}
+		}
+		return name, "Module with " + joinList(fns) + ".", true
+	}
+	return "", "", false
+}
+
+// joinList joins strs like "a, b and c".
+func joinList(strs []string) string {
+	if len(strs) < 2 {
+		return strings.Join(strs, "")
+	}
+	return strings.Join(strs[:len(strs)-1], ", ") + " and " + strs[len(strs)-1]
+}
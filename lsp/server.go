@@ -0,0 +1,334 @@
+// Package lsp implements a language server for Monkey, speaking the
+// Language Server Protocol over JSON-RPC. It publishes parse errors as
+// diagnostics and provides go to definition, references, hover, completion,
+// document symbols and formatting.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/format"
+	"github.com/EmilLaursen/wiig/jsonrpc"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/scope"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+// Server is a language server. Documents are kept in memory as the client
+// opens and edits them; the server never reads files itself.
+type Server struct {
+	conn *jsonrpc.Conn
+	docs map[string]*document
+}
+
+// document is an open document, parsed on every change.
+type document struct {
+	text    string
+	program *ast.Program
+	errors  []parser.Error
+	scopes  *scope.Info
+}
+
+func NewServer() *Server {
+	return &Server{docs: map[string]*document{}}
+}
+
+// Serve handles the messages read from r, writing responses and
+// notifications to w, until the client sends exit or r ends.
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	s.conn = jsonrpc.NewConn(r, w)
+	for {
+		msg, err := s.conn.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var rpcErr *jsonrpc.Error
+		if errors.As(err, &rpcErr) {
+			s.conn.Reply(json.RawMessage("null"), nil, rpcErr)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+		if !msg.IsRequest() {
+			s.notification(msg)
+			continue
+		}
+		result, err := s.request(msg)
+		if err := s.conn.Reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) notification(msg *jsonrpc.Message) {
+	switch msg.Method {
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			changes := params.ContentChanges
+			s.update(params.TextDocument.URI, changes[len(changes)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if json.Unmarshal(msg.Params, &params) == nil {
+			delete(s.docs, params.TextDocument.URI)
+			s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+				URI:         params.TextDocument.URI,
+				Diagnostics: []Diagnostic{},
+			})
+		}
+	}
+}
+
+func (s *Server) request(msg *jsonrpc.Message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		var result InitializeResult
+		result.ServerInfo.Name = "monkey"
+		result.Capabilities = ServerCapabilities{
+			TextDocumentSync:           1, // full document
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			HoverProvider:              true,
+			CompletionProvider:         map[string]any{},
+			DocumentSymbolProvider:     true,
+			DocumentFormattingProvider: true,
+		}
+		return result, nil
+	case "shutdown":
+		return nil, nil
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		return withDocument(s, msg, &params, &params.TextDocument, s.definition)
+	case "textDocument/references":
+		var params ReferenceParams
+		return withDocument(s, msg, &params, &params.TextDocument, s.references)
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		return withDocument(s, msg, &params, &params.TextDocument, s.hover)
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		return withDocument(s, msg, &params, &params.TextDocument, s.completion)
+	case "textDocument/documentSymbol":
+		var params DocumentParams
+		return withDocument(s, msg, &params, &params.TextDocument, s.symbols)
+	case "textDocument/formatting":
+		var params DocumentParams
+		return withDocument(s, msg, &params, &params.TextDocument, s.formatting)
+	default:
+		return nil, &jsonrpc.Error{Code: jsonrpc.MethodNotFound, Message: "method not supported: " + msg.Method}
+	}
+}
+
+// withDocument decodes the params of msg into params and calls handle with
+// the document identified by doc.
+func withDocument[P any](s *Server, msg *jsonrpc.Message, params *P, doc *TextDocumentIdentifier, handle func(*document, *P) any) (any, error) {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return nil, &jsonrpc.Error{Code: jsonrpc.InvalidParams, Message: err.Error()}
+	}
+	d, ok := s.docs[doc.URI]
+	if !ok {
+		return nil, &jsonrpc.Error{Code: jsonrpc.InvalidParams, Message: "unknown document " + doc.URI}
+	}
+	return handle(d, params), nil
+}
+
+// update parses the new text of the document uri and publishes its
+// diagnostics.
+func (s *Server) update(uri, text string) {
+	p := parser.FromInput(text)
+	program := p.ParseProgram()
+	doc := &document{text: text, program: program, errors: p.ErrorList(), scopes: scope.Resolve(program)}
+	s.docs[uri] = doc
+
+	diags := []Diagnostic{}
+	for _, err := range doc.errors {
+		start := toPosition(err.Pos)
+		diags = append(diags, Diagnostic{
+			Range:    Range{start, Position{start.Line, start.Character + 1}},
+			Severity: SeverityError,
+			Source:   "monkey",
+			Message:  err.Msg,
+		})
+	}
+	s.conn.Notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+func toPosition(pos token.Position) Position {
+	return Position{Line: max(pos.Line-1, 0), Character: max(pos.Column-1, 0)}
+}
+
+func fromPosition(pos Position) token.Position {
+	return token.Position{Line: pos.Line + 1, Column: pos.Character + 1}
+}
+
+func identRange(ident *ast.Identifier) Range {
+	start := toPosition(ident.Pos())
+	return Range{start, Position{start.Line, start.Character + len(ident.Value)}}
+}
+
+func (s *Server) definition(doc *document, params *TextDocumentPositionParams) any {
+	u := doc.scopes.UseAt(fromPosition(params.Position))
+	if u == nil || u.Binding == nil {
+		return nil
+	}
+	return Location{URI: params.TextDocument.URI, Range: identRange(u.Binding.Ident)}
+}
+
+func (s *Server) references(doc *document, params *ReferenceParams) any {
+	locs := []Location{}
+	u := doc.scopes.UseAt(fromPosition(params.Position))
+	if u == nil || u.Binding == nil {
+		return locs
+	}
+	uri := params.TextDocument.URI
+	if params.Context.IncludeDeclaration {
+		locs = append(locs, Location{URI: uri, Range: identRange(u.Binding.Ident)})
+	}
+	for _, ref := range u.Binding.Refs {
+		locs = append(locs, Location{URI: uri, Range: identRange(ref)})
+	}
+	return locs
+}
+
+func (s *Server) hover(doc *document, params *TextDocumentPositionParams) any {
+	u := doc.scopes.UseAt(fromPosition(params.Position))
+	if u == nil {
+		return nil
+	}
+
+	var text string
+	if u.Binding == nil {
+		signature, doc, ok := builtinDoc(u.Ident.Value)
+		if !ok {
+			return nil
+		}
+		text = "```monkey\n" + signature + "\n```\n\n" + doc
+	} else {
+		text = "```monkey\n" + describe(u.Binding) + "\n```"
+	}
+	r := identRange(u.Ident)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}
+}
+
+// describe summarises the definition of b in a line of source.
+func describe(b *scope.Binding) string {
+	switch b.Kind {
+	case scope.Param:
+		return "(parameter) " + b.Ident.Value
+	case scope.Catch:
+		return "(catch) " + b.Ident.Value
+	case scope.Let:
+		let := b.Node.(*ast.LetStatement)
+		if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+			return "let " + let.Name.Value + " = " + fn.Signature()
+		}
+	}
+	src := format.Node(b.Node)
+	if i := strings.IndexByte(src, '\n'); i >= 0 {
+		src = src[:i] + " …"
+	}
+	return src
+}
+
+func (s *Server) completion(doc *document, params *TextDocumentPositionParams) any {
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	add := func(item CompletionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	for _, b := range doc.scopes.Visible(fromPosition(params.Position)) {
+		item := CompletionItem{Label: b.Ident.Value, Kind: CompletionVariable, Detail: describe(b)}
+		if b.Kind == scope.Import {
+			item.Kind = CompletionModule
+		} else if let, ok := b.Node.(*ast.LetStatement); ok {
+			if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+				item.Kind = CompletionFunction
+			}
+		}
+		add(item)
+	}
+	for _, name := range eval.BuiltinNames() {
+		signature, _, _ := builtinDoc(name)
+		item := CompletionItem{Label: name, Kind: CompletionFunction, Detail: signature}
+		if name == "json" {
+			item.Kind = CompletionModule
+		}
+		add(item)
+	}
+	for _, kw := range token.Keywords() {
+		add(CompletionItem{Label: kw, Kind: CompletionKeyword})
+	}
+	return items
+}
+
+func (s *Server) symbols(doc *document, _ *DocumentParams) any {
+	return statementSymbols(doc.program.Statements)
+}
+
+// statementSymbols returns the symbols of the lets and imports among stmts,
+// with the lets in the body of a function as its children.
+func statementSymbols(stmts []ast.Statement) []DocumentSymbol {
+	syms := []DocumentSymbol{}
+	for _, stmt := range stmts {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = export.Let
+		}
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			sym := DocumentSymbol{
+				Name:           stmt.Name.Value,
+				Kind:           SymbolVariable,
+				Range:          Range{toPosition(stmt.Pos()), identRange(stmt.Name).End},
+				SelectionRange: identRange(stmt.Name),
+			}
+			if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && fn.Body != nil {
+				sym.Kind = SymbolFunction
+				end := toPosition(fn.Body.Rbrace)
+				sym.Range.End = Position{end.Line, end.Character + 1}
+				sym.Children = statementSymbols(fn.Body.Statements)
+			}
+			syms = append(syms, sym)
+		case *ast.ImportStatement:
+			syms = append(syms, DocumentSymbol{
+				Name:           stmt.Name.Value,
+				Kind:           SymbolModule,
+				Range:          Range{toPosition(stmt.Pos()), identRange(stmt.Name).End},
+				SelectionRange: identRange(stmt.Name),
+			})
+		}
+	}
+	return syms
+}
+
+// formatting replaces the whole document with its formatted source. It
+// returns no edits while the document has parse errors.
+func (s *Server) formatting(doc *document, _ *DocumentParams) any {
+	edits := []TextEdit{}
+	formatted, err := format.Source(doc.text)
+	if err != nil || formatted == doc.text {
+		return edits
+	}
+	lines := strings.Split(doc.text, "\n")
+	end := Position{Line: len(lines) - 1, Character: len(lines[len(lines)-1])}
+	return append(edits, TextEdit{Range: Range{End: end}, NewText: formatted})
+}
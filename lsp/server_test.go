@@ -0,0 +1,318 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/jsonrpc"
+	"github.com/stretchr/testify/require"
+)
+
+const uri = "file:///test.mnk"
+
+// client talks to a server running in-process over pipes. Messages are read
+// in the background, as the server may write while the client does.
+type client struct {
+	t        *testing.T
+	conn     *jsonrpc.Conn
+	messages chan *jsonrpc.Message
+	nextID   int
+	// diagnostics are the latest diagnostics published per document.
+	diagnostics map[string][]Diagnostic
+	done        chan error
+}
+
+func newClient(t *testing.T) *client {
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+	c := &client{
+		t:           t,
+		conn:        jsonrpc.NewConn(clientR, clientW),
+		messages:    make(chan *jsonrpc.Message),
+		diagnostics: map[string][]Diagnostic{},
+		done:        make(chan error, 1),
+	}
+	go func() {
+		c.done <- NewServer().Serve(serverR, serverW)
+		serverW.Close()
+	}()
+	go func() {
+		defer close(c.messages)
+		for {
+			msg, err := c.conn.Read()
+			if err != nil {
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		clientW.Close()
+		clientR.Close()
+	})
+	return c
+}
+
+func (c *client) notify(method string, params any) {
+	require.NoError(c.t, c.conn.Notify(method, params))
+}
+
+// call sends a request, decoding the result into result. Notifications
+// received meanwhile are recorded.
+func (c *client) call(method string, params, result any) *jsonrpc.Error {
+	c.nextID++
+	require.NoError(c.t, c.conn.Request(c.nextID, method, params))
+	for {
+		msg, ok := <-c.messages
+		require.True(c.t, ok, "connection closed")
+		if msg.Method == "textDocument/publishDiagnostics" {
+			var params PublishDiagnosticsParams
+			require.NoError(c.t, json.Unmarshal(msg.Params, &params))
+			c.diagnostics[params.URI] = params.Diagnostics
+			continue
+		}
+		require.Equal(c.t, strconv.Itoa(c.nextID), string(msg.ID))
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			require.NoError(c.t, json.Unmarshal(msg.Result, result))
+		}
+		return nil
+	}
+}
+
+func (c *client) open(text string) {
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Text: text},
+	})
+}
+
+func at(line, char int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{line, char}}
+}
+
+func rng(line, start, end int) Range {
+	return Range{Position{line, start}, Position{line, end}}
+}
+
+const source = `let add = fn(a, b) { a + b };
+let total = add(1, 2);
+let twice = fn(f, x) {
+    let once = f(x);
+    f(once)
+};
+puts(twice(add(1), total));
+`
+
+func TestInitializeAndShutdown(t *testing.T) {
+	c := newClient(t)
+	var result InitializeResult
+	require.Nil(t, c.call("initialize", map[string]any{}, &result))
+	require.Equal(t, "monkey", result.ServerInfo.Name)
+	require.Equal(t, 1, result.Capabilities.TextDocumentSync)
+	require.True(t, result.Capabilities.HoverProvider)
+
+	err := c.call("workspace/symbol", map[string]any{}, nil)
+	require.Equal(t, jsonrpc.MethodNotFound, err.Code)
+
+	require.Nil(t, c.call("shutdown", nil, nil))
+	c.notify("exit", nil)
+	require.NoError(t, <-c.done)
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := newClient(t)
+	c.open("let x = ;\nlet = 2;")
+	c.call("shutdown", nil, nil)
+	require.Equal(t, []Diagnostic{
+		{Range: rng(0, 8, 9), Severity: SeverityError, Source: "monkey", Message: "no prefix parse function for ; found"},
+		{Range: rng(1, 4, 5), Severity: SeverityError, Source: "monkey", Message: "expected next token to be IDENT, got = instead"},
+		{Range: rng(1, 4, 5), Severity: SeverityError, Source: "monkey", Message: "no prefix parse function for = found"},
+	}, c.diagnostics[uri])
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = 1;"}},
+	})
+	c.call("shutdown", nil, nil)
+	require.Empty(t, c.diagnostics[uri])
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+
+	tests := []struct {
+		pos  TextDocumentPositionParams
+		want *Location
+	}{
+		{at(1, 13), &Location{uri, rng(0, 4, 7)}},   // add in total
+		{at(0, 22), &Location{uri, rng(0, 13, 14)}}, // a in the body of add
+		{at(4, 6), &Location{uri, rng(3, 8, 12)}},   // once
+		{at(4, 4), &Location{uri, rng(2, 15, 16)}},  // parameter f
+		{at(6, 20), &Location{uri, rng(1, 4, 9)}},   // total
+		{at(6, 1), nil},  // puts is a builtin
+		{at(0, 10), nil}, // not an identifier
+	}
+	for _, tt := range tests {
+		var got *Location
+		require.Nil(t, c.call("textDocument/definition", tt.pos, &got))
+		require.Equal(t, tt.want, got, "%+v", tt.pos.Position)
+	}
+
+	params := ReferenceParams{TextDocumentPositionParams: at(0, 5)}
+	var refs []Location
+	require.Nil(t, c.call("textDocument/references", params, &refs))
+	require.Equal(t, []Location{{uri, rng(1, 12, 15)}, {uri, rng(6, 11, 14)}}, refs)
+
+	params = ReferenceParams{TextDocumentPositionParams: at(2, 15)}
+	params.Context.IncludeDeclaration = true
+	require.Nil(t, c.call("textDocument/references", params, &refs))
+	require.Equal(t, []Location{{uri, rng(2, 15, 16)}, {uri, rng(3, 15, 16)}, {uri, rng(4, 4, 5)}}, refs)
+}
+
+func TestScopes(t *testing.T) {
+	c := newClient(t)
+	c.open(`let f = fn() { g() };
+let g = fn() { 1 };
+let x = 1;
+let x = x + 1;
+try { 1 } catch (e) { e };
+let y = fn(x) { x };
+`)
+
+	tests := []struct {
+		pos  TextDocumentPositionParams
+		want *Location
+	}{
+		{at(0, 15), &Location{uri, rng(1, 4, 5)}},   // functions see later lets
+		{at(3, 8), &Location{uri, rng(2, 4, 5)}},    // the value sees the previous x
+		{at(4, 22), &Location{uri, rng(4, 17, 18)}}, // catch parameter
+		{at(5, 16), &Location{uri, rng(5, 11, 12)}}, // parameter shadows x
+	}
+	for _, tt := range tests {
+		var got *Location
+		require.Nil(t, c.call("textDocument/definition", tt.pos, &got))
+		require.Equal(t, tt.want, got, "%+v", tt.pos.Position)
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+
+	tests := []struct {
+		pos  TextDocumentPositionParams
+		want string
+	}{
+		{at(1, 13), "```monkey\nlet add = fn(a, b)\n```"},
+		{at(0, 22), "```monkey\n(parameter) a\n```"},
+		{at(4, 7), "```monkey\nlet once = f(x)\n```"},
+		{at(6, 1), "```monkey\nputs(values...)\n```\n\nPrints each value on its own line. Requires the io capability."},
+	}
+	for _, tt := range tests {
+		var got Hover
+		require.Nil(t, c.call("textDocument/hover", tt.pos, &got))
+		require.Equal(t, "markdown", got.Contents.Kind)
+		require.Equal(t, tt.want, got.Contents.Value)
+	}
+
+	var got *Hover
+	require.Nil(t, c.call("textDocument/hover", at(0, 10), &got))
+	require.Nil(t, got)
+}
+
+func TestBuiltinDocs(t *testing.T) {
+	for _, name := range eval.BuiltinNames() {
+		signature, doc, ok := builtinDoc(name)
+		require.True(t, ok, name)
+		require.NotEmpty(t, signature, name)
+		require.NotEmpty(t, doc, name)
+	}
+
+	signature, doc, _ := builtinDoc("json")
+	require.Equal(t, "json", signature)
+	require.Equal(t, "Module with json.parse(string) and json.stringify(value, indent?).", doc)
+	_, _, ok := builtinDoc("x")
+	require.False(t, ok)
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.open(source)
+
+	labels := func(pos TextDocumentPositionParams) map[string]int {
+		var items []CompletionItem
+		require.Nil(t, c.call("textDocument/completion", pos, &items))
+		kinds := map[string]int{}
+		for _, item := range items {
+			kinds[item.Label] = item.Kind
+		}
+		return kinds
+	}
+
+	inside := labels(at(4, 4))
+	require.Equal(t, CompletionFunction, inside["add"])
+	require.Equal(t, CompletionVariable, inside["once"])
+	require.Equal(t, CompletionVariable, inside["f"])
+	require.Equal(t, CompletionFunction, inside["len"])
+	require.Equal(t, CompletionModule, inside["json"])
+	require.Equal(t, CompletionKeyword, inside["let"])
+
+	outside := labels(at(6, 0))
+	require.Contains(t, outside, "total")
+	require.NotContains(t, outside, "once")
+	require.NotContains(t, outside, "f")
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := newClient(t)
+	c.open(`import "lib.mnk" as lib;
+export let twice = fn(f, x) {
+    let once = f(x);
+    f(once)
+};
+let n = 1;
+`)
+	var got []DocumentSymbol
+	require.Nil(t, c.call("textDocument/documentSymbol", DocumentParams{TextDocumentIdentifier{uri}}, &got))
+	require.Equal(t, []DocumentSymbol{
+		{Name: "lib", Kind: SymbolModule, Range: rng(0, 0, 23), SelectionRange: rng(0, 20, 23)},
+		{
+			Name: "twice", Kind: SymbolFunction,
+			Range: Range{Position{1, 7}, Position{4, 1}}, SelectionRange: rng(1, 11, 16),
+			Children: []DocumentSymbol{
+				{Name: "once", Kind: SymbolVariable, Range: rng(2, 4, 12), SelectionRange: rng(2, 8, 12)},
+			},
+		},
+		{Name: "n", Kind: SymbolVariable, Range: rng(5, 0, 5), SelectionRange: rng(5, 4, 5)},
+	}, got)
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	params := DocumentParams{TextDocumentIdentifier{uri}}
+
+	c.open("let x=1\nlet y=fn(a){a}")
+	var edits []TextEdit
+	require.Nil(t, c.call("textDocument/formatting", params, &edits))
+	require.Equal(t, []TextEdit{{
+		Range:   Range{End: Position{1, 14}},
+		NewText: "let x = 1;\nlet y = fn(a) { a };\n",
+	}}, edits)
+
+	c.open("let x = 1;\n")
+	require.Nil(t, c.call("textDocument/formatting", params, &edits))
+	require.Empty(t, edits)
+
+	c.open("let x = ;")
+	require.Nil(t, c.call("textDocument/formatting", params, &edits))
+	require.Empty(t, edits)
+
+	err := c.call("textDocument/formatting", DocumentParams{TextDocumentIdentifier{"file:///other.mnk"}}, nil)
+	require.Equal(t, jsonrpc.InvalidParams, err.Code)
+}
@@ -1,19 +1,28 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/user"
+	"path/filepath"
 
+	"github.com/EmilLaursen/wiig/dap"
 	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/lsp"
 	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/optimizer"
 	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/profiler"
 	"github.com/EmilLaursen/wiig/repl"
+	"github.com/EmilLaursen/wiig/types"
+	"github.com/EmilLaursen/wiig/vet"
 )
 
 func startRepl() {
-	fmt.Printf("HELLO")
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -24,51 +33,417 @@ func startRepl() {
 	repl.Start(os.Stdin, os.Stdout)
 }
 
-func runFiles(stdout, stderr io.Writer, files ...string) {
-	for _, file := range files {
+// workDirFS returns the filesystem scripts are run with: the working
+// directory, which symlinks cannot escape.
+func workDirFS() (fs.FS, error) {
+	root, err := os.OpenRoot(".")
+	if err != nil {
+		return nil, err
+	}
+	return root.FS(), nil
+}
+
+// fsPath converts file, a path on the host, to a path in workDirFS.
+func fsPath(file string) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if !fs.ValidPath(rel) {
+		return "", fmt.Errorf("%s is outside of the working directory", file)
+	}
+	return rel, nil
+}
+
+// readScript reads the script file, a path on the host, returning its source
+// and its name in workDirFS, or file itself when it is outside of the working
+// directory. Such scripts can only import modules from MONKEYPATH.
+func readScript(file string) (name, src string, err error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", "", err
+	}
+	if name, err = fsPath(file); err != nil {
+		name = file
+	}
+	return name, string(data), nil
+}
+
+// newInterpreter returns an interpreter for scripts run from the command
+// line, reading files of the working directory and importing modules from
+// MONKEYPATH too. It is granted caps, io and process.
+func newInterpreter(caps []eval.Capability, stdin io.Reader, stdout, stderr io.Writer) (*eval.Interpreter, error) {
+	fsys, err := workDirFS()
+	if err != nil {
+		return nil, err
+	}
+	interp := eval.New()
+	interp.FS = fsys
+	interp.Allow(append(caps, eval.CapIO, eval.CapProcess)...)
+	interp.Stdout = stdout
+	interp.Stderr = stderr
+	interp.Stdin = stdin
+	for _, dir := range filepath.SplitList(os.Getenv("MONKEYPATH")) {
+		p, err := fsPath(dir)
+		if err != nil {
+			return nil, fmt.Errorf("MONKEYPATH: %w", err)
+		}
+		interp.SearchPath = append(interp.SearchPath, p)
+	}
+	return interp, nil
+}
+
+// run implements `monkey run`, returning the exit status.
+func run(argv []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	allow := flags.String("allow", "", "comma separated capabilities granted to the script")
+	expr := flags.String("e", "", "evaluate `expr` instead of a script file")
+	optimize := flags.Bool("O", false, "optimize the script and its imports before evaluating them")
+	profile := flags.String("profile", "", "profile the script, writing a pprof profile to `file` and a report to stderr")
+	if err := flags.Parse(argv); err != nil {
+		return 2
+	}
+	caps, err := eval.ParseCapabilities(*allow)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	interp, err := newInterpreter(caps, stdin, stdout, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	// name is the script file as in readScript, or empty for -e and stdin.
+	var name, src string
+	args := flags.Args()
+	switch {
+	case *expr != "":
+		src = *expr
+	case len(args) == 0:
+		fmt.Fprintf(stderr, usage, progName())
+		return 2
+	case args[0] == "-":
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "read stdin: %s\n", err)
+			return 1
+		}
+		src, args = string(data), args[1:]
+	default:
+		name, src, err = readScript(args[0])
+		if err != nil {
+			fmt.Fprintf(stderr, "read file: %s\n", err)
+			return 1
+		}
+		args = args[1:]
+	}
+
+	p := parser.FromInput(src)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		fmt.Fprintf(stderr, "Errors in %s:\n", scriptName(name))
+		for _, msg := range p.Errors() {
+			fmt.Fprintf(stderr, "\t%s\n", msg)
+		}
+		return 1
+	}
+	if *optimize {
+		program = optimizer.Optimize(program)
+		interp.Optimize = optimizer.Optimize
+	}
+
+	env := object.NewEnv()
+	argv = make([]string, len(args))
+	copy(argv, args)
+	env.Set("args", stringArray(argv))
+
+	var prof *profiler.Profiler
+	if *profile != "" {
+		prof = profiler.New()
+		interp.Hook = prof
+		prof.Start()
+	}
+	var val object.Object
+	if name != "" {
+		val = interp.EvalFile(name, program, env)
+	} else {
+		val = interp.Eval(program, env)
+	}
+	if prof != nil {
+		prof.Stop()
+		if err := writeProfile(prof, *profile, stderr); err != nil {
+			fmt.Fprintf(stderr, "profile: %s\n", err)
+			return 1
+		}
+	}
+
+	switch val := val.(type) {
+	case *object.Exit:
+		return val.Code
+	case *object.Error:
+		printError(stderr, name, val)
+		return 1
+	case nil:
+	default:
+		fmt.Fprintln(stdout, val.Inspect())
+	}
+	return 0
+}
+
+// writeProfile writes the pprof profile of prof to file and its report to
+// stderr.
+func writeProfile(prof *profiler.Profiler, file string, stderr io.Writer) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	err = prof.WritePprof(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return prof.WriteText(stderr)
+}
+
+// vetFiles implements `monkey vet`, returning the exit status: 1 when
+// problems are found.
+func vetFiles(argv []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("vet", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the problems found as JSON")
+	enabled := make(map[string]*bool)
+	for _, c := range vet.Checks {
+		enabled[c.Name] = flags.Bool(c.Name, true, "report "+c.Doc)
+	}
+	if err := flags.Parse(argv); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintf(stderr, usage, progName())
+		return 2
+	}
+	var checks []string
+	for _, c := range vet.Checks {
+		if *enabled[c.Name] {
+			checks = append(checks, c.Name)
+		}
+	}
+
+	status := 0
+	diags := []vet.Diagnostic{}
+	for _, file := range flags.Args() {
 		data, err := os.ReadFile(file)
 		if err != nil {
-			fmt.Printf("read file: %s, %s\n", file, err)
-			return
+			fmt.Fprintf(stderr, "read file: %s\n", err)
+			status = 1
+			continue
 		}
+		for _, d := range vet.Source(string(data), checks) {
+			d.File = file
+			diags = append(diags, d)
+		}
+	}
+	if len(diags) > 0 {
+		status = 1
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(diags)
+		return status
+	}
+	for _, d := range diags {
+		fmt.Fprintln(stdout, d)
+	}
+	return status
+}
 
+// checkFiles implements `monkey check`, returning the exit status.
+func checkFiles(argv []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	verbose := flags.Bool("v", false, "print the types of the top level bindings")
+	if err := flags.Parse(argv); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintf(stderr, usage, progName())
+		return 2
+	}
+
+	status := 0
+	for _, file := range flags.Args() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "read file: %s\n", err)
+			status = 1
+			continue
+		}
 		p := parser.FromInput(string(data))
 		program := p.ParseProgram()
-		if len(p.Errors()) > 0 {
-			fmt.Fprintf(stderr, "Errors in file: %s\n", file)
-			for _, msg := range p.Errors() {
-				io.WriteString(stderr, msg)
-				io.WriteString(stderr, "\n")
+		if errs := p.ErrorList(); len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Fprintf(stdout, "%s:%s\n", file, err)
+			}
+			status = 1
+			continue
+		}
+		bindings, errs := types.Check(program)
+		for _, err := range errs {
+			fmt.Fprintf(stdout, "%s:%s\n", file, err)
+			status = 1
+		}
+		if *verbose {
+			for _, b := range bindings {
+				fmt.Fprintf(stdout, "%s:%s: %s: %s\n", file, b.Pos, b.Name, b.Type)
 			}
-			return
 		}
-		env := object.NewEnv()
-		val := eval.Eval(program, env)
-		io.WriteString(stdout, val.Inspect())
-		io.WriteString(stdout, "\n")
 	}
+	return status
+}
+
+// printError prints err, raised by the script name, with its stack trace.
+func printError(w io.Writer, name string, err *object.Error) {
+	if err.File != "" {
+		name = err.File
+	}
+	fmt.Fprintf(w, "%s:%s: %s\n", scriptName(name), err.Pos, err.Msg)
+	for _, frame := range err.Stack {
+		fmt.Fprintf(w, "\tat %s\n", frame)
+	}
+}
+
+// progName is the name the program was run as, for the usage message. The
+// arguments may be empty when run by other programs.
+func progName() string {
+	if len(os.Args) == 0 {
+		return "monkey"
+	}
+	return os.Args[0]
+}
+
+func scriptName(name string) string {
+	if name == "" {
+		return "<input>"
+	}
+	return name
+}
+
+func stringArray(strs []string) *object.Array {
+	elems := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elems[i] = &object.String{Value: s}
+	}
+	return &object.Array{Elems: elems}
 }
 
 const usage string = `Usage:
-%s repl
+%[1]s repl
+
+%[1]s run [ --allow=CAPABILITIES ] [ -O ] [ -profile FILE ] ( FILE | - | -e EXPR ) [ ARGS... ]
+
+Runs the script FILE, the script read from stdin (-) or the expression EXPR,
+with ARGS bound to the array args. The exit status is 1 when the script fails
+to parse or evaluate, or the code passed to exit(). With -O the script and its
+imports are optimized first. With -profile the calls of functions and builtins
+are measured, a report printed to stderr and a profile for go tool pprof
+written to FILE.
+
+CAPABILITIES is a comma separated list of io, fs, time, random, env, process
+or all. Scripts run with io and process by default.
+
+%[1]s bench [ --allow=CAPABILITIES ] [ -O ] [ -n RUNS ] [ -warmup RUNS ] FILE [ ARGS... ]
 
-%s run [ FILES... ]
+Runs the script FILE RUNS times, 10 by default, discarding its output, and
+prints the mean and percentile times and the allocations per run in the
+format of go test -bench, for benchstat.
+
+%[1]s vet [ -json ] [ -CHECK=false ... ] FILES...
+
+Reports suspicious constructs in FILES, as text or JSON. The exit status is 1
+when problems are found. Every check is run unless disabled: undefined,
+unused, shadow, argcount, unreachable and constcond; see -help.
+
+%[1]s check [ -v ] FILES...
+
+Type checks FILES without running them, inferring the types of bindings and
+checking them against the type annotations. The exit status is 1 when errors
+are found. With -v the types of the top level bindings are printed.
+
+%[1]s test [ --allow=CAPABILITIES ] [ -v ] [ -run REGEXP ] [ -junit FILE ] [ PATHS... ]
+
+Runs the tests of the test files PATHS, and of the files named *_test.mnk in
+the directories PATHS, the working directory by default. A test is a top
+level function named test_* failing if it raises an error, as by assert,
+assertEq and assertError. Each test is run in isolation, evaluating its file
+anew. The exit status is 1 when a test fails. With -junit the results are
+written as JUnit XML to FILE too.
+
+%[1]s lsp
+
+Runs the language server, speaking the Language Server Protocol on stdin and
+stdout.
+
+%[1]s dap
+
+Runs the debugger, speaking the Debug Adapter Protocol on stdin and stdout.
+Programs are launched with the io and process capabilities, and those listed
+in the "allow" launch argument.
 `
 
 func main() {
-	n := len(os.Args)
-	fmt.Printf("args: %+v\n", os.Args)
-	if n < 1 {
-		fmt.Printf(usage, os.Args[0], os.Args[0])
-		os.Exit(1)
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, usage, progName())
+		os.Exit(2)
 	}
 	switch os.Args[1] {
 	case "repl":
 		startRepl()
 	case "run":
-		runFiles(os.Stdout, os.Stderr, os.Args[2:]...)
+		os.Exit(run(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	case "vet":
+		os.Exit(vetFiles(os.Args[2:], os.Stdout, os.Stderr))
+	case "bench":
+		os.Exit(benchFile(os.Args[2:], os.Stdout, os.Stderr))
+	case "check":
+		os.Exit(checkFiles(os.Args[2:], os.Stdout, os.Stderr))
+	case "test":
+		os.Exit(testFiles(os.Args[2:], os.Stdout, os.Stderr))
+	case "dap":
+		fsys, err := workDirFS()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		srv := dap.NewServer()
+		srv.FS = fsys
+		srv.Resolve = fsPath
+		if err := srv.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "lsp":
+		if err := lsp.NewServer().Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	default:
-		fmt.Printf(usage, os.Args[0], os.Args[0])
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, usage, progName())
+		os.Exit(2)
 	}
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		argv   []string
+		stdin  string
+		status int
+		stdout string
+		stderr string
+	}{
+		{[]string{"-e", "1 + 1"}, "", 0, "2\n", ""},
+		{[]string{"-e", "puts(args)", "a", "b"}, "", 0, "[a, b]\nnull\n", ""},
+		{[]string{"-e", "let x = 1"}, "", 0, "", ""},
+		{[]string{"-e", "exit(3)"}, "", 3, "", ""},
+		{[]string{"-e", "now()"}, "", 1, "", "<input>:1:4: capability 'time' not granted\n\tat now (1:1)\n"},
+		{[]string{"--allow=time", "-e", "now() > 0"}, "", 0, "true\n", ""},
+		{[]string{"--allow=net", "-e", "1"}, "", 2, "", "unknown capability \"net\"\n"},
+		{[]string{"-e", "let f = fn() { 1 + true }; f()"}, "", 1, "", "<input>:1:18: type mismatch: INTEGER + BOOLEAN\n\tat f (1:28)\n"},
+		{[]string{"-e", "let = 1"}, "", 1, "", "Errors in <input>:\n\texpected next token to be IDENT, got = instead\n\tno prefix parse function for = found\n"},
+		{[]string{"-", "x"}, "puts(args[0]); readLine()", 0, "x\nnull\n", ""},
+		{[]string{"examples/map_reduce.mnk"}, "", 0, "[[2, 4, 6, 8, 10, 12], 21]\n", ""},
+		{[]string{"missing.mnk"}, "", 1, "", "read file: open missing.mnk: no such file or directory\n"},
+		{[]string{"-O", "examples/map_reduce.mnk"}, "", 0, "[[2, 4, 6, 8, 10, 12], 21]\n", ""},
+		{[]string{"-O", "-e", "let x = 1; let f = fn() { x + true }; f()"}, "", 1, "", "<input>:1:29: type mismatch: INTEGER + BOOLEAN\n\tat f (1:39)\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		status := run(tt.argv, strings.NewReader(tt.stdin), &stdout, &stderr)
+		require.Equal(t, tt.status, status, tt.argv)
+		require.Equal(t, tt.stdout, stdout.String(), tt.argv)
+		require.Equal(t, tt.stderr, stderr.String(), tt.argv)
+	}
+}
+
+func TestRunOutsideWorkDir(t *testing.T) {
+	outside := t.TempDir()
+	script := filepath.Join(outside, "p.mnk")
+	require.NoError(t, os.WriteFile(script, []byte("let f = fn() { 1 + true }; f()"), 0o644))
+
+	var stdout, stderr strings.Builder
+	status := run([]string{script}, strings.NewReader(""), &stdout, &stderr)
+	require.Equal(t, 1, status)
+	require.Equal(t, script+":1:18: type mismatch: INTEGER + BOOLEAN\n\tat f ("+script+":1:28)\n", stderr.String())
+
+	// Builtins cannot follow symlinks out of the working directory.
+	secret := filepath.Join(outside, "secret")
+	require.NoError(t, os.WriteFile(secret, []byte("secret"), 0o644))
+	dir := t.TempDir()
+	require.NoError(t, os.Symlink(secret, filepath.Join(dir, "leak")))
+	t.Chdir(dir)
+
+	stdout.Reset()
+	stderr.Reset()
+	status = run([]string{"--allow=fs", "-e", `readFile("leak")`}, strings.NewReader(""), &stdout, &stderr)
+	require.Equal(t, 1, status)
+	require.Empty(t, stdout.String())
+	require.Equal(t, "<input>:1:9: readFile: openat leak: path escapes from parent\n\tat readFile (1:1)\n", stderr.String())
+}
+
+func TestRunProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "prof.pb.gz")
+	var stdout, stderr strings.Builder
+	status := run([]string{"-profile", file, "examples/map_reduce.mnk"}, strings.NewReader(""), &stdout, &stderr)
+	require.Equal(t, 0, status, stderr.String())
+	require.Equal(t, "[[2, 4, 6, 8, 10, 12], 21]\n", stdout.String())
+	require.Regexp(t, `(?m)^total time .*\n\n +calls +total +self +allocs +bytes +function\n`, stderr.String())
+	require.Regexp(t, `(?m)^ +6 .* double \(examples/map_reduce\.mnk:4:14\)$`, stderr.String())
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x1f, 0x8b}, data[:2], "gzip header")
+
+	stderr.Reset()
+	status = run([]string{"-profile", filepath.Join(file, "missing"), "-e", "1"}, strings.NewReader(""), &stdout, &stderr)
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr.String(), "profile: open ")
+}
+
+func TestVet(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.mnk")
+	require.NoError(t, os.WriteFile(file, []byte("let x = 1;\nlen(y)"), 0o600))
+
+	tests := []struct {
+		argv   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{[]string{file}, 1, file + ":1:5: x is declared and not used [unused]\n" + file + ":2:5: undefined: y [undefined]\n", ""},
+		{[]string{"-unused=false", file}, 1, file + ":2:5: undefined: y [undefined]\n", ""},
+		{[]string{"-unused=false", "-undefined=false", file, "examples/map_reduce.mnk"}, 0, "", ""},
+		{[]string{"-json", "-unused=false", file}, 1, `[
+  {
+    "file": "` + file + `",
+    "line": 2,
+    "column": 5,
+    "check": "undefined",
+    "message": "undefined: y"
+  }
+]
+`, ""},
+		{[]string{"-json", "examples/map_reduce.mnk"}, 0, "[]\n", ""},
+		{[]string{"missing.mnk"}, 1, "", "read file: open missing.mnk: no such file or directory\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		status := vetFiles(tt.argv, &stdout, &stderr)
+		require.Equal(t, tt.status, status, tt.argv)
+		require.Equal(t, tt.stdout, stdout.String(), tt.argv)
+		require.Equal(t, tt.stderr, stderr.String(), tt.argv)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.mnk")
+	require.NoError(t, os.WriteFile(file, []byte("let x: int = 1;\nlet y = x + \"a\""), 0o600))
+	bad := filepath.Join(dir, "b.mnk")
+	require.NoError(t, os.WriteFile(bad, []byte("let = 1"), 0o600))
+
+	tests := []struct {
+		argv   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{[]string{file}, 1, file + ":2:11: type mismatch: int + string\n", ""},
+		{[]string{"-v", "examples/map_reduce.mnk"}, 0, "examples/map_reduce.mnk:3:5: a: array<int>\nexamples/map_reduce.mnk:4:5: double: fn(int) -> int\n", ""},
+		{[]string{bad}, 1, bad + ":1:5: expected next token to be IDENT, got = instead\n" + bad + ":1:5: no prefix parse function for = found\n", ""},
+		{[]string{"missing.mnk"}, 1, "", "read file: open missing.mnk: no such file or directory\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		status := checkFiles(tt.argv, &stdout, &stderr)
+		require.Equal(t, tt.status, status, tt.argv)
+		require.Equal(t, tt.stdout, stdout.String(), tt.argv)
+		require.Equal(t, tt.stderr, stderr.String(), tt.argv)
+	}
+}
+
+func TestProgName(t *testing.T) {
+	args := os.Args
+	t.Cleanup(func() { os.Args = args })
+
+	os.Args = []string{"/bin/monkey", "run"}
+	require.Equal(t, "/bin/monkey", progName())
+	os.Args = nil
+	require.Equal(t, "monkey", progName())
+}
@@ -1,24 +1,47 @@
 package object
 
+import "sort"
+
 func NewEnv() *Environment {
 	s := make(map[string]Object)
 	return &Environment{store: s}
 }
 
+// Environment binds names to values. The locals of functions and catch
+// clauses are kept in slots, assigned by the resolver, and other bindings
+// such as globals by name.
 type Environment struct {
 	store map[string]Object
+	// slots hold the variables named by names, nil until set.
+	slots []Object
+	names []string
 	outer *Environment
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
-	o, ok := e.store[name]
-	if !ok && e.outer != nil {
-		o, ok = e.outer.Get(name)
+	for env := e; env != nil; env = env.outer {
+		for i, n := range env.names {
+			if n == name && env.slots[i] != nil {
+				return env.slots[i], true
+			}
+		}
+		if o, ok := env.store[name]; ok {
+			return o, true
+		}
 	}
-	return o, ok
+	return nil, false
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	for i, n := range e.names {
+		if n == name {
+			e.slots[i] = val
+			return val
+		}
+	}
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
@@ -28,3 +51,51 @@ func NewScope(outer *Environment) *Environment {
 	env.outer = outer
 	return env
 }
+
+// NewFrame returns an environment enclosed by outer with a slot for each
+// of names, as resolved for a function or catch clause.
+func NewFrame(outer *Environment, names []string) *Environment {
+	return &Environment{slots: make([]Object, len(names)), names: names, outer: outer}
+}
+
+// GetSlot returns the value of slot of the environment depth levels out,
+// or nil if it is not set.
+func (e *Environment) GetSlot(depth, slot int) Object {
+	env := e
+	for ; depth > 0 && env != nil; depth-- {
+		env = env.outer
+	}
+	if env == nil || slot >= len(env.slots) {
+		return nil
+	}
+	return env.slots[slot]
+}
+
+// SetSlot sets slot of e to val, reporting whether e has the slot.
+func (e *Environment) SetSlot(slot int, val Object) bool {
+	if slot >= len(e.slots) {
+		return false
+	}
+	e.slots[slot] = val
+	return true
+}
+
+// Outer returns the enclosing environment, or nil for the global one.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Names returns the sorted names bound in e itself, not in outer scopes.
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store)+len(e.names))
+	for i, name := range e.names {
+		if e.slots[i] != nil {
+			names = append(names, name)
+		}
+	}
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
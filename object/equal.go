@@ -0,0 +1,93 @@
+package object
+
+const (
+	fnvOffset uint64 = 14695981039346656037
+	fnvPrime  uint64 = 1099511628211
+)
+
+// mixHashKey folds k into the running hash h, FNV style.
+func mixHashKey(h uint64, k HashKey) uint64 {
+	for i := 0; i < len(k.Type); i++ {
+		h ^= uint64(k.Type[i])
+		h *= fnvPrime
+	}
+	for i := 0; i < 8; i++ {
+		h ^= (k.Value >> (8 * i)) & 0xff
+		h *= fnvPrime
+	}
+	return h
+}
+
+// AsHashable returns o as a Hashable if it can be used as a hash key.
+// Arrays and hashes are hashable when everything they contain is.
+func AsHashable(o Object) (Hashable, bool) {
+	switch o := o.(type) {
+	case *Array:
+		for _, e := range o.Elems {
+			if _, ok := AsHashable(e); !ok {
+				return nil, false
+			}
+		}
+		return o, true
+	case *Hash:
+		for _, pair := range o.pairs {
+			if _, ok := AsHashable(pair.Value); !ok {
+				return nil, false
+			}
+		}
+		return o, true
+	case Hashable:
+		return o, true
+	default:
+		return nil, false
+	}
+}
+
+// Equal reports whether a and b are structurally equal. Integers, booleans,
+// strings and null compare by value, arrays element-wise and hashes by
+// contents regardless of insertion order. Anything else is only equal to
+// itself.
+func Equal(a, b Object) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Null:
+		return true
+	case *Array:
+		other := b.(*Array)
+		if len(a.Elems) != len(other.Elems) {
+			return false
+		}
+		for i := range a.Elems {
+			if !Equal(a.Elems[i], other.Elems[i]) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		other := b.(*Hash)
+		if a.Len() != other.Len() {
+			return false
+		}
+		for _, pair := range a.pairs {
+			v, ok := other.Get(pair.Key.(Hashable))
+			if !ok || !Equal(pair.Value, v) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
@@ -1,25 +1,44 @@
 package object
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"io"
+	"io/fs"
 	"strings"
 
 	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/token"
 )
 
 type (
 	ObjectType      string
-	BuiltinFunction func(args ...Object) Object
+	BuiltinFunction func(ctx Context, args ...Object) Object
 )
 
+// Context is the part of the running interpreter exposed to builtins.
+type Context interface {
+	// Apply calls fn, a function or builtin, with args.
+	Apply(fn Object, args ...Object) Object
+	// FileSystem is the only filesystem builtins may access. It may be nil.
+	FileSystem() fs.FS
+	// Output and ErrOutput are the standard output and error of the program.
+	Output() io.Writer
+	ErrOutput() io.Writer
+	// Input is the standard input of the program, buffered so consecutive
+	// reads see consecutive lines.
+	Input() *bufio.Reader
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
@@ -34,6 +53,8 @@ const (
 	BUILTIN_OBJ      ObjectType = "BUILTIN"
 	ARRAY_OBJ        ObjectType = "ARRAY"
 	HASH_OBJ         ObjectType = "HASH"
+	MODULE_OBJ       ObjectType = "MODULE"
+	EXIT_OBJ         ObjectType = "EXIT"
 )
 
 type HashPair struct {
@@ -41,15 +62,29 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash stores its pairs in insertion order, so Inspect and iteration are
+// deterministic. Use NewHash to create one.
+//
+// Keys are bucketed by HashKey and compared with Equal inside a bucket, so
+// colliding hash keys never merge distinct keys.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs []HashPair
+	index map[HashKey][]int
+}
+
+// bucketKey is the key keys are bucketed by, replaced by tests to force
+// collisions.
+var bucketKey = Hashable.HashKey
+
+func NewHash() *Hash {
+	return &Hash{index: make(map[HashKey][]int)}
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
-	var pairs []string
-	for _, pair := range h.Pairs {
+	pairs := make([]string, 0, len(h.pairs))
+	for _, pair := range h.pairs {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 	out.WriteString("{")
@@ -58,6 +93,82 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// HashKey combines the keys of all pairs independently of their order, so
+// equal hashes have equal keys. Only meaningful if AsHashable accepts h.
+func (h *Hash) HashKey() HashKey {
+	var sum uint64
+	for _, pair := range h.pairs {
+		k := mixHashKey(fnvOffset, pair.Key.(Hashable).HashKey())
+		k = mixHashKey(k, pair.Value.(Hashable).HashKey())
+		sum += k
+	}
+	return HashKey{Type: h.Type(), Value: sum}
+}
+
+// Len returns the number of pairs in the hash.
+func (h *Hash) Len() int { return len(h.pairs) }
+
+// Pairs returns the pairs of the hash in insertion order. The returned slice
+// must not be modified.
+func (h *Hash) Pairs() []HashPair { return h.pairs }
+
+func (h *Hash) find(key Hashable) (HashKey, int) {
+	hk := bucketKey(key)
+	for _, i := range h.index[hk] {
+		if Equal(h.pairs[i].Key, key) {
+			return hk, i
+		}
+	}
+	return hk, -1
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	_, i := h.find(key)
+	if i < 0 {
+		return nil, false
+	}
+	return h.pairs[i].Value, true
+}
+
+// Set inserts or replaces the value for key. Replacing keeps the original
+// position of the key.
+func (h *Hash) Set(key Hashable, val Object) {
+	hk, i := h.find(key)
+	if i >= 0 {
+		h.pairs[i].Value = val
+		return
+	}
+	h.index[hk] = append(h.index[hk], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: val})
+}
+
+// Delete removes key from the hash and reports whether it was present.
+func (h *Hash) Delete(key Hashable) bool {
+	_, i := h.find(key)
+	if i < 0 {
+		return false
+	}
+	h.pairs = append(h.pairs[:i], h.pairs[i+1:]...)
+	h.reindex()
+	return true
+}
+
+func (h *Hash) reindex() {
+	h.index = make(map[HashKey][]int, len(h.pairs))
+	for i, pair := range h.pairs {
+		hk := bucketKey(pair.Key.(Hashable))
+		h.index[hk] = append(h.index[hk], i)
+	}
+}
+
+// Copy returns a shallow copy of the hash.
+func (h *Hash) Copy() *Hash {
+	c := &Hash{pairs: make([]HashPair, len(h.pairs))}
+	copy(c.pairs, h.pairs)
+	c.reindex()
+	return c
+}
+
 func IsTypeOrNULL(one Object, ot ObjectType) bool {
 	if one.Type() == ot {
 		return true
@@ -137,23 +248,92 @@ func (i *Array) Inspect() string {
 	return out.String()
 }
 
+// HashKey combines the keys of the elements in order. Only meaningful if
+// AsHashable accepts i.
+func (i *Array) HashKey() HashKey {
+	v := fnvOffset
+	for _, e := range i.Elems {
+		v = mixHashKey(v, e.(Hashable).HashKey())
+	}
+	return HashKey{Type: i.Type(), Value: v}
+}
+
 type Builtin struct {
 	Fn BuiltinFunction
+	// Capability names the capability group the interpreter must grant
+	// before Fn may be called. Empty for builtins without side effects.
+	Capability string
+
+	// The fields below describe builtins to tools such as vet, the language
+	// server and the type checker. Methods leave them unset.
+
+	// MinArgs and MaxArgs bound the number of arguments Fn takes, MaxArgs
+	// being -1 for any number.
+	MinArgs, MaxArgs int
+	// Signature shows how the builtin is called, like "push(array, value)",
+	// and Doc what it does.
+	Signature, Doc string
+	// TypeAnnotation is the type of the builtin as it would be annotated,
+	// like "fn(array<T>, T) -> array<T>". Empty for builtins the type
+	// checker checks specially, such as those taking any number of
+	// arguments.
+	TypeAnnotation string
 }
 
 func (i *Builtin) Inspect() string  { return "builtin function" }
 func (i *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 
+// Error aborts evaluation until it is caught by a try expression.
 type Error struct {
 	Msg string
+	// Value is the value passed to throw, nil for errors raised by the
+	// interpreter itself.
+	Value Object
+	// File and Pos are where the error was raised, File being empty for
+	// programs not evaluated from a file.
+	File string
+	Pos  token.Position
+	// Stack holds the calls the error propagated through, innermost first.
+	Stack []Frame
+}
+
+// Frame is a call of Function at Pos in File, which is empty for programs
+// not evaluated from a file.
+type Frame struct {
+	Function string
+	File     string
+	Pos      token.Position
+}
+
+func (f Frame) String() string {
+	if f.File == "" {
+		return fmt.Sprintf("%s (%s)", f.Function, f.Pos)
+	}
+	return fmt.Sprintf("%s (%s:%s)", f.Function, f.File, f.Pos)
 }
 
 func (i *Error) Type() ObjectType { return ERROR_OBJ }
 func (i *Error) Inspect() string  { return "ERROR: " + i.Msg }
 
+// Exit aborts evaluation like an Error, but cannot be caught. The host
+// decides what exiting with Code means.
+type Exit struct {
+	Code int
+}
+
+func (e *Exit) Type() ObjectType { return EXIT_OBJ }
+func (e *Exit) Inspect() string  { return fmt.Sprintf("exit %d", e.Code) }
+
 type Function struct {
+	Name string
+	// File and Pos are where the function literal is, File being empty
+	// for programs not evaluated from a file.
+	File   string
+	Pos    token.Position
 	Params []*ast.Identifier
 	Body   *ast.BlockStatement
+	// Locals name the slots of the environments of calls, see NewFrame.
+	Locals []string
 	Env    *Environment
 }
 
@@ -167,8 +347,18 @@ func (n *Function) Inspect() string {
 	out.WriteString("fn")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(")")
+	out.WriteString(") ")
 	out.WriteString(n.Body.String())
 	out.WriteString("\n")
 	return out.String()
 }
+
+// Module is the namespace an import binds, holding the exported bindings of
+// the module loaded from Path.
+type Module struct {
+	Path    string
+	Exports *Hash
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "module " + m.Path }
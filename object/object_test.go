@@ -0,0 +1,69 @@
+package object
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashCollisions(t *testing.T) {
+	// Every key of a type lands in the same bucket.
+	bucketKey = func(k Hashable) HashKey { return HashKey{Type: k.Type()} }
+	t.Cleanup(func() { bucketKey = Hashable.HashKey })
+
+	str := func(s string) *String { return &String{Value: s} }
+	arr := func(elems ...Object) *Array { return &Array{Elems: elems} }
+	get := func(h *Hash, key Hashable) Object {
+		v, ok := h.Get(key)
+		require.True(t, ok, key.Inspect())
+		return v
+	}
+	one, two := &Integer{Value: 1}, &Integer{Value: 2}
+
+	h := NewHash()
+	h.Set(str("a"), one)
+	h.Set(str("b"), two)
+	h.Set(arr(one), one)
+	h.Set(arr(two), two)
+	require.Equal(t, 4, h.Len())
+
+	// Keys are found by value, not identity.
+	require.Same(t, one, get(h, str("a")))
+	require.Same(t, two, get(h, str("b")))
+	require.Same(t, one, get(h, arr(&Integer{Value: 1})))
+	require.Same(t, two, get(h, arr(&Integer{Value: 2})))
+	_, ok := h.Get(str("c"))
+	require.False(t, ok)
+
+	h.Set(str("a"), two)
+	require.Equal(t, 4, h.Len())
+	require.Same(t, two, get(h, str("a")))
+
+	require.True(t, h.Delete(str("a")))
+	require.False(t, h.Delete(str("a")))
+	require.Equal(t, "{b: 2, [1]: 1, [2]: 2}", h.Inspect())
+	require.Same(t, two, get(h, str("b")))
+	require.Same(t, one, get(h, arr(&Integer{Value: 1})))
+}
+
+func TestHashKeys(t *testing.T) {
+	arr := func(elems ...Object) *Array { return &Array{Elems: elems} }
+	one, two := &Integer{Value: 1}, &Integer{Value: 2}
+
+	require.Equal(t, arr(one, two).HashKey(), arr(&Integer{Value: 1}, &Integer{Value: 2}).HashKey())
+	require.NotEqual(t, arr(one, two).HashKey(), arr(two, one).HashKey())
+	require.NotEqual(t, arr(arr()).HashKey(), arr().HashKey())
+
+	h1, h2 := NewHash(), NewHash()
+	h1.Set(one, two)
+	h1.Set(two, one)
+	h2.Set(two, one)
+	h2.Set(one, two)
+	require.Equal(t, h1.HashKey(), h2.HashKey())
+	require.True(t, Equal(h1, h2))
+
+	_, ok := AsHashable(arr(one, &Function{}))
+	require.False(t, ok)
+	_, ok = AsHashable(arr(one, h1))
+	require.True(t, ok)
+}
@@ -0,0 +1,27 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/format"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+// FuzzOptimize checks that optimizing programs never panics, and gives
+// programs that format to valid source.
+func FuzzOptimize(f *testing.F) {
+	testutils.AddSeeds(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		p := parser.FromInput(input)
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return
+		}
+		src := format.Program(Optimize(program))
+		p = parser.FromInput(src)
+		p.ParseProgram()
+		require.Empty(t, p.Errors(), src)
+	})
+}
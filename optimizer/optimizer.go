@@ -0,0 +1,376 @@
+// Package optimizer rewrites programs before they are evaluated, without
+// changing their output or errors: constant expressions are folded, if
+// branches that cannot be taken are removed and let bindings of literals
+// are inlined.
+package optimizer
+
+import (
+	"strconv"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+// Optimize rewrites program, which must have parsed without errors, in
+// place and returns it.
+func Optimize(program *ast.Program) *ast.Program {
+	sc := newScope(nil, program.Statements, nil)
+	program.Statements = statements(sc, program.Statements, true)
+	return program
+}
+
+// scope holds the bindings of a program, function body or catch block,
+// including those of the blocks nested in it other than function bodies and
+// catch blocks.
+type scope struct {
+	outer *scope
+	// defs counts the bindings of each name.
+	defs map[string]int
+	// consts holds the names bound once, to a literal, by a let evaluated
+	// unconditionally. They are added as the let is passed, so that only the
+	// uses following it are inlined.
+	consts map[string]ast.Expression
+}
+
+func newScope(outer *scope, stmts []ast.Statement, params []*ast.Identifier) *scope {
+	sc := &scope{outer: outer, defs: map[string]int{}, consts: map[string]ast.Expression{}}
+	for _, p := range params {
+		sc.defs[p.Value]++
+	}
+	var visit func(ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			sc.defs[n.Name.Value]++
+		case *ast.ImportStatement:
+			sc.defs[n.Name.Value]++
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.TryExpression:
+			ast.Inspect(n.Block, visit)
+			if n.Finally != nil {
+				ast.Inspect(n.Finally, visit)
+			}
+			return false
+		}
+		return true
+	}
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, visit)
+	}
+	return sc
+}
+
+// lookup returns the literal name is bound to, if it is a constant.
+func (sc *scope) lookup(name string) (ast.Expression, bool) {
+	for ; sc != nil; sc = sc.outer {
+		if lit, ok := sc.consts[name]; ok {
+			return lit, true
+		}
+		if sc.defs[name] > 0 {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// statements optimizes stmts. They are direct if they are evaluated
+// whenever the scope is, rather than in a block of an if or try.
+func statements(sc *scope, stmts []ast.Statement, direct bool) []ast.Statement {
+	out := make([]ast.Statement, 0, len(stmts))
+	for i, stmt := range stmts {
+		last := i == len(stmts)-1
+		if es, ok := stmt.(*ast.ExpressionStatement); ok {
+			if ife, ok := es.Expression.(*ast.IfExpression); ok {
+				ife.Condition = expr(sc, ife.Condition)
+				if taken, ok := truthy(ife.Condition); ok {
+					branch := ife.Alternative
+					if taken {
+						branch = ife.Consequence
+					}
+					// Statements of a taken branch run in the enclosing scope
+					// anyway, so they are spliced in, unless the value of the
+					// if is that of the scope and would change: a let ending
+					// the branch makes it null, but the scope nothing.
+					switch {
+					case branch == nil && !last:
+						continue
+					case branch == nil:
+						out = append(out, &ast.ExpressionStatement{Token: es.Token, Expression: null(ife.Pos())})
+						continue
+					case !last || endsInExpression(branch):
+						out = append(out, statements(sc, branch.Statements, direct)...)
+						continue
+					}
+				}
+			}
+		}
+		out = append(out, statement(sc, stmt, direct))
+	}
+	return out
+}
+
+// endsInExpression reports whether the last statement of block is an
+// expression statement.
+func endsInExpression(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+func statement(sc *scope, stmt ast.Statement, direct bool) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		stmt.Value = expr(sc, stmt.Value)
+		if direct && sc.defs[stmt.Name.Value] == 1 && isLiteral(stmt.Value) {
+			sc.consts[stmt.Name.Value] = stmt.Value
+		}
+	case *ast.ExportStatement:
+		statement(sc, stmt.Let, direct)
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue != nil {
+			stmt.ReturnValue = expr(sc, stmt.ReturnValue)
+		}
+	case *ast.ThrowStatement:
+		stmt.Value = expr(sc, stmt.Value)
+	case *ast.ExpressionStatement:
+		stmt.Expression = expr(sc, stmt.Expression)
+	}
+	return stmt
+}
+
+func block(sc *scope, b *ast.BlockStatement) *ast.BlockStatement {
+	if b != nil {
+		b.Statements = statements(sc, b.Statements, false)
+	}
+	return b
+}
+
+func expr(sc *scope, e ast.Expression) ast.Expression {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		if lit, ok := sc.lookup(e.Value); ok {
+			return literalAt(lit, e.Pos())
+		}
+	case *ast.PrefixExpression:
+		e.Right = expr(sc, e.Right)
+		if folded := foldPrefix(e); folded != nil {
+			return folded
+		}
+	case *ast.InfixExpression:
+		e.Left = expr(sc, e.Left)
+		e.Right = expr(sc, e.Right)
+		if folded := foldInfix(e); folded != nil {
+			return folded
+		}
+	case *ast.IfExpression:
+		return ifExpression(sc, e)
+	case *ast.FunctionLiteral:
+		fs := newScope(sc, e.Body.Statements, e.Params)
+		e.Body.Statements = statements(fs, e.Body.Statements, true)
+	case *ast.CallExpression:
+		e.Function = expr(sc, e.Function)
+		for i, arg := range e.Arguments {
+			e.Arguments[i] = expr(sc, arg)
+		}
+	case *ast.ArrayLiteral:
+		for i, el := range e.Elems {
+			e.Elems[i] = expr(sc, el)
+		}
+	case *ast.HashLiteral:
+		pairs := make(map[ast.Expression]ast.Expression, len(e.Pairs))
+		for i, k := range e.Keys {
+			v := e.Pairs[k]
+			e.Keys[i] = expr(sc, k)
+			pairs[e.Keys[i]] = expr(sc, v)
+		}
+		e.Pairs = pairs
+	case *ast.IndexExpression:
+		e.Left = expr(sc, e.Left)
+		e.Index = expr(sc, e.Index)
+	case *ast.SliceExpression:
+		e.Left = expr(sc, e.Left)
+		if e.IndexLeft != nil {
+			e.IndexLeft = expr(sc, e.IndexLeft)
+		}
+		if e.IndexRight != nil {
+			e.IndexRight = expr(sc, e.IndexRight)
+		}
+	case *ast.MemberExpression:
+		e.Object = expr(sc, e.Object)
+	case *ast.TryExpression:
+		block(sc, e.Block)
+		if e.Catch != nil {
+			var params []*ast.Identifier
+			if e.Param != nil {
+				params = append(params, e.Param)
+			}
+			cs := newScope(sc, e.Catch.Statements, params)
+			e.Catch.Statements = statements(cs, e.Catch.Statements, true)
+		}
+		block(sc, e.Finally)
+	}
+	return e
+}
+
+// ifExpression removes the branch of e that cannot be taken, if its
+// condition is constant. A branch of a single expression replaces e.
+func ifExpression(sc *scope, e *ast.IfExpression) ast.Expression {
+	e.Condition = expr(sc, e.Condition)
+	block(sc, e.Consequence)
+	block(sc, e.Alternative)
+
+	taken, ok := truthy(e.Condition)
+	if !ok {
+		return e
+	}
+	branch := e.Alternative
+	if taken {
+		branch = e.Consequence
+	}
+	if branch == nil {
+		return null(e.Pos())
+	}
+	if len(branch.Statements) == 1 {
+		if es, ok := branch.Statements[0].(*ast.ExpressionStatement); ok {
+			return es.Expression
+		}
+	}
+	e.Condition = boolean(e.Condition.Pos(), true)
+	e.Consequence, e.Alternative = branch, nil
+	return e
+}
+
+func isLiteral(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean, *ast.StringLiteral, *ast.Null:
+		return true
+	}
+	return false
+}
+
+// truthy reports whether the value of e is truthy, if e is a literal.
+func truthy(e ast.Expression) (taken bool, ok bool) {
+	switch e := e.(type) {
+	case *ast.Boolean:
+		return e.Value, true
+	case *ast.Null:
+		return false, true
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		return true, true
+	}
+	return false, false
+}
+
+func foldPrefix(e *ast.PrefixExpression) ast.Expression {
+	switch e.Operator {
+	case "!":
+		if taken, ok := truthy(e.Right); ok {
+			return boolean(e.Pos(), !taken)
+		}
+	case "-":
+		if n, ok := e.Right.(*ast.IntegerLiteral); ok {
+			return integer(e.Pos(), -n.Value)
+		}
+	}
+	return nil
+}
+
+// foldInfix evaluates e if its operands are literals and it cannot fail.
+// Division by zero and operators not supported by the operands are left
+// to fail when evaluated.
+func foldInfix(e *ast.InfixExpression) ast.Expression {
+	pos := e.Left.Pos()
+	_, lnull := e.Left.(*ast.Null)
+	_, rnull := e.Right.(*ast.Null)
+	if (lnull || rnull) && isLiteral(e.Left) && isLiteral(e.Right) {
+		switch e.Operator {
+		case "==":
+			return boolean(pos, lnull && rnull)
+		case "!=":
+			return boolean(pos, !(lnull && rnull))
+		}
+		return nil
+	}
+
+	switch l := e.Left.(type) {
+	case *ast.IntegerLiteral:
+		r, ok := e.Right.(*ast.IntegerLiteral)
+		if !ok {
+			return nil
+		}
+		switch e.Operator {
+		case "+":
+			return integer(pos, l.Value+r.Value)
+		case "-":
+			return integer(pos, l.Value-r.Value)
+		case "*":
+			return integer(pos, l.Value*r.Value)
+		case "/":
+			if r.Value != 0 {
+				return integer(pos, l.Value/r.Value)
+			}
+		case "<":
+			return boolean(pos, l.Value < r.Value)
+		case ">":
+			return boolean(pos, l.Value > r.Value)
+		case "==":
+			return boolean(pos, l.Value == r.Value)
+		case "!=":
+			return boolean(pos, l.Value != r.Value)
+		}
+	case *ast.Boolean:
+		r, ok := e.Right.(*ast.Boolean)
+		if !ok {
+			return nil
+		}
+		switch e.Operator {
+		case "==":
+			return boolean(pos, l.Value == r.Value)
+		case "!=":
+			return boolean(pos, l.Value != r.Value)
+		}
+	case *ast.StringLiteral:
+		if r, ok := e.Right.(*ast.StringLiteral); ok && e.Operator == "+" {
+			return str(pos, l.Value+r.Value)
+		}
+	}
+	return nil
+}
+
+// literalAt returns a copy of the literal lit at pos.
+func literalAt(lit ast.Expression, pos token.Position) ast.Expression {
+	switch lit := lit.(type) {
+	case *ast.IntegerLiteral:
+		return integer(pos, lit.Value)
+	case *ast.Boolean:
+		return boolean(pos, lit.Value)
+	case *ast.StringLiteral:
+		return str(pos, lit.Value)
+	}
+	return null(pos)
+}
+
+func integer(pos token.Position, v int64) *ast.IntegerLiteral {
+	return &ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: strconv.FormatInt(v, 10), Pos: pos}, Value: v}
+}
+
+func boolean(pos token.Position, v bool) *ast.Boolean {
+	tok := token.Ident(strconv.FormatBool(v))
+	tok.Pos = pos
+	return &ast.Boolean{Token: tok, Value: v}
+}
+
+func str(pos token.Position, v string) *ast.StringLiteral {
+	tok := token.Str(v)
+	tok.Pos = pos
+	return &ast.StringLiteral{Token: tok, Value: v}
+}
+
+func null(pos token.Position) *ast.Null {
+	tok := token.Ident("null")
+	tok.Pos = pos
+	return &ast.Null{Token: tok}
+}
@@ -0,0 +1,106 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/format"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.FromInput(input)
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors(), input)
+	return program
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`1 + 2 * 3`, "7;"},
+		{`10 / 3 - -1`, "4;"},
+		{`1 / 0`, "1 / 0;"},
+		{`1 < 2 == true`, "true;"},
+		{`"a" + "b" + "c"`, `"abc";`},
+		{`"a" == "a"`, `"a" == "a";`},
+		{`1 + true`, "1 + true;"},
+		{`null == null`, "true;"},
+		{`1 != null`, "true;"},
+		{`!true`, "false;"},
+		{`!!5`, "true;"},
+		{`!null`, "true;"},
+		{`-x`, "-x;"},
+		{`let x = 2; x * 3`, "let x = 2;\n6;"},
+		{`let x = 2; let f = fn(y) { x + y }; f(1)`, "let x = 2;\nlet f = fn(y) { 2 + y };\nf(1);"},
+		{`let f = fn() { x }; let x = 2; f()`, "let f = fn() { x };\nlet x = 2;\nf();"},
+		{`let x = 1; let x = 2; x`, "let x = 1;\nlet x = 2;\nx;"},
+		{`let x = 1; let f = fn(x) { x }; x`, "let x = 1;\nlet f = fn(x) { x };\n1;"},
+		{`let x = 1; let f = fn() { let x = 2; x }; x`, "let x = 1;\nlet f = fn() {\n    let x = 2;\n    2\n};\n1;"},
+		{`if (c) { let x = 1 }; x`, "if (c) {\n    let x = 1;\n};\nx;"},
+		{`try { let x = 1; x } catch (e) { x }`, "try {\n    let x = 1;\n    x\n} catch (e) { x };"},
+		{`let x = [1]; x`, "let x = [1];\nx;"},
+		{`let h = {"x": 1}; h.x`, "let h = {\"x\": 1};\nh.x;"},
+		{`if (true) { 1 } else { 2 }`, "1;"},
+		{`if (1 > 2) { 1 } else { 2 }`, "2;"},
+		{`if (false) { 1 }`, "null;"},
+		{`if (false) { 1 }; 2`, "2;"},
+		{`if (true) { let y = 1; y } else { 2 }; y`, "let y = 1;\n1;\n1;"},
+		{`let f = fn() { if (true) { return 1 }; 2 }`, "let f = fn() {\n    return 1;\n    2\n};"},
+		{`let z = if (true) { let a = 1; a } else { 2 }`, "let z = if (true) {\n    let a = 1;\n    a\n};"},
+		{`let z = if (false) { 1 }`, "let z = null;"},
+	}
+
+	for _, tt := range tests {
+		program := Optimize(parse(t, tt.input))
+		require.Equal(t, tt.want+"\n", format.Program(program), tt.input)
+	}
+}
+
+// TestSemantics checks that optimized programs evaluate to the same values
+// and errors.
+func TestSemantics(t *testing.T) {
+	inputs := []string{
+		`1 + true`,
+		`"a" - "b"`,
+		`-"a"`,
+		`let x = "a"; x == x`,
+		`let f = 5; f(1)`,
+		`let f = fn() { x }; f()`,
+		`let f = fn() { x }; let x = 3; f()`,
+		`if (c) { let x = 1 }; x`,
+		`if (false) { let x = 1 }; x`,
+		`if (true) { let x = 1 }; x`,
+		`if (true) { let x = 2 }`,
+		`if (true) {}`,
+		`if (false) {}`,
+		`let x = 1; if (x > 0) { "pos" } else { "neg" }`,
+		`let n = 10; let fib = fn(k) { if (k < 2) { return k } fib(k - 1) + fib(k - 2) }; fib(n)`,
+		`let x = null; x ?? 2`,
+		`let g = fn() { let y = 1; try { throw "e"; let y = 2 } catch (e) { y } }; g()`,
+		`let x = 1; let x = x + 1; x`,
+		`let s = "a" + "b"; s.upper()`,
+	}
+
+	for _, input := range inputs {
+		want := eval.Eval(parse(t, input), object.NewEnv())
+		got := eval.Eval(Optimize(parse(t, input)), object.NewEnv())
+		require.Equal(t, inspect(want), inspect(got), input)
+	}
+}
+
+func inspect(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return "<nil>"
+	case *object.Error:
+		return obj.Pos.String() + ": " + obj.Msg
+	}
+	return obj.Inspect()
+}
@@ -0,0 +1,23 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/testutils"
+)
+
+func BenchmarkParser(b *testing.B) {
+	for _, prog := range testutils.BenchPrograms(b) {
+		b.Run(prog.Name, func(b *testing.B) {
+			b.SetBytes(int64(len(prog.Src)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				p := FromInput(prog.Src)
+				p.ParseProgram()
+				if len(p.Errors()) > 0 {
+					b.Fatal(p.Errors())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/EmilLaursen/wiig/testutils"
+	"github.com/stretchr/testify/require"
+)
+
+// FuzzParseProgram checks that parsing never panics, whether or not the
+// input is a valid program, and that the String of valid programs parses
+// back to a program with the same String.
+func FuzzParseProgram(f *testing.F) {
+	testutils.AddSeeds(f)
+	f.Fuzz(func(t *testing.T, input string) {
+		p := FromInput(input)
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			return
+		}
+		src := program.String()
+
+		p = FromInput(src)
+		again := p.ParseProgram()
+		require.Empty(t, p.Errors(), src)
+		require.Equal(t, src, again.String())
+	})
+}
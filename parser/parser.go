@@ -6,6 +6,7 @@ import (
 
 	"github.com/EmilLaursen/wiig/ast"
 	"github.com/EmilLaursen/wiig/lexer"
+	"github.com/EmilLaursen/wiig/resolver"
 	"github.com/EmilLaursen/wiig/token"
 )
 
@@ -14,6 +15,7 @@ type Precedence int
 const (
 	_ Precedence = iota
 	LOWEST
+	NULLISH
 	EQUALS
 	LESSGREATER
 	SUM
@@ -24,6 +26,7 @@ const (
 )
 
 var precedences = map[token.TokenType]Precedence{
+	token.NULLISH:  NULLISH,
 	token.EQ:       EQUALS,
 	token.NOT_EQ:   EQUALS,
 	token.LT:       LESSGREATER,
@@ -34,6 +37,17 @@ var precedences = map[token.TokenType]Precedence{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.OPTINDEX: INDEX,
+	token.OPTCHAIN: INDEX,
+	token.DOT:      INDEX,
+}
+
+// PrecedenceOf returns the precedence of the infix operator t, or LOWEST.
+func PrecedenceOf(t token.TokenType) Precedence {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
 }
 
 type (
@@ -41,21 +55,32 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
+// Error is a parse error at Pos.
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e Error) Error() string { return fmt.Sprintf("%s: %s", e.Pos, e.Msg) }
+
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []Error
 
 	curToken  token.Token
 	peekToken token.Token
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// depth is the number of enclosing blocks of the current token.
+	depth int
 }
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:              l,
-		errors:         []string{},
+		errors:         []Error{},
 		prefixParseFns: map[token.TokenType]prefixParseFn{},
 		infixParseFns:  map[token.TokenType]infixParseFn{},
 	}
@@ -69,8 +94,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNull)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseString)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
@@ -86,6 +113,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexOrSliceExpression)
+	p.registerInfix(token.OPTINDEX, p.parseIndexOrSliceExpression)
+	p.registerInfix(token.OPTCHAIN, p.parseMemberExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.NULLISH, p.parseInfixExpression)
 	return p
 }
 
@@ -118,15 +149,38 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+	if len(p.errors) == 0 {
+		resolver.Resolve(program)
+	}
 	return program
 }
 
+// ParseType parses a type annotation, the whole of the input, e.g.
+// `fn(array<T>) -> T`. The result is nil if there are errors.
+func (p *Parser) ParseType() ast.TypeExpr {
+	typ := p.parseType()
+	if typ != nil && !p.expectPeek(token.EOF) {
+		return nil
+	}
+	return typ
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		// A nil *LetStatement would make a non-nil Statement.
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -188,6 +242,62 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	if p.depth > 0 {
+		p.addErr("import is only allowed at the top level")
+		return nil
+	}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	// as is not a keyword, so that it remains usable as an identifier.
+	if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != "as" {
+		p.addErrAt(p.peekToken.Pos, fmt.Sprintf("expected next token to be as, got %s instead", p.peekToken.Type))
+		return nil
+	}
+	p.nextToken()
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	if p.depth > 0 {
+		p.addErr("export is only allowed at the top level")
+		return nil
+	}
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+	stmt.Let = p.parseLetStatement()
+	if stmt.Let == nil {
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	v, err := strconv.ParseBool(p.curToken.Literal)
 	if err != nil {
@@ -201,6 +311,10 @@ func (p *Parser) parseBoolean() ast.Expression {
 	}
 }
 
+func (p *Parser) parseNull() ast.Expression {
+	return &ast.Null{Token: p.curToken}
+}
+
 func (p *Parser) parseString() ast.Expression {
 	return &ast.StringLiteral{
 		Token: p.curToken,
@@ -228,6 +342,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		val := p.parseExpression(LOWEST)
 		hsh.Pairs[key] = val
+		hsh.Keys = append(hsh.Keys, key)
 
 		if !(p.peekTokenIs(token.RBRACE) || p.expectPeek(token.COMMA)) {
 			return nil
@@ -309,12 +424,63 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	exp := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	exp.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			exp.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectPeek(token.RPAREN) {
+				return nil
+			}
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		exp.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		exp.Finally = p.parseBlockStatement()
+	}
+
+	if exp.Catch == nil && exp.Finally == nil {
+		p.addErrAt(p.peekToken.Pos, fmt.Sprintf("expected next token to be %s or %s, got %s instead", token.CATCH, token.FINALLY, p.peekToken.Type))
+		return nil
+	}
+	return exp
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
-	lit.Params = p.parseFunctionParameters()
+	lit.Params, lit.ParamTypes = p.parseFunctionParameters()
+	if lit.Params == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.ARROW) {
+		p.nextToken()
+		p.nextToken()
+		if lit.ReturnType = p.parseType(); lit.ReturnType == nil {
+			return nil
+		}
+	}
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
@@ -322,37 +488,115 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters parses the parameters of a function literal and
+// their optional type annotations. The types are nil unless a parameter is
+// annotated.
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []ast.TypeExpr) {
 	ids := []*ast.Identifier{}
+	var types []ast.TypeExpr
+	annotated := false
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return ids
-	}
-
-	p.nextToken()
-
-	ident := &ast.Identifier{
-		Token: p.curToken,
-		Value: p.curToken.Literal,
+		return ids, nil
 	}
-	ids = append(ids, ident)
-
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
 
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil, nil
+		}
 		ident := &ast.Identifier{
 			Token: p.curToken,
 			Value: p.curToken.Literal,
 		}
 		ids = append(ids, ident)
+
+		var typ ast.TypeExpr
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if typ = p.parseType(); typ == nil {
+				return nil, nil
+			}
+			annotated = true
+		}
+		types = append(types, typ)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.expectPeek(token.RPAREN) {
+		return nil, nil
+	}
+	if !annotated {
+		types = nil
+	}
+	return ids, types
+}
+
+// parseType parses a type annotation starting at the current token: a type
+// name, optionally with type arguments as in `hash<string, int>`, or a
+// function type `fn(int) -> bool`.
+func (p *Parser) parseType() ast.TypeExpr {
+	switch p.curToken.Type {
+	case token.IDENT:
+		typ := &ast.TypeName{Token: p.curToken, Name: p.curToken.Literal}
+		if !p.peekTokenIs(token.LT) {
+			return typ
+		}
+		p.nextToken()
+		if typ.Args = p.parseTypeList(token.GT); typ.Args == nil {
+			return nil
+		}
+		return typ
+	case token.FUNCTION:
+		typ := &ast.FunctionType{Token: p.curToken}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if p.peekTokenIs(token.RPAREN) {
+			p.nextToken()
+			typ.Params = []ast.TypeExpr{}
+		} else if typ.Params = p.parseTypeList(token.RPAREN); typ.Params == nil {
+			return nil
+		}
+		if !p.expectPeek(token.ARROW) {
+			return nil
+		}
+		p.nextToken()
+		if typ.Return = p.parseType(); typ.Return == nil {
+			return nil
+		}
+		return typ
+	default:
+		p.addErr(fmt.Sprintf("expected type, got %s instead", p.curToken.Type))
+		return nil
+	}
+}
+
+// parseTypeList parses one or more comma separated types following the
+// current token, and the end token closing them.
+func (p *Parser) parseTypeList(end token.TokenType) []ast.TypeExpr {
+	var types []ast.TypeExpr
+	for {
+		p.nextToken()
+		typ := p.parseType()
+		if typ == nil {
+			return nil
+		}
+		types = append(types, typ)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(end) {
 		return nil
 	}
-	return ids
+	return types
 }
 
 func (p *Parser) parseCallExpression(left ast.Expression) ast.Expression {
@@ -387,6 +631,7 @@ func (p *Parser) parseIndexOrSliceExpression(left ast.Expression) ast.Expression
 	var exp ast.Expression
 	var fst ast.Expression
 	tok := p.curToken
+	optional := p.curTokenIs(token.OPTINDEX)
 
 	if !p.peekTokenIs(token.COLON) {
 		p.nextToken()
@@ -398,6 +643,7 @@ func (p *Parser) parseIndexOrSliceExpression(left ast.Expression) ast.Expression
 			Token:     tok,
 			Left:      left,
 			IndexLeft: fst,
+			Optional:  optional,
 		}
 		p.nextToken()
 
@@ -411,9 +657,10 @@ func (p *Parser) parseIndexOrSliceExpression(left ast.Expression) ast.Expression
 		exp = slice
 	} else {
 		exp = &ast.IndexExpression{
-			Token: tok,
-			Left:  left,
-			Index: fst,
+			Token:    tok,
+			Left:     left,
+			Index:    fst,
+			Optional: optional,
 		}
 	}
 
@@ -423,6 +670,19 @@ func (p *Parser) parseIndexOrSliceExpression(left ast.Expression) ast.Expression
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{
+		Token:    p.curToken,
+		Object:   left,
+		Optional: p.curTokenIs(token.OPTCHAIN),
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
 	exp := p.parseExpression(LOWEST)
@@ -444,6 +704,14 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		Value: p.curToken.Literal,
 	}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if stmt.Type = p.parseType(); stmt.Type == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -451,6 +719,9 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fn.Name = stmt.Name.Value
+	}
 
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
@@ -462,6 +733,8 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	p.depth++
+	defer func() { p.depth-- }()
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
@@ -470,6 +743,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken.Pos
 	return block
 }
 
@@ -492,13 +766,28 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addErrAt(p.peekToken.Pos, msg)
 }
 
+// Errors returns the messages of the errors found.
 func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		msgs[i] = err.Msg
+	}
+	return msgs
+}
+
+// ErrorList returns the errors found, with their positions.
+func (p *Parser) ErrorList() []Error {
 	return p.errors
 }
 
+// addErr records an error at the current token.
 func (p *Parser) addErr(msg string) {
-	p.errors = append(p.errors, msg)
+	p.addErrAt(p.curToken.Pos, msg)
+}
+
+func (p *Parser) addErrAt(pos token.Position, msg string) {
+	p.errors = append(p.errors, Error{Pos: pos, Msg: msg})
 }
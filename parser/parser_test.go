@@ -12,6 +12,10 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func pos(line, column int) token.Position {
+	return token.Position{Line: line, Column: column}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	t.Helper()
 	errors := p.Errors()
@@ -228,19 +232,19 @@ let foobar = 838383;
 
 	want := []ast.Statement{
 		&ast.LetStatement{
-			Token: token.Token{Type: token.LET, Literal: "let"},
-			Name:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "x"}, Value: "x"},
+			Token: token.Token{Type: token.LET, Literal: "let", Pos: pos(2, 1)},
+			Name:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "x", Pos: pos(2, 5)}, Value: "x"},
 			Value: &ast.IntegerLiteral{
-				Token: token.Token{token.INT, "5"},
+				Token: token.Token{Type: token.INT, Literal: "5", Pos: pos(2, 9)},
 				Value: 5,
 			},
 		},
 
 		&ast.LetStatement{
-			Token: token.Token{Type: token.LET, Literal: "let"},
-			Name:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "y"}, Value: "y"},
+			Token: token.Token{Type: token.LET, Literal: "let", Pos: pos(3, 1)},
+			Name:  &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "y", Pos: pos(3, 5)}, Value: "y"},
 			Value: &ast.IntegerLiteral{
-				Token: token.Token{token.INT, "10"},
+				Token: token.Token{Type: token.INT, Literal: "10", Pos: pos(3, 9)},
 				Value: 10,
 			},
 		},
@@ -249,15 +253,17 @@ let foobar = 838383;
 			Token: token.Token{
 				Type:    token.LET,
 				Literal: "let",
+				Pos:     pos(4, 1),
 			},
 			Name: &ast.Identifier{
 				Token: token.Token{
 					Type:    token.IDENT,
 					Literal: "foobar",
+					Pos:     pos(4, 5),
 				}, Value: "foobar",
 			},
 			Value: &ast.IntegerLiteral{
-				Token: token.Token{token.INT, "838383"},
+				Token: token.Token{Type: token.INT, Literal: "838383", Pos: pos(4, 14)},
 				Value: 838383,
 			},
 		},
@@ -333,9 +339,9 @@ func TestIdentifiers(t *testing.T) {
 
 	want := []ast.Statement{
 		&ast.ExpressionStatement{
-			Token: token.Token{token.IDENT, "foobar"},
+			Token: token.Token{Type: token.IDENT, Literal: "foobar"},
 			Expression: &ast.Identifier{
-				Token: token.Token{token.IDENT, "foobar"},
+				Token: token.Token{Type: token.IDENT, Literal: "foobar"},
 				Value: "foobar",
 			},
 		},
@@ -405,9 +411,9 @@ func TestIntegerLiteralExpression(t *testing.T) {
 
 	want := []ast.Statement{
 		&ast.ExpressionStatement{
-			Token: token.Token{token.INT, "5"},
+			Token: token.Token{Type: token.INT, Literal: "5"},
 			Expression: &ast.IntegerLiteral{
-				Token: token.Token{token.INT, "5"},
+				Token: token.Token{Type: token.INT, Literal: "5"},
 				Value: 5,
 			},
 		},
@@ -485,7 +491,9 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"a+b/c", "(a + (b / c))"},
 		{"a+b*c+d/e -f", "(((a + (b * c)) + (d / e)) - f)"},
 
-		{"3+4;-5*5", "(3 + 4)((-5) * 5)"},
+		{"3+4;-5*5", "(3 + 4);((-5) * 5)"},
+		{"a b", "a;b"},
+		{"if (a) { b } else {} c", "if (a) { b } else { };c"},
 		{"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
 		{"5 > 4 != 3 < 4", "((5 > 4) != (3 < 4))"},
 
@@ -557,27 +565,29 @@ func TestFunctionLiteralParsing(t *testing.T) {
 
 	want := []ast.Statement{
 		&ast.ExpressionStatement{
-			Token: token.Token{Type: token.FUNCTION, Literal: "fn"},
+			Token: token.Token{Type: token.FUNCTION, Literal: "fn", Pos: pos(1, 1)},
 			Expression: &ast.FunctionLiteral{
-				Token: token.Token{Type: token.FUNCTION, Literal: "fn"},
+				Token: token.Token{Type: token.FUNCTION, Literal: "fn", Pos: pos(1, 1)},
 				Params: []*ast.Identifier{
-					{Token: token.Token{token.IDENT, "x"}, Value: "x"},
-					{Token: token.Token{token.IDENT, "y"}, Value: "y"},
+					{Token: token.Token{Type: token.IDENT, Literal: "x", Pos: pos(1, 4)}, Value: "x", Local: true, Slot: 0},
+					{Token: token.Token{Type: token.IDENT, Literal: "y", Pos: pos(1, 6)}, Value: "y", Local: true, Slot: 1},
 				},
 				Body: &ast.BlockStatement{
-					Token: token.Token{token.LBRACE, "{"},
+					Token: token.Token{Type: token.LBRACE, Literal: "{", Pos: pos(1, 9)},
 					Statements: []ast.Statement{
 						&ast.ExpressionStatement{
-							Token: token.Token{token.IDENT, "x"},
+							Token: token.Token{Type: token.IDENT, Literal: "x", Pos: pos(1, 10)},
 							Expression: &ast.InfixExpression{
-								Token:    token.Token{Type: token.PLUS, Literal: "+"},
+								Token:    token.Token{Type: token.PLUS, Literal: "+", Pos: pos(1, 11)},
 								Operator: "+",
-								Left:     &ast.Identifier{Token: token.Token{token.IDENT, "x"}, Value: "x"},
-								Right:    &ast.Identifier{Token: token.Token{token.IDENT, "y"}, Value: "y"},
+								Left:     &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "x", Pos: pos(1, 10)}, Value: "x", Local: true, Slot: 0},
+								Right:    &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: "y", Pos: pos(1, 12)}, Value: "y", Local: true, Slot: 1},
 							},
 						},
 					},
+					Rbrace: pos(1, 14),
 				},
+				Locals: []string{"x", "y"},
 			},
 		},
 	}
@@ -602,10 +612,17 @@ func TestFunctionParameterParsing(t *testing.T) {
 		fn := testutils.IsType[*ast.FunctionLiteral](t, stmt.Expression)
 		gotTokens := []token.Token{}
 		for _, p := range fn.Params {
-			gotTokens = append(gotTokens, p.Token)
+			gotTokens = append(gotTokens, token.Token{Type: p.Token.Type, Literal: p.Token.Literal})
 		}
 		require.Equal(t, tt.want, gotTokens)
 	}
+
+	for _, input := range []string{"fn(0) {}", "fn(x, ) {}", "fn(x, \"y\") {}"} {
+		p := FromInput(input)
+		p.ParseProgram()
+		require.NotEmpty(t, p.Errors(), input)
+		require.Contains(t, p.Errors()[0], "expected next token to be IDENT", input)
+	}
 }
 
 func TestCallExpressionParsing(t *testing.T) {
@@ -757,3 +774,181 @@ func TestHashLiteralParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestNullOperatorParsing(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"null", "null"},
+		{"a ?? b", "(a ?? b)"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b == c", "(a ?? (b == c))"},
+		{"a + b ?? c * d", "((a + b) ?? (c * d))"},
+		{"a?.b", "(a?.b)"},
+		{"a?.b?.c", "((a?.b)?.c)"},
+		{"a?[1]", "(a?[1])"},
+		{"a?[1:]", "(a?[1:])"},
+		{"a?.b?[0] ?? -1", "(((a?.b)?[0]) ?? (-1))"},
+		{"f(x)?.y", "(f(x)?.y)"},
+	}
+
+	for _, tt := range tests {
+		p := FromInput(tt.input)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		require.Equal(t, tt.want, program.String())
+	}
+}
+
+func TestMemberExpressionParsing(t *testing.T) {
+	p := FromInput("h?.key")
+	program := p.ParseProgram()
+	baseParseCheck(t, p, program, 1)
+
+	stmt := testutils.IsType[*ast.ExpressionStatement](t, program.Statements[0])
+	mexp := testutils.IsType[*ast.MemberExpression](t, stmt.Expression)
+	testLiteralExpression(t, "h", mexp.Object)
+	testLiteralExpression(t, "key", mexp.Property)
+	require.True(t, mexp.Optional)
+}
+
+func TestDotParsing(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"a.b", "(a.b)"},
+		{"a.b.c", "((a.b).c)"},
+		{"a.b(1, 2)", "(a.b)(1, 2)"},
+		{"a.b().c", "((a.b)().c)"},
+		{"a.b[0]", "((a.b)[0])"},
+		{"-a.b", "(-(a.b))"},
+		{"a.b + c.d", "((a.b) + (c.d))"},
+		{"a?.b.c", "((a?.b).c)"},
+	}
+
+	for _, tt := range tests {
+		p := FromInput(tt.input)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		require.Equal(t, tt.want, program.String())
+	}
+}
+
+func TestTryParsing(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"try { a } catch (e) { b }", "try { a } catch (e) { b }"},
+		{"try { a } catch { b }", "try { a } catch { b }"},
+		{"try { a } finally { c }", "try { a } finally { c }"},
+		{"try { a } catch (e) { b } finally { c }", "try { a } catch (e) { b } finally { c }"},
+		{"throw 1 + 2;", "throw (1 + 2);"},
+		{"let x = try { a } catch (e) { b };", "let x = try { a } catch (e) { b };"},
+	}
+
+	for _, tt := range tests {
+		p := FromInput(tt.input)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		require.Equal(t, tt.want, program.String())
+	}
+
+	p := FromInput("try { a }")
+	p.ParseProgram()
+	require.Equal(t, []string{"expected next token to be CATCH or FINALLY, got EOF instead"}, p.Errors())
+}
+
+func TestLetFunctionName(t *testing.T) {
+	p := FromInput("let f = fn(x) { x }; fn(y) { y }")
+	program := p.ParseProgram()
+	baseParseCheck(t, p, program, 2)
+
+	let := testutils.IsType[*ast.LetStatement](t, program.Statements[0])
+	require.Equal(t, "f", testutils.IsType[*ast.FunctionLiteral](t, let.Value).Name)
+	stmt := testutils.IsType[*ast.ExpressionStatement](t, program.Statements[1])
+	require.Equal(t, "", testutils.IsType[*ast.FunctionLiteral](t, stmt.Expression).Name)
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"let x: int = 5", "let x: int = 5;"},
+		{"let h: hash<string, array<int>> = {}", "let h: hash<string, array<int>> = {};"},
+		{"let f: fn(int, bool) -> fn() -> string = g", "let f: fn(int, bool) -> fn() -> string = g;"},
+		{"fn(a: int, b) -> int { a }", "fn(a: int, b) -> int { a }"},
+		{"fn(a, b) { a }", "fn(a, b) { a }"},
+	}
+
+	for _, tt := range tests {
+		p := FromInput(tt.input)
+		program := p.ParseProgram()
+		baseParseCheck(t, p, program, 1)
+		require.Equal(t, tt.want, program.String(), tt.input)
+	}
+
+	p := FromInput("fn(a: int, b) -> bool { a }")
+	program := p.ParseProgram()
+	baseParseCheck(t, p, program, 1)
+	fn := testutils.IsType[*ast.FunctionLiteral](t, program.Statements[0].(*ast.ExpressionStatement).Expression)
+	require.Len(t, fn.ParamTypes, 2)
+	require.Equal(t, &ast.TypeName{Token: token.Token{Type: token.IDENT, Literal: "int", Pos: pos(1, 7)}, Name: "int"}, fn.ParamTypes[0])
+	require.Nil(t, fn.ParamTypes[1])
+	require.Equal(t, "bool", fn.ReturnType.String())
+
+	errors := []struct {
+		input string
+		want  string
+	}{
+		{"let x: = 1", "expected type, got = instead"},
+		{"let x: array<int = 1", "expected next token to be >, got = instead"},
+		{"fn(a: 1) { a }", "expected type, got INT instead"},
+		{"let f: fn(int) = 1", "expected next token to be ->, got = instead"},
+	}
+	for _, tt := range errors {
+		p := FromInput(tt.input)
+		p.ParseProgram()
+		require.NotEmpty(t, p.Errors(), tt.input)
+		require.Equal(t, tt.want, p.Errors()[0], tt.input)
+	}
+}
+
+func TestImportExportParsing(t *testing.T) {
+	p := FromInput(`import "lib/a.mnk" as a; export let x = a.y;`)
+	program := p.ParseProgram()
+	baseParseCheck(t, p, program, 2)
+	require.Equal(t, `import "lib/a.mnk" as a;export let x = (a.y);`, program.String())
+
+	imp := testutils.IsType[*ast.ImportStatement](t, program.Statements[0])
+	require.Equal(t, "lib/a.mnk", imp.Path.Value)
+	testLiteralExpression(t, "a", imp.Name)
+	exp := testutils.IsType[*ast.ExportStatement](t, program.Statements[1])
+	testLiteralExpression(t, "x", exp.Let.Name)
+
+	// as is an identifier elsewhere.
+	p = FromInput(`import "a.mnk" as as; let as = as.f; as(as)`)
+	program = p.ParseProgram()
+	baseParseCheck(t, p, program, 3)
+	require.Equal(t, `import "a.mnk" as as;let as = (as.f);as(as)`, program.String())
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`fn() { import "a.mnk" as a; }`, "import is only allowed at the top level"},
+		{`if (true) { export let x = 1; }`, "export is only allowed at the top level"},
+		{`import "a.mnk";`, "expected next token to be as, got ; instead"},
+		{`import "a.mnk" a;`, "expected next token to be as, got IDENT instead"},
+		{`import "a.mnk" as;`, "expected next token to be IDENT, got ; instead"},
+		{`export x;`, "expected next token to be LET, got IDENT instead"},
+	}
+	for _, tt := range tests {
+		p := FromInput(tt.input)
+		p.ParseProgram()
+		require.Contains(t, p.Errors(), tt.want, tt.input)
+	}
+}
@@ -0,0 +1,189 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// The fields of the messages of profile.proto, the format of pprof.
+const (
+	profileSampleType        = 1
+	profileSample            = 2
+	profileLocation          = 4
+	profileFunction          = 5
+	profileStringTable       = 6
+	profileTimeNanos         = 9
+	profileDurationNanos     = 10
+	profilePeriodType        = 11
+	profilePeriod            = 12
+	profileDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// WritePprof writes the profile to w in the gzipped protocol buffer format
+// of pprof. There is a sample per call stack, with the number of calls and
+// the time and allocations exclusive of the calls made, so that pprof
+// shows the exclusive measurements of functions as flat and the inclusive
+// ones as cumulative. Each function has a single location, its definition.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	b := &pprofBuilder{strings: map[string]int64{"": 0}, table: []string{""}}
+
+	for _, st := range [][2]string{{"calls", "count"}, {"time", "nanoseconds"}, {"alloc_objects", "count"}, {"alloc_space", "bytes"}} {
+		var vt protobuf
+		vt.int(valueTypeType, b.str(st[0]))
+		vt.int(valueTypeUnit, b.str(st[1]))
+		b.msg.bytes(profileSampleType, vt)
+	}
+
+	p.walk(func(stack []*Func, n *node) {
+		var s protobuf
+		ids := make([]uint64, len(stack))
+		for i, f := range stack {
+			ids[i] = f.id
+		}
+		s.packed(sampleLocationID, ids)
+		s.packed(sampleValue, []uint64{uint64(n.calls), uint64(n.time.Nanoseconds()), n.allocs, n.bytes})
+		b.msg.bytes(profileSample, s)
+	})
+
+	funcs := make([]*Func, 0, len(p.funcs))
+	for _, f := range p.funcs {
+		funcs = append(funcs, f)
+	}
+	sort.Slice(funcs, func(i, j int) bool { return funcs[i].id < funcs[j].id })
+	for _, f := range funcs {
+		var line, loc protobuf
+		line.uint(lineFunctionID, f.id)
+		line.int(lineLine, int64(f.Pos.Line))
+		loc.uint(locationID, f.id)
+		loc.bytes(locationLine, line)
+		b.msg.bytes(profileLocation, loc)
+	}
+	for _, f := range funcs {
+		var fn protobuf
+		file := f.File
+		if f.Builtin {
+			file = "<builtin>"
+		}
+		fn.uint(functionID, f.id)
+		fn.int(functionName, b.str(pprofName(f)))
+		fn.int(functionSystemName, b.str(f.Name))
+		fn.int(functionFilename, b.str(file))
+		fn.int(functionStartLine, int64(f.Pos.Line))
+		b.msg.bytes(profileFunction, fn)
+	}
+
+	b.msg.int(profileTimeNanos, p.start.UnixNano())
+	b.msg.int(profileDurationNanos, p.Duration().Nanoseconds())
+	var pt protobuf
+	pt.int(valueTypeType, b.str("time"))
+	pt.int(valueTypeUnit, b.str("nanoseconds"))
+	b.msg.bytes(profilePeriodType, pt)
+	b.msg.int(profilePeriod, 1)
+	b.msg.int(profileDefaultSampleType, b.str("time"))
+	// The string table goes last, holding the strings of all the above.
+	for _, s := range b.table {
+		b.msg.string(profileStringTable, s)
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.msg); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// pprofName returns the name of f in pprof profiles. pprof drops what is
+// between angle brackets, taking it for template arguments, and merges the
+// functions of a file by name, so anonymous functions are named by position.
+func pprofName(f *Func) string {
+	if f.Name == "<anonymous>" {
+		return fmt.Sprintf("anonymous@%s", f.Pos)
+	}
+	return f.Name
+}
+
+// pprofBuilder builds a Profile message and its string table.
+type pprofBuilder struct {
+	msg     protobuf
+	strings map[string]int64
+	table   []string
+}
+
+// str returns the index of s in the string table, adding it if missing.
+func (b *pprofBuilder) str(s string) int64 {
+	i, ok := b.strings[s]
+	if !ok {
+		i = int64(len(b.table))
+		b.strings[s] = i
+		b.table = append(b.table, s)
+	}
+	return i
+}
+
+// protobuf is an encoded protocol buffer message, built field by field.
+type protobuf []byte
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (m *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		*m = append(*m, byte(x)|0x80)
+		x >>= 7
+	}
+	*m = append(*m, byte(x))
+}
+
+func (m *protobuf) tag(field, wire int) {
+	m.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (m *protobuf) uint(field int, x uint64) {
+	m.tag(field, wireVarint)
+	m.varint(x)
+}
+
+func (m *protobuf) int(field int, x int64) {
+	m.uint(field, uint64(x))
+}
+
+func (m *protobuf) bytes(field int, b []byte) {
+	m.tag(field, wireBytes)
+	m.varint(uint64(len(b)))
+	*m = append(*m, b...)
+}
+
+func (m *protobuf) string(field int, s string) {
+	m.bytes(field, []byte(s))
+}
+
+// packed encodes xs as a packed repeated field.
+func (m *protobuf) packed(field int, xs []uint64) {
+	var p protobuf
+	for _, x := range xs {
+		p.varint(x)
+	}
+	m.bytes(field, p)
+}
@@ -0,0 +1,270 @@
+// Package profiler measures where Monkey programs spend their time. A
+// Profiler is an eval.Hook counting the calls of each function, keyed by
+// the position of its definition, and of each builtin, with the time and
+// allocations inclusive and exclusive of the functions they call.
+//
+// The results are written as a text report, or as a pprof profile for
+// `go tool pprof`, with a sample per call stack.
+package profiler
+
+import (
+	"fmt"
+	"runtime/metrics"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+// Func is the measurements of a function or builtin.
+type Func struct {
+	// Name is the name of the function, "<anonymous>" if it has none, or
+	// of the builtin, such as "len" or "array.map".
+	Name    string
+	Builtin bool
+	// File and Pos are where the function is defined, empty for builtins
+	// and File empty for programs not read from a file.
+	File string
+	Pos  token.Position
+
+	Calls int
+	// Inclusive is the time spent in calls, including the functions they
+	// call, counted once for recursive calls. Exclusive excludes those.
+	Inclusive time.Duration
+	Exclusive time.Duration
+	// Allocs and Bytes are the heap allocations made by calls, exclusive of
+	// the functions they call. The runtime counts allocations as its caches
+	// of memory are refilled, so they are approximate for short calls.
+	Allocs uint64
+	Bytes  uint64
+
+	// id identifies the function in pprof profiles, from 1.
+	id uint64
+	// active is the number of calls being made, to count the inclusive
+	// time of recursive calls once.
+	active int
+}
+
+// String describes f by name and definition.
+func (f *Func) String() string {
+	switch {
+	case f.Builtin:
+		return f.Name + " (builtin)"
+	case f.File == "":
+		return fmt.Sprintf("%s (%s)", f.Name, f.Pos)
+	default:
+		return fmt.Sprintf("%s (%s:%s)", f.Name, f.File, f.Pos)
+	}
+}
+
+// key identifies a function by definition, or a builtin by name.
+type key struct {
+	file string
+	pos  token.Position
+	name string
+}
+
+// node is a call stack, with the exclusive measurements of its calls.
+type node struct {
+	fn       *Func
+	parent   *node
+	children map[*Func]*node
+
+	calls  int64
+	time   time.Duration
+	allocs uint64
+	bytes  uint64
+}
+
+func (n *node) child(fn *Func) *node {
+	c, ok := n.children[fn]
+	if !ok {
+		if n.children == nil {
+			n.children = make(map[*Func]*node)
+		}
+		c = &node{fn: fn, parent: n}
+		n.children[fn] = c
+	}
+	return c
+}
+
+// frame is a call being made.
+type frame struct {
+	node   *node
+	start  time.Time
+	allocs uint64
+	bytes  uint64
+	// child* are the totals of the calls made by the call.
+	childTime   time.Duration
+	childAllocs uint64
+	childBytes  uint64
+}
+
+// Profiler is an eval.Hook measuring calls. Start it before evaluating and
+// stop it after.
+type Profiler struct {
+	funcs map[key]*Func
+	stack []*frame
+	// root is the empty call stack, the calls made at the top level being
+	// its children.
+	root node
+
+	start, stop time.Time
+	metrics     []metrics.Sample
+}
+
+var _ eval.Hook = (*Profiler)(nil)
+
+// New returns a Profiler, to be set as the Hook of an interpreter.
+func New() *Profiler {
+	return &Profiler{
+		funcs: make(map[key]*Func),
+		metrics: []metrics.Sample{
+			{Name: "/gc/heap/allocs:objects"},
+			{Name: "/gc/heap/allocs:bytes"},
+		},
+	}
+}
+
+// Start starts the clock of the profile.
+func (p *Profiler) Start() { p.start = time.Now() }
+
+// Stop stops the clock of the profile.
+func (p *Profiler) Stop() { p.stop = time.Now() }
+
+// Duration returns the time between Start and Stop.
+func (p *Profiler) Duration() time.Duration { return p.stop.Sub(p.start) }
+
+// Before implements eval.Hook.
+func (p *Profiler) Before(ast.Node, *object.Environment) object.Object { return nil }
+
+// Enter implements eval.Hook.
+func (p *Profiler) Enter(fn object.Object, _ []object.Object) {
+	f := p.function(fn)
+	f.active++
+	parent := &p.root
+	if len(p.stack) > 0 {
+		parent = p.stack[len(p.stack)-1].node
+	}
+	fr := &frame{node: parent.child(f)}
+	p.stack = append(p.stack, fr)
+	// Measure last, leaving out the work of the profiler.
+	fr.allocs, fr.bytes = p.allocs()
+	fr.start = time.Now()
+}
+
+// Leave implements eval.Hook.
+func (p *Profiler) Leave(object.Object, object.Object) {
+	now := time.Now()
+	allocs, bytes := p.allocs()
+	fr := p.stack[len(p.stack)-1]
+	p.stack = p.stack[:len(p.stack)-1]
+
+	elapsed := now.Sub(fr.start)
+	allocs, bytes = allocs-fr.allocs, bytes-fr.bytes
+	exclusive := elapsed - fr.childTime
+	exAllocs, exBytes := allocs-min(allocs, fr.childAllocs), bytes-min(bytes, fr.childBytes)
+
+	n := fr.node
+	n.calls++
+	n.time += exclusive
+	n.allocs += exAllocs
+	n.bytes += exBytes
+
+	f := n.fn
+	f.Calls++
+	f.Exclusive += exclusive
+	f.Allocs += exAllocs
+	f.Bytes += exBytes
+	if f.active--; f.active == 0 {
+		f.Inclusive += elapsed
+	}
+
+	if len(p.stack) > 0 {
+		parent := p.stack[len(p.stack)-1]
+		parent.childTime += elapsed
+		parent.childAllocs += allocs
+		parent.childBytes += bytes
+	}
+}
+
+// allocs returns the number of heap allocations and bytes allocated so far.
+func (p *Profiler) allocs() (uint64, uint64) {
+	metrics.Read(p.metrics)
+	return p.metrics[0].Value.Uint64(), p.metrics[1].Value.Uint64()
+}
+
+// function returns the measurements of fn, a function or builtin.
+func (p *Profiler) function(fn object.Object) *Func {
+	var k key
+	switch fn := fn.(type) {
+	case *object.Function:
+		k = key{file: fn.File, pos: fn.Pos, name: fn.Name}
+	case *object.Builtin:
+		k = key{name: eval.BuiltinName(fn)}
+		if k.name == "" {
+			k.name = "<builtin>"
+		}
+	default:
+		k = key{name: "<" + strings.ToLower(string(fn.Type())) + ">"}
+	}
+
+	f, ok := p.funcs[k]
+	if !ok {
+		f = &Func{Name: k.name, File: k.file, Pos: k.pos, id: uint64(len(p.funcs) + 1)}
+		switch fn.(type) {
+		case *object.Function:
+			if f.Name == "" {
+				f.Name = "<anonymous>"
+			}
+		case *object.Builtin:
+			f.Builtin = true
+		}
+		p.funcs[k] = f
+	}
+	return f
+}
+
+// Funcs returns the measurements of the functions and builtins called, by
+// decreasing exclusive time.
+func (p *Profiler) Funcs() []*Func {
+	funcs := make([]*Func, 0, len(p.funcs))
+	for _, f := range p.funcs {
+		funcs = append(funcs, f)
+	}
+	sort.Slice(funcs, func(i, j int) bool {
+		if funcs[i].Exclusive != funcs[j].Exclusive {
+			return funcs[i].Exclusive > funcs[j].Exclusive
+		}
+		return funcs[i].id < funcs[j].id
+	})
+	return funcs
+}
+
+// walk calls visit with each call stack that made calls, innermost call
+// first, and its node.
+func (p *Profiler) walk(visit func(stack []*Func, n *node)) {
+	var rec func(n *node)
+	rec = func(n *node) {
+		if n.calls > 0 {
+			var stack []*Func
+			for m := n; m != &p.root; m = m.parent {
+				stack = append(stack, m.fn)
+			}
+			visit(stack, n)
+		}
+		children := make([]*node, 0, len(n.children))
+		for _, c := range n.children {
+			children = append(children, c)
+		}
+		sort.Slice(children, func(i, j int) bool { return children[i].fn.id < children[j].fn.id })
+		for _, c := range children {
+			rec(c)
+		}
+	}
+	rec(&p.root)
+}
@@ -0,0 +1,221 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/stretchr/testify/require"
+)
+
+const program = `let fib = fn(n) {
+  if (n < 2) { n } else { fib(n - 1) + fib(n - 2) }
+};
+let xs = [1, 2, 3].map(fn(x) { fib(x + 5) });
+len(xs)
+`
+
+// profile profiles program, evaluated from the file main.mnk.
+func profile(t *testing.T) *Profiler {
+	t.Helper()
+	prog := parser.FromInput(program).ParseProgram()
+	in := eval.New()
+	in.FS = fstest.MapFS{"main.mnk": {Data: []byte(program)}}
+	p := New()
+	in.Hook = p
+	p.Start()
+	res := in.EvalFile("main.mnk", prog, object.NewEnv())
+	p.Stop()
+	require.Equal(t, "3", res.Inspect())
+	return p
+}
+
+func TestProfiler(t *testing.T) {
+	p := profile(t)
+
+	funcs := map[string]*Func{}
+	for _, f := range p.Funcs() {
+		funcs[f.String()] = f
+	}
+	require.Len(t, funcs, 4)
+
+	// fib(6), fib(7) and fib(8) make 25, 41 and 67 calls.
+	fib := funcs["fib (main.mnk:1:11)"]
+	require.NotNil(t, fib)
+	require.Equal(t, 133, fib.Calls)
+	require.False(t, fib.Builtin)
+
+	anon := funcs["<anonymous> (main.mnk:4:24)"]
+	require.NotNil(t, anon)
+	require.Equal(t, 3, anon.Calls)
+
+	for _, name := range []string{"array.map (builtin)", "len (builtin)"} {
+		f := funcs[name]
+		require.NotNil(t, f, name)
+		require.True(t, f.Builtin)
+		require.Equal(t, 1, f.Calls)
+	}
+
+	// Recursive calls count once in the inclusive time, which the callers
+	// include in theirs.
+	mp := funcs["array.map (builtin)"]
+	require.GreaterOrEqual(t, fib.Inclusive, fib.Exclusive)
+	require.GreaterOrEqual(t, anon.Inclusive, fib.Inclusive+anon.Exclusive)
+	require.GreaterOrEqual(t, mp.Inclusive, anon.Inclusive+mp.Exclusive)
+	require.GreaterOrEqual(t, p.Duration(), mp.Inclusive)
+}
+
+func TestWriteText(t *testing.T) {
+	var out strings.Builder
+	require.NoError(t, profile(t).WriteText(&out))
+	lines := strings.Split(out.String(), "\n")
+	require.True(t, strings.HasPrefix(lines[0], "total time "), lines[0])
+	require.Regexp(t, `^ +calls +total +self +allocs +bytes +function$`, lines[2])
+	require.Regexp(t, `^ +133 .* fib \(main\.mnk:1:11\)$`, lines[3])
+	require.Regexp(t, `^ +3 .* <anonymous> \(main\.mnk:4:24\)$`, lines[4])
+	require.Regexp(t, `^ +calls +total +self +allocs +bytes +builtin$`, lines[6])
+	require.Len(t, lines, 10)
+}
+
+func TestWritePprof(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, profile(t).WritePprof(&buf))
+	zr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	data, err := io.ReadAll(zr)
+	require.NoError(t, err)
+
+	msg := decode(t, data)
+	strs := msg.strings(profileStringTable)
+	require.Equal(t, "", strs[0])
+	str := func(i uint64) string { return strs[i] }
+
+	var sampleTypes []string
+	for _, vt := range msg.messages(t, profileSampleType) {
+		sampleTypes = append(sampleTypes, str(vt.uint(valueTypeType))+"/"+str(vt.uint(valueTypeUnit)))
+	}
+	require.Equal(t, []string{"calls/count", "time/nanoseconds", "alloc_objects/count", "alloc_space/bytes"}, sampleTypes)
+	require.Equal(t, "time", str(msg.uint(profileDefaultSampleType)))
+
+	funcs := map[uint64]string{}
+	for _, fn := range msg.messages(t, profileFunction) {
+		funcs[fn.uint(functionID)] = str(fn.uint(functionName)) + " " + str(fn.uint(functionFilename))
+	}
+	require.ElementsMatch(t, []string{"fib main.mnk", "anonymous@4:24 main.mnk", "array.map <builtin>", "len <builtin>"}, values(funcs))
+	for _, loc := range msg.messages(t, profileLocation) {
+		line := decode(t, loc.bytes(locationLine)[0])
+		require.Equal(t, loc.uint(locationID), line.uint(lineFunctionID))
+	}
+
+	// The calls of each stack, innermost call first.
+	calls := map[string]uint64{}
+	for _, s := range msg.messages(t, profileSample) {
+		var stack []string
+		for _, id := range s.packed(t, sampleLocationID) {
+			stack = append(stack, strings.Fields(funcs[id])[0])
+		}
+		vs := s.packed(t, sampleValue)
+		require.Len(t, vs, 4)
+		calls[strings.Join(stack, " ")] += vs[0]
+	}
+	require.Equal(t, uint64(1), calls["array.map"])
+	require.Equal(t, uint64(1), calls["len"])
+	require.Equal(t, uint64(3), calls["anonymous@4:24 array.map"])
+	require.Equal(t, uint64(3), calls["fib anonymous@4:24 array.map"])
+	require.Equal(t, uint64(6), calls["fib fib anonymous@4:24 array.map"])
+	var total uint64
+	for _, n := range calls {
+		total += n
+	}
+	require.Equal(t, uint64(133+3+2), total)
+}
+
+func values(m map[uint64]string) []string {
+	var vs []string
+	for _, v := range m {
+		vs = append(vs, v)
+	}
+	return vs
+}
+
+// message is a decoded protocol buffer message, the values of its fields
+// being varints or bytes.
+type message map[int][]any
+
+func decode(t *testing.T, data []byte) message {
+	t.Helper()
+	m := message{}
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		require.Positive(t, n)
+		data = data[n:]
+		field := int(tag >> 3)
+		switch tag & 7 {
+		case wireVarint:
+			x, n := binary.Uvarint(data)
+			require.Positive(t, n)
+			data = data[n:]
+			m[field] = append(m[field], x)
+		case wireBytes:
+			l, n := binary.Uvarint(data)
+			require.Positive(t, n)
+			data = data[n:]
+			m[field] = append(m[field], data[:l])
+			data = data[l:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+	return m
+}
+
+func (m message) uint(field int) uint64 {
+	if len(m[field]) == 0 {
+		return 0
+	}
+	return m[field][0].(uint64)
+}
+
+func (m message) bytes(field int) [][]byte {
+	var bs [][]byte
+	for _, v := range m[field] {
+		bs = append(bs, v.([]byte))
+	}
+	return bs
+}
+
+func (m message) strings(field int) []string {
+	var strs []string
+	for _, b := range m.bytes(field) {
+		strs = append(strs, string(b))
+	}
+	return strs
+}
+
+func (m message) messages(t *testing.T, field int) []message {
+	var ms []message
+	for _, b := range m.bytes(field) {
+		ms = append(ms, decode(t, b))
+	}
+	return ms
+}
+
+func (m message) packed(t *testing.T, field int) []uint64 {
+	var xs []uint64
+	for _, b := range m.bytes(field) {
+		for len(b) > 0 {
+			x, n := binary.Uvarint(b)
+			require.Positive(t, n)
+			xs = append(xs, x)
+			b = b[n:]
+		}
+	}
+	return xs
+}
@@ -0,0 +1,41 @@
+package profiler
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// WriteText writes a report of the functions called to w, and then of the
+// builtins, each by decreasing exclusive time.
+func (p *Profiler) WriteText(w io.Writer) error {
+	var funcs, builtins []*Func
+	for _, f := range p.Funcs() {
+		if f.Builtin {
+			builtins = append(builtins, f)
+		} else {
+			funcs = append(funcs, f)
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "total time %s\n", round(p.Duration()))
+	for _, section := range []struct {
+		title string
+		funcs []*Func
+	}{{"function", funcs}, {"builtin", builtins}} {
+		if len(section.funcs) == 0 {
+			continue
+		}
+		fmt.Fprintf(tw, "\ncalls\ttotal\tself\tallocs\tbytes\t\t%s\n", section.title)
+		for _, f := range section.funcs {
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t\t%s\n", f.Calls, round(f.Inclusive), round(f.Exclusive), f.Allocs, f.Bytes, f)
+		}
+	}
+	return tw.Flush()
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
@@ -0,0 +1,129 @@
+package repl
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/EmilLaursen/wiig/lexer"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+// command is a REPL command, entered as `:name arg`.
+type command struct {
+	name string
+	arg  string // shown by :help, empty if the command takes no argument
+	help string
+	run  func(s *session, arg string)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"tokens", "EXPR", "show the tokens of EXPR", (*session).tokens},
+		{"ast", "EXPR", "show the syntax tree of EXPR", (*session).ast},
+		{"env", "", "list the bindings of the environment", (*session).listEnv},
+		{"time", "EXPR", "evaluate EXPR and show how long it took", (*session).time},
+		{"load", "FILE", "evaluate FILE in the environment", (*session).load},
+		{"reset", "", "start over with an empty environment", (*session).resetCommand},
+		{"type", "EXPR", "show the type of the value of EXPR", (*session).typeOf},
+		{"help", "", "list the commands", (*session).help},
+	}
+}
+
+// command runs line, a REPL command starting with ':'.
+func (s *session) command(line string) {
+	name, arg, _ := strings.Cut(line[1:], " ")
+	arg = strings.TrimSpace(arg)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		if c.arg != "" && arg == "" {
+			fmt.Fprintf(s.out, "usage: :%s %s\n", c.name, c.arg)
+			return
+		}
+		c.run(s, arg)
+		return
+	}
+	fmt.Fprintf(s.out, "unknown command :%s, see :help\n", name)
+}
+
+func (s *session) tokens(input string) {
+	l := lexer.New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%s %s %q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+}
+
+func (s *session) ast(input string) {
+	if program, ok := s.parse(input); ok {
+		printTree(s.out, program)
+	}
+}
+
+func (s *session) listEnv(string) {
+	for _, name := range s.env.Names() {
+		val, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, strings.TrimSpace(val.Inspect()))
+	}
+}
+
+func (s *session) time(input string) {
+	program, ok := s.parse(input)
+	if !ok {
+		return
+	}
+	start := time.Now()
+	val := s.interp.Eval(program, s.env)
+	elapsed := time.Since(start)
+	s.print(val)
+	fmt.Fprintf(s.out, "time: %s\n", elapsed)
+}
+
+func (s *session) load(file string) {
+	data, err := fs.ReadFile(s.interp.FS, file)
+	if err != nil {
+		fmt.Fprintf(s.out, "load: %s\n", err)
+		return
+	}
+	program, ok := s.parse(string(data))
+	if !ok {
+		return
+	}
+	switch val := s.interp.EvalFile(file, program, s.env).(type) {
+	case *object.Error, *object.Exit:
+		s.print(val)
+	}
+}
+
+func (s *session) resetCommand(string) {
+	s.reset()
+	fmt.Fprintln(s.out, "environment reset")
+}
+
+func (s *session) typeOf(input string) {
+	program, ok := s.parse(input)
+	if !ok {
+		return
+	}
+	val := s.interp.Eval(program, s.env)
+	if val == nil {
+		fmt.Fprintln(s.out, "no value")
+		return
+	}
+	fmt.Fprintln(s.out, val.Type())
+}
+
+func (s *session) help(string) {
+	for _, c := range commands {
+		usage := ":" + c.name
+		if c.arg != "" {
+			usage += " " + c.arg
+		}
+		fmt.Fprintf(s.out, "%-12s %s\n", usage, c.help)
+	}
+}
@@ -0,0 +1,364 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// errInterrupted is returned by readLine when the user presses Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// maxHistory is the number of history entries kept.
+const maxHistory = 1000
+
+// editor reads lines from a terminal with cursor movement, history and
+// completion. Keys:
+//
+//	Left, Right, Ctrl-B, Ctrl-F   move the cursor
+//	Home, End, Ctrl-A, Ctrl-E     move to the start or end of the line
+//	Up, Down                      recall history
+//	Backspace, Delete, Ctrl-D     delete a character
+//	Ctrl-W, Ctrl-U, Ctrl-K        delete the word before the cursor, or
+//	                              everything before or after it
+//	Ctrl-R                        search history backwards
+//	Tab                           complete the word before the cursor
+//	Ctrl-L                        clear the screen
+//	Ctrl-C                        abandon the line
+type editor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// raw switches the terminal to raw mode while a line is read, returning
+	// a function restoring it. Nil when in is not a terminal.
+	raw func() (func(), error)
+	// complete returns the candidates for completing prefix.
+	complete func(prefix string) []string
+
+	history []string
+	// histFile is appended each line added to history, if not empty.
+	histFile string
+
+	buf []rune
+	pos int
+}
+
+// readLine reads a line, without the trailing newline. It returns
+// errInterrupted on Ctrl-C, and io.EOF on Ctrl-D on an empty line.
+func (e *editor) readLine(prompt string) (string, error) {
+	if e.raw != nil {
+		if restore, err := e.raw(); err == nil {
+			defer restore()
+		}
+	}
+
+	e.buf, e.pos = e.buf[:0], 0
+	histIdx, saved := len(e.history), ""
+	setLine := func(s string) {
+		e.buf = []rune(s)
+		e.pos = len(e.buf)
+	}
+
+	e.refresh(prompt)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if len(e.buf) > 0 {
+				io.WriteString(e.out, "\n")
+				return e.accept(), nil
+			}
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			io.WriteString(e.out, "\n")
+			return e.accept(), nil
+		case ctrl('C'):
+			io.WriteString(e.out, "^C\n")
+			return "", errInterrupted
+		case ctrl('D'):
+			if len(e.buf) == 0 {
+				io.WriteString(e.out, "\n")
+				return "", io.EOF
+			}
+			e.delete(e.pos, e.pos+1)
+		case ctrl('A'):
+			e.pos = 0
+		case ctrl('E'):
+			e.pos = len(e.buf)
+		case ctrl('B'):
+			e.pos = max(e.pos-1, 0)
+		case ctrl('F'):
+			e.pos = min(e.pos+1, len(e.buf))
+		case 127, ctrl('H'):
+			if e.pos > 0 {
+				e.delete(e.pos-1, e.pos)
+			}
+		case ctrl('W'):
+			start := e.pos
+			for start > 0 && unicode.IsSpace(e.buf[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(e.buf[start-1]) {
+				start--
+			}
+			e.delete(start, e.pos)
+		case ctrl('U'):
+			e.delete(0, e.pos)
+		case ctrl('K'):
+			e.delete(e.pos, len(e.buf))
+		case ctrl('L'):
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case '\t':
+			e.completeWord()
+		case ctrl('R'):
+			if e.search() {
+				io.WriteString(e.out, "\n")
+				return e.accept(), nil
+			}
+		case 27:
+			switch e.escape() {
+			case 'A':
+				if histIdx > 0 {
+					if histIdx == len(e.history) {
+						saved = string(e.buf)
+					}
+					histIdx--
+					setLine(e.history[histIdx])
+				}
+			case 'B':
+				if histIdx < len(e.history) {
+					histIdx++
+					if histIdx == len(e.history) {
+						setLine(saved)
+					} else {
+						setLine(e.history[histIdx])
+					}
+				}
+			case 'C':
+				e.pos = min(e.pos+1, len(e.buf))
+			case 'D':
+				e.pos = max(e.pos-1, 0)
+			case 'H':
+				e.pos = 0
+			case 'F':
+				e.pos = len(e.buf)
+			case '~':
+				e.delete(e.pos, e.pos+1)
+			}
+		default:
+			if unicode.IsPrint(r) {
+				e.insert(string(r))
+			}
+		}
+		e.refresh(prompt)
+	}
+}
+
+func ctrl(key rune) rune { return key & 0x1f }
+
+// escape reads the rest of an escape sequence, returning the key as the
+// final byte of its ANSI sequence: A-D for arrows, H and F for Home and End,
+// '~' for Delete, or 0 for sequences not handled.
+func (e *editor) escape() rune {
+	r, _, err := e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0
+	}
+	var num string
+	for {
+		r, _, err = e.in.ReadRune()
+		if err != nil {
+			return 0
+		}
+		if r < '0' || r > '9' {
+			break
+		}
+		num += string(r)
+	}
+
+	switch {
+	case r != '~':
+		return r
+	case num == "1" || num == "7":
+		return 'H'
+	case num == "4" || num == "8":
+		return 'F'
+	case num == "3":
+		return '~'
+	default:
+		return 0
+	}
+}
+
+// refresh redraws the line with the cursor at pos.
+func (e *editor) refresh(prompt string) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(e.buf))
+	if n := len(e.buf) - e.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", n)
+	}
+}
+
+func (e *editor) insert(s string) {
+	rs := []rune(s)
+	e.buf = append(e.buf[:e.pos], append(rs, e.buf[e.pos:]...)...)
+	e.pos += len(rs)
+}
+
+// delete removes buf[from:to], clamped to the line.
+func (e *editor) delete(from, to int) {
+	to = min(to, len(e.buf))
+	if from >= to {
+		return
+	}
+	e.buf = append(e.buf[:from], e.buf[to:]...)
+	if e.pos > to {
+		e.pos -= to - from
+	} else if e.pos > from {
+		e.pos = from
+	}
+}
+
+// accept returns the line read, adding it to the history.
+func (e *editor) accept() string {
+	line := string(e.buf)
+	e.addHistory(line)
+	return line
+}
+
+func (e *editor) completeWord() {
+	if e.complete == nil {
+		return
+	}
+	start := e.pos
+	for start > 0 && isIdentRune(e.buf[start-1]) {
+		start--
+	}
+	prefix := string(e.buf[start:e.pos])
+	if prefix == "" {
+		return
+	}
+
+	candidates := e.complete(prefix)
+	if len(candidates) == 0 {
+		io.WriteString(e.out, "\a")
+		return
+	}
+	common := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(prefix) {
+		e.insert(common[len(prefix):])
+		return
+	}
+	fmt.Fprintf(e.out, "\n%s\n", strings.Join(candidates, "  "))
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9'
+}
+
+// search is the reverse history search started by Ctrl-R. Typing narrows
+// the search, Ctrl-R finds an older match, Enter submits the match and any
+// other key edits it. Ctrl-C and Ctrl-G cancel the search. It reports
+// whether the match was submitted.
+func (e *editor) search() bool {
+	orig, origPos := append([]rune{}, e.buf...), e.pos
+	query := ""
+	match := len(e.history)
+	find := func(from int) {
+		for i := min(from, len(e.history)-1); i >= 0; i-- {
+			if idx := strings.Index(e.history[i], query); idx >= 0 {
+				match = i
+				e.buf = []rune(e.history[i])
+				e.pos = len([]rune(e.history[i][:idx]))
+				return
+			}
+		}
+	}
+
+	for {
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%s': %s\x1b[K", query, string(e.buf))
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return false
+		}
+		switch {
+		case r == ctrl('R'):
+			find(match - 1)
+		case r == 127 || r == ctrl('H'):
+			if query != "" {
+				_, size := utf8.DecodeLastRuneInString(query)
+				query = query[:len(query)-size]
+				find(len(e.history))
+			}
+		case r == ctrl('C') || r == ctrl('G'):
+			e.buf, e.pos = orig, origPos
+			return false
+		case r == '\r' || r == '\n':
+			return true
+		case unicode.IsPrint(r):
+			query += string(r)
+			find(match)
+		default:
+			e.in.UnreadRune()
+			return false
+		}
+	}
+}
+
+func (e *editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+	if e.histFile == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(e.histFile), 0o700); err != nil {
+		return
+	}
+	f, err := os.OpenFile(e.histFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// loadHistory reads the history from histFile, truncating the file to the
+// last maxHistory lines. A missing file is an empty history.
+func (e *editor) loadHistory() {
+	data, err := os.ReadFile(e.histFile)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+		os.WriteFile(e.histFile, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	}
+	e.history = lines
+}
+
+// historyFile is where the REPL history is kept, in the user config
+// directory.
+func historyFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "monkey", "history")
+}
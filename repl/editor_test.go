@@ -0,0 +1,111 @@
+package repl
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEditor(input string, history ...string) *editor {
+	return &editor{
+		in:  bufio.NewReader(strings.NewReader(input)),
+		out: io.Discard,
+		complete: func(prefix string) []string {
+			var names []string
+			for _, name := range []string{"len", "let", "listDir", "puts"} {
+				if strings.HasPrefix(name, prefix) {
+					names = append(names, name)
+				}
+			}
+			return names
+		},
+		history: history,
+	}
+}
+
+func TestEditorKeys(t *testing.T) {
+	const (
+		left  = "\x1b[D"
+		right = "\x1b[C"
+		up    = "\x1b[A"
+		down  = "\x1b[B"
+		home  = "\x1b[H"
+		del   = "\x1b[3~"
+	)
+
+	tests := []struct {
+		name    string
+		input   string
+		history []string
+		want    string
+	}{
+		{"plain", "1 + 2\r", nil, "1 + 2"},
+		{"arrows", "13" + left + "2" + right + "4\r", nil, "1234"},
+		{"ctrl-a and ctrl-e", "bc\x01a\x05d\r", nil, "abcd"},
+		{"home and delete", "xab" + home + del + "\r", nil, "ab"},
+		{"backspace", "abc\x7f\x7fd\r", nil, "ad"},
+		{"ctrl-w", "let x = foo bar\x17baz\r", nil, "let x = foo baz"},
+		{"ctrl-u", "abc" + left + "\x15\r", nil, "c"},
+		{"ctrl-k", "abc" + left + left + "\x0b\r", nil, "a"},
+		{"ctrl-d deletes", "ab\x01\x04\r", nil, "b"},
+		{"history", up + up + "!\r", []string{"first", "second"}, "first!"},
+		{"history down", "draft" + up + down + "\r", []string{"first"}, "draft"},
+		{"reverse search", "\x12ir\r", []string{"first", "second", "third"}, "third"},
+		{"reverse search again", "\x12ir\x12\r", []string{"first", "second", "third"}, "first"},
+		{"reverse search edit", "\x12sec\x05!\r", []string{"first", "second"}, "second!"},
+		{"reverse search cancel", "x\x12sec\x07\r", []string{"second"}, "x"},
+		{"complete unique", "pu\t(1)\r", nil, "puts(1)"},
+		{"complete common prefix", "li\t\r", nil, "listDir"},
+		{"complete ambiguous", "le\t\r", nil, "le"},
+		{"end of input", "abc", nil, "abc"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.input, tt.history...)
+		got, err := e.readLine(PROMPT)
+		require.NoError(t, err, tt.name)
+		require.Equal(t, tt.want, got, tt.name)
+	}
+}
+
+func TestEditorInterruptAndEOF(t *testing.T) {
+	e := newTestEditor("abc\x03\x04")
+	_, err := e.readLine(PROMPT)
+	require.ErrorIs(t, err, errInterrupted)
+	_, err = e.readLine(PROMPT)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestEditorHistoryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "monkey", "history")
+
+	e := newTestEditor("let a = 1\r\rlet a = 1\rputs(a)\r")
+	e.histFile = file
+	for i := 0; i < 4; i++ {
+		_, err := e.readLine(PROMPT)
+		require.NoError(t, err)
+	}
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, "let a = 1\nputs(a)\n", string(data))
+
+	e = newTestEditor("\x1b[A\r")
+	e.histFile = file
+	e.loadHistory()
+	got, err := e.readLine(PROMPT)
+	require.NoError(t, err)
+	require.Equal(t, "puts(a)", got)
+}
+
+func TestComplete(t *testing.T) {
+	s := newSession(bufio.NewReader(strings.NewReader("")), io.Discard, nil)
+	s.evaluate("let length = 1; let lemon = 2;")
+	require.Equal(t, []string{"lemon", "len", "length", "let"}, s.complete("le"))
+	require.Equal(t, []string{"json"}, s.complete("js"))
+	require.Empty(t, s.complete("zz"))
+}
@@ -0,0 +1,62 @@
+package repl
+
+import (
+	"strings"
+
+	"github.com/EmilLaursen/wiig/lexer"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+// continuing are the tokens that cannot end a program, so input ending with
+// one of them continues on the next line.
+var continuing = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.BANG:     true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.EQ:       true,
+	token.NOT_EQ:   true,
+	token.LT:       true,
+	token.GT:       true,
+	token.NULLISH:  true,
+	token.OPTCHAIN: true,
+	token.COMMA:    true,
+	token.DOT:      true,
+	token.COLON:    true,
+	token.ARROW:    true,
+}
+
+// incomplete reports whether input needs more lines: it has unbalanced
+// brackets, ends with an operator or ends inside a string.
+func incomplete(input string) bool {
+	l := lexer.New(input)
+	depth := 0
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET, token.OPTINDEX:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+		last = tok
+	}
+
+	if depth > 0 || continuing[last.Type] {
+		return true
+	}
+	return last.Type == token.STRING && unterminated(input, last)
+}
+
+// unterminated reports whether the string token tok ran to the end of
+// input without a closing quote.
+func unterminated(input string, tok token.Token) bool {
+	off := 0
+	for line := 1; line < tok.Pos.Line; line++ {
+		off += strings.IndexByte(input[off:], '\n') + 1
+	}
+	off += tok.Pos.Column - 1
+	return input[off+1:] == tok.Literal
+}
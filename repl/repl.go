@@ -4,40 +4,258 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
 
+	"github.com/EmilLaursen/wiig/ast"
 	"github.com/EmilLaursen/wiig/eval"
 	"github.com/EmilLaursen/wiig/object"
 	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/token"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT      = ">> "
+	CONT_PROMPT = ".. "
+)
 
+// Start runs the REPL with every capability granted. Imports and file
+// builtins see the working directory. Programs write to out, and readLine
+// reads the lines following the one being evaluated from in.
+//
+// Input spanning several lines is evaluated once it is complete, and
+// Ctrl-C abandons the input read so far, or stops the program being
+// evaluated. When in is a terminal, lines are read with an editor supporting
+// history and completion, see editor.
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnv()
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		reader := bufio.NewReader(f)
+		s := newSession(reader, out, interrupts)
+		ed := &editor{
+			in:       reader,
+			out:      out,
+			raw:      func() (func(), error) { return makeRaw(f.Fd()) },
+			complete: s.complete,
+			histFile: historyFile(),
+		}
+		ed.loadHistory()
+		loop(s, ed)
+		return
+	}
+	run(in, out, interrupts)
+}
+
+// lineReader reads the lines of input. readLine prints prompt and returns
+// the next line without its newline, or errInterrupted on Ctrl-C.
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// session is the state of a REPL, replaced by :reset.
+type session struct {
+	interp *eval.Interpreter
+	env    *object.Environment
+	in     *bufio.Reader
+	out    io.Writer
+	// exited is set once a program calls exit().
+	exited bool
+	// stop stops programs when interrupted while they are evaluated.
+	stop *interruptHook
+}
+
+func newSession(in *bufio.Reader, out io.Writer, interrupts <-chan os.Signal) *session {
+	s := &session{in: in, out: out, stop: &interruptHook{interrupts: interrupts}}
+	s.reset()
+	return s
+}
+
+func (s *session) reset() {
+	s.env = object.NewEnv()
+	s.interp = eval.New()
+	// Opening the working directory as a root keeps symlinks from escaping
+	// it. Without it builtins and imports have no filesystem.
+	if root, err := os.OpenRoot("."); err == nil {
+		s.interp.FS = root.FS()
+	}
+	s.interp.Allow(eval.AllCapabilities...)
+	s.interp.Stdout = s.out
+	s.interp.Stderr = s.out
+	s.interp.Stdin = s.in
+	s.interp.Hook = s.stop
+}
+
+func run(in io.Reader, out io.Writer, interrupts <-chan os.Signal) {
+	reader := bufio.NewReader(in)
+	lr := newPlainReader(reader, out, interrupts)
+	defer lr.close()
+	loop(newSession(reader, out, interrupts), lr)
+}
 
+// loop reads and evaluates input until the end of input or exit().
+func loop(s *session, lr lineReader) {
+	var buf string
 	for {
-		fmt.Fprint(out, PROMPT)
-		scanned := scanner.Scan()
+		prompt := PROMPT
+		if buf != "" {
+			prompt = CONT_PROMPT
+		}
+		line, err := lr.readLine(prompt)
+		if err == errInterrupted {
+			buf = ""
+			continue
+		}
+		s.stop.interrupted = false
 
-		if !scanned {
-			return
+		if buf == "" && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			s.command(strings.TrimSpace(line))
+			if err != nil || s.exited {
+				return
+			}
+			continue
 		}
 
-		line := scanner.Text()
-		p := parser.FromInput(line)
-		program := p.ParseProgram()
-		if len(p.Errors()) > 0 {
-			printParserErrors(out, p.Errors())
+		if err != nil && line == "" && buf == "" {
+			return
+		}
+		buf += line + "\n"
+		if err == nil && incomplete(buf) {
 			continue
 		}
 
-		val := eval.Eval(program, env)
-		if val != nil {
-			io.WriteString(out, val.Inspect())
-			io.WriteString(out, "\n")
+		s.evaluate(buf)
+		buf = ""
+		if err != nil || s.exited {
+			return
+		}
+	}
+}
+
+// complete returns the keywords, builtins and bindings starting with prefix.
+func (s *session) complete(prefix string) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, list := range [][]string{token.Keywords(), eval.BuiltinNames(), s.env.Names()} {
+		for _, name := range list {
+			if strings.HasPrefix(name, prefix) && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// plainReader reads lines when in is not a terminal. Lines are read in the
+// background so interrupts are seen while waiting for input. A line is only
+// read when asked for, leaving the rest of in to readLine while a program
+// is evaluated.
+type plainReader struct {
+	out        io.Writer
+	interrupts <-chan os.Signal
+	want       chan struct{}
+	lines      chan readResult
+	pending    bool
+}
+
+type readResult struct {
+	line string
+	err  error
+}
+
+func newPlainReader(in *bufio.Reader, out io.Writer, interrupts <-chan os.Signal) *plainReader {
+	r := &plainReader{
+		out:        out,
+		interrupts: interrupts,
+		want:       make(chan struct{}),
+		lines:      make(chan readResult),
+	}
+	go func() {
+		for range r.want {
+			line, err := in.ReadString('\n')
+			r.lines <- readResult{line, err}
+		}
+	}()
+	return r
+}
+
+func (r *plainReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.pending {
+		r.want <- struct{}{}
+		r.pending = true
+	}
+
+	select {
+	case <-r.interrupts:
+		io.WriteString(r.out, "\n")
+		return "", errInterrupted
+	case res := <-r.lines:
+		r.pending = false
+		return strings.TrimSuffix(res.line, "\n"), res.err
+	}
+}
+
+func (r *plainReader) close() {
+	close(r.want)
+}
+
+// interruptHook stops the program being evaluated once an interrupt is
+// received, failing every node evaluated after it so try cannot catch it.
+type interruptHook struct {
+	interrupts  <-chan os.Signal
+	interrupted bool
+}
+
+func (h *interruptHook) Before(ast.Node, *object.Environment) object.Object {
+	if !h.interrupted {
+		select {
+		case <-h.interrupts:
+			h.interrupted = true
+		default:
+			return nil
 		}
 	}
+	return &object.Error{Msg: "interrupted"}
+}
+
+func (h *interruptHook) Enter(object.Object, []object.Object) {}
+func (h *interruptHook) Leave(object.Object, object.Object)   {}
+
+// parse parses input, printing any errors.
+func (s *session) parse(input string) (*ast.Program, bool) {
+	p := parser.FromInput(input)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		printParserErrors(s.out, p.Errors())
+		return nil, false
+	}
+	return program, true
+}
+
+func (s *session) evaluate(input string) {
+	program, ok := s.parse(input)
+	if !ok {
+		return
+	}
+	s.print(s.interp.Eval(program, s.env))
+}
+
+func (s *session) print(val object.Object) {
+	if _, ok := val.(*object.Exit); ok {
+		s.exited = true
+		return
+	}
+	if val != nil {
+		io.WriteString(s.out, val.Inspect())
+		io.WriteString(s.out, "\n")
+	}
 }
 
 func printParserErrors(out io.Writer, errors []string) {
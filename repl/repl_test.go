@@ -0,0 +1,143 @@
+package repl
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{`1 + 2`, false},
+		{`let f = fn(x) {`, true},
+		{"let f = fn(x) {\n x }", false},
+		{`[1, 2`, true},
+		{`f(1,`, true},
+		{`{"a": `, true},
+		{`1 +`, true},
+		{`let x =`, true},
+		{`a ??`, true},
+		{`h.`, true},
+		{`x)`, false},
+		{`"abc`, true},
+		{`let s = "`, true},
+		{`let s = ""`, false},
+		{"\"a\nb\"", false},
+		{"let s = \"a\nb", true},
+		{``, false},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.want, incomplete(tt.input), tt.input)
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	var out strings.Builder
+	run(strings.NewReader("let f = fn(x) {\n  x *\n  2\n};\nf(\n21)\n[1,\n"), &out, nil)
+	require.Equal(t, ">> .. .. .. >> .. 42\n>> .. "+
+		"\tno prefix parse function for EOF found\n"+
+		"\texpected next token to be ], got EOF instead\n", out.String())
+}
+
+// promptWriter collects output, signalling each prompt written, and each
+// line written by puts on lines if not nil.
+type promptWriter struct {
+	mu      sync.Mutex
+	out     strings.Builder
+	prompts chan string
+	lines   chan string
+}
+
+func (w *promptWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if s := string(p); s == PROMPT || s == CONT_PROMPT {
+		w.prompts <- s
+	} else if w.lines != nil && strings.HasSuffix(s, "\n") {
+		w.lines <- s
+	}
+	return w.out.Write(p)
+}
+
+func TestInterruptAbandonsInput(t *testing.T) {
+	r, w := io.Pipe()
+	out := &promptWriter{prompts: make(chan string, 10)}
+	interrupts := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		run(r, out, interrupts)
+		close(done)
+	}()
+
+	require.Equal(t, PROMPT, <-out.prompts)
+	io.WriteString(w, "let x = [1,\n")
+	require.Equal(t, CONT_PROMPT, <-out.prompts)
+	interrupts <- os.Interrupt
+	require.Equal(t, PROMPT, <-out.prompts)
+	io.WriteString(w, "1 + 1\n")
+	w.Close()
+	<-done
+	require.Equal(t, ">> .. \n>> 2\n>> ", out.out.String())
+}
+
+func TestInterruptStopsEvaluation(t *testing.T) {
+	r, w := io.Pipe()
+	out := &promptWriter{prompts: make(chan string, 10), lines: make(chan string, 10)}
+	interrupts := make(chan os.Signal)
+	done := make(chan struct{})
+	go func() {
+		run(r, out, interrupts)
+		close(done)
+	}()
+
+	require.Equal(t, PROMPT, <-out.prompts)
+	io.WriteString(w, `let f = fn(n) { if (n < 2) { n } else { f(n - 1) + f(n - 2) } }; puts("started"); try { f(100) } catch (e) { "caught" }`+"\n")
+	require.Equal(t, "started\n", <-out.lines)
+	interrupts <- os.Interrupt
+	require.Equal(t, PROMPT, <-out.prompts)
+	io.WriteString(w, "f(10)\n")
+	w.Close()
+	<-done
+	require.Equal(t, ">> started\nERROR: interrupted\n>> 55\n>> ", out.out.String())
+}
+
+func TestCommands(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{":tokens let x = \"a\";", "1:1 LET \"let\"\n1:5 IDENT \"x\"\n1:7 = \"=\"\n1:9 STRING \"a\"\n1:12 ; \";\"\n"},
+		{":ast -a + b[1]", "Program (1:1)\n" +
+			"  Statements[0]: ExpressionStatement (1:1)\n" +
+			"    Expression: InfixExpression \"+\" (1:4)\n" +
+			"      Left: PrefixExpression \"-\" (1:1)\n" +
+			"        Right: Identifier a (1:2)\n" +
+			"      Right: IndexExpression (1:7)\n" +
+			"        Left: Identifier b (1:6)\n" +
+			"        Index: IntegerLiteral 1 (1:8)\n"},
+		{":ast let = 1", "\texpected next token to be IDENT, got = instead\n\tno prefix parse function for = found\n"},
+		{"let b = 2; let a = [1];\n:env", "a = [1]\nb = 2\n"},
+		{":type {}", "HASH\n"},
+		{":type let x = 1;", "no value\n"},
+		{"let x = 1;\n:reset\nx", "environment reset\nERROR: identifier not found: x\n"},
+		{":load missing.mnk", "load: openat missing.mnk: no such file or directory\n"},
+		{":tokens", "usage: :tokens EXPR\n"},
+		{":nope", "unknown command :nope, see :help\n"},
+		{"exit(2)\n1", ""},
+	}
+
+	for _, tt := range tests {
+		var out strings.Builder
+		run(strings.NewReader(tt.input+"\n"), &out, nil)
+		got := strings.ReplaceAll(out.String(), PROMPT, "")
+		require.Equal(t, tt.want, got, tt.input)
+	}
+}
@@ -0,0 +1,75 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/EmilLaursen/wiig/ast"
+)
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// printTree writes node as an indented tree, one node per line. Nodes
+// without children are printed with their source form, others with their
+// string and boolean fields, e.g. the operator of an infix expression.
+func printTree(w io.Writer, node ast.Node) {
+	printNode(w, "", node, 0)
+}
+
+func printNode(w io.Writer, label string, node ast.Node, depth int) {
+	v := reflect.ValueOf(node).Elem()
+	t := v.Type()
+
+	type child struct {
+		label string
+		node  ast.Node
+	}
+	var children []child
+	var attrs []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		switch {
+		case f.Name == "Token":
+		case f.Type.Implements(nodeType):
+			if !fv.IsNil() {
+				children = append(children, child{f.Name, fv.Interface().(ast.Node)})
+			}
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Implements(nodeType):
+			for j := 0; j < fv.Len(); j++ {
+				children = append(children, child{fmt.Sprintf("%s[%d]", f.Name, j), fv.Index(j).Interface().(ast.Node)})
+			}
+		case f.Type.Kind() == reflect.String && fv.String() != "":
+			attrs = append(attrs, fmt.Sprintf("%q", fv.String()))
+		case f.Type.Kind() == reflect.Bool && fv.Bool():
+			attrs = append(attrs, f.Name)
+		}
+	}
+	// Pairs of hash literals are shown in source order, by Keys.
+	if hash, ok := node.(*ast.HashLiteral); ok {
+		children = children[:0]
+		for j, k := range hash.Keys {
+			children = append(children,
+				child{fmt.Sprintf("Keys[%d]", j), k},
+				child{fmt.Sprintf("Values[%d]", j), hash.Pairs[k]})
+		}
+	}
+
+	line := strings.Repeat("  ", depth)
+	if label != "" {
+		line += label + ": "
+	}
+	line += t.Name()
+	if len(children) == 0 {
+		line += " " + node.String()
+	} else if len(attrs) > 0 {
+		line += " " + strings.Join(attrs, " ")
+	}
+	fmt.Fprintf(w, "%s (%s)\n", line, node.Pos())
+
+	for _, c := range children {
+		printNode(w, c.label, c.node, depth+1)
+	}
+}
@@ -0,0 +1,124 @@
+// Package resolver gives the local variables of Monkey programs lexical
+// addresses. Each function and catch clause gets a slot per parameter and
+// let in it, and identifiers naming them are annotated with the number of
+// functions and catch clauses out their variable is, and its slot. The
+// evaluator then finds local variables by index rather than by name.
+//
+// Blocks of if and try expressions share the variables of the enclosing
+// function, as they are evaluated in its environment. Top level bindings
+// are globals, looked up by name, like builtins.
+package resolver
+
+import "github.com/EmilLaursen/wiig/ast"
+
+// Resolve annotates the identifiers, function literals and try expressions
+// of program, which must have parsed without errors.
+func Resolve(program *ast.Program) {
+	r := &resolver{}
+	for _, stmt := range program.Statements {
+		r.node(stmt)
+	}
+}
+
+// scope holds the variables of a function or catch clause.
+type scope struct {
+	names []string
+	slots map[string]int
+}
+
+func (s *scope) add(name string) {
+	if _, ok := s.slots[name]; !ok {
+		s.slots[name] = len(s.names)
+		s.names = append(s.names, name)
+	}
+}
+
+type resolver struct {
+	// scopes are the enclosing functions and catch clauses, innermost last.
+	scopes []*scope
+}
+
+// push starts a scope for params and the lets of body.
+func (r *resolver) push(params []*ast.Identifier, body *ast.BlockStatement) *scope {
+	s := &scope{slots: map[string]int{}}
+	for _, p := range params {
+		s.add(p.Value)
+	}
+	var visit func(ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			s.add(n.Name.Value)
+		case *ast.ImportStatement:
+			s.add(n.Name.Value)
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.TryExpression:
+			ast.Inspect(n.Block, visit)
+			if n.Finally != nil {
+				ast.Inspect(n.Finally, visit)
+			}
+			return false
+		}
+		return true
+	}
+	ast.Inspect(body, visit)
+
+	r.scopes = append(r.scopes, s)
+	for _, p := range params {
+		r.identifier(p)
+	}
+	return s
+}
+
+func (r *resolver) pop() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+// identifier annotates ident with the address of the innermost variable
+// named by it, if it is local.
+func (r *resolver) identifier(ident *ast.Identifier) {
+	for depth := 0; depth < len(r.scopes); depth++ {
+		s := r.scopes[len(r.scopes)-1-depth]
+		if slot, ok := s.slots[ident.Value]; ok {
+			ident.Local, ident.Depth, ident.Slot = true, depth, slot
+			return
+		}
+	}
+}
+
+func (r *resolver) node(n ast.Node) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Identifier:
+			r.identifier(n)
+		case *ast.MemberExpression:
+			// The property is not a variable.
+			r.node(n.Object)
+			return false
+		case *ast.FunctionLiteral:
+			s := r.push(n.Params, n.Body)
+			n.Locals = s.names
+			r.node(n.Body)
+			r.pop()
+			return false
+		case *ast.TryExpression:
+			r.node(n.Block)
+			if n.Catch != nil {
+				var params []*ast.Identifier
+				if n.Param != nil {
+					params = append(params, n.Param)
+				}
+				s := r.push(params, n.Catch)
+				n.CatchLocals = s.names
+				r.node(n.Catch)
+				r.pop()
+			}
+			if n.Finally != nil {
+				r.node(n.Finally)
+			}
+			return false
+		}
+		return true
+	})
+}
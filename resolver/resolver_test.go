@@ -0,0 +1,72 @@
+package resolver_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	input := `
+let g = 1;
+let f = fn(a, b) {
+    let c = a;
+    if (b) { let d = g };
+    fn(a) { a + b + c + d + h.x };
+    try { let t = 1 } catch (e) { let u = e; t + u };
+    d
+};
+`
+	p := parser.FromInput(input)
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors())
+
+	var got []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Identifier:
+			if n.Local {
+				got = append(got, fmt.Sprintf("%s %s %d.%d", n.Pos(), n.Value, n.Depth, n.Slot))
+			} else {
+				got = append(got, fmt.Sprintf("%s %s global", n.Pos(), n.Value))
+			}
+		case *ast.FunctionLiteral:
+			got = append(got, fmt.Sprintf("%s fn %v", n.Pos(), n.Locals))
+		case *ast.TryExpression:
+			got = append(got, fmt.Sprintf("%s catch %v", n.Pos(), n.CatchLocals))
+		}
+		return true
+	})
+	want := []string{
+		"2:5 g global",
+		"3:5 f global",
+		"3:9 fn [a b c d t]",
+		"3:12 a 0.0",
+		"3:15 b 0.1",
+		"4:9 c 0.2",
+		"4:13 a 0.0",
+		"5:9 b 0.1",
+		"5:18 d 0.3",
+		"5:22 g global",
+		"6:5 fn [a]",
+		"6:8 a 0.0",
+		"6:13 a 0.0",
+		"6:17 b 1.1",
+		"6:21 c 1.2",
+		"6:25 d 1.3",
+		"6:29 h global",
+		"6:31 x global", // a property, not a variable
+		"7:5 catch [e u]",
+		"7:15 t 0.4",
+		"7:30 e 0.0",
+		"7:39 u 0.1",
+		"7:43 e 0.0",
+		"7:46 t 1.4",
+		"7:50 u 0.1",
+		"8:5 d 0.3",
+	}
+	require.Equal(t, want, got)
+}
@@ -0,0 +1,242 @@
+// Package scope resolves the identifiers of Monkey programs to the lets,
+// parameters, imports and catch clauses binding them, for tools such as
+// the language server and vet. Unlike package resolver, which addresses the
+// variables of programs about to be evaluated, it accepts programs with
+// parse errors, skipping what failed to parse.
+//
+// Function literals and catch clauses introduce scopes; other blocks share
+// the enclosing one, as they do during evaluation. Within a scope a name
+// refers to the latest binding defined before it. Bindings defined later
+// are visible from the functions of the scope, which may run once they are
+// defined.
+package scope
+
+import (
+	"math"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+// Kind is the construct introducing a binding.
+type Kind int
+
+const (
+	Let Kind = iota
+	Param
+	Import
+	Catch
+)
+
+// Binding is a name introduced by a let statement, function parameter,
+// import or catch clause, with the identifiers referring to it.
+type Binding struct {
+	Kind  Kind
+	Ident *ast.Identifier
+	// Node introduces the binding: the let or import statement, or the
+	// function literal or try expression of a parameter.
+	Node ast.Node
+	// Exported is set for the lets of export statements.
+	Exported bool
+	Refs     []*ast.Identifier
+}
+
+// Scope holds the bindings visible from Start to End.
+type Scope struct {
+	Parent *Scope
+	// Function is set for the scope of a function literal, whose body may
+	// run after the enclosing scopes are complete.
+	Function   bool
+	Start, End token.Position
+	Bindings   []*Binding
+}
+
+// Contains reports whether pos is within s.
+func (s *Scope) Contains(pos token.Position) bool {
+	return !before(pos, s.Start) && !before(s.End, pos)
+}
+
+// lookup finds the binding of ident in s alone: the latest defined before
+// ident, or, when later ones are visible, the first defined after it.
+func (s *Scope) lookup(ident *ast.Identifier, later bool) *Binding {
+	var latest, first *Binding
+	for _, b := range s.Bindings {
+		if b.Ident.Value != ident.Value {
+			continue
+		}
+		if before(b.Ident.Pos(), ident.Pos()) {
+			latest = b
+		} else if first == nil {
+			first = b
+		}
+	}
+	if latest != nil || !later {
+		return latest
+	}
+	return first
+}
+
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// Use is an identifier naming a variable, defining or referring to it, and
+// its binding, nil for builtins and undefined names.
+type Use struct {
+	Ident   *ast.Identifier
+	Binding *Binding
+}
+
+// Info is the resolution of the identifiers of a program.
+type Info struct {
+	// Scopes are the scopes of the program, enclosing ones first.
+	Scopes []*Scope
+	// Uses are the identifiers naming variables, definitions included.
+	Uses     []Use
+	bindings map[*ast.Identifier]*Binding
+}
+
+// Resolve resolves the identifiers of program.
+func Resolve(program *ast.Program) *Info {
+	r := &resolver{info: &Info{bindings: map[*ast.Identifier]*Binding{}}}
+	root := r.newScope(nil, token.Position{Line: 1, Column: 1}, token.Position{Line: math.MaxInt, Column: math.MaxInt})
+	r.walk(program, root)
+	for len(r.deferred) > 0 {
+		d := r.deferred[0]
+		r.deferred = r.deferred[1:]
+		r.walk(d.body, d.scope)
+	}
+	return r.info
+}
+
+// BindingOf returns the binding ident defines or refers to, nil for
+// builtins, undefined names and identifiers not naming variables.
+func (info *Info) BindingOf(ident *ast.Identifier) *Binding {
+	return info.bindings[ident]
+}
+
+// UseAt returns the use of the identifier at pos, or nil.
+func (info *Info) UseAt(pos token.Position) *Use {
+	for i, u := range info.Uses {
+		p := u.Ident.Pos()
+		if p.Line == pos.Line && p.Column <= pos.Column && pos.Column <= p.Column+len(u.Ident.Value) {
+			return &info.Uses[i]
+		}
+	}
+	return nil
+}
+
+// Visible returns the bindings of the scopes containing pos, innermost
+// first.
+func (info *Info) Visible(pos token.Position) []*Binding {
+	var bs []*Binding
+	for i := len(info.Scopes) - 1; i >= 0; i-- {
+		if s := info.Scopes[i]; s.Contains(pos) {
+			bs = append(bs, s.Bindings...)
+		}
+	}
+	return bs
+}
+
+type resolver struct {
+	info *Info
+	// deferred function bodies are walked once their enclosing scope is
+	// complete, so they see bindings defined after them.
+	deferred []deferredBody
+}
+
+type deferredBody struct {
+	body  *ast.BlockStatement
+	scope *Scope
+}
+
+func (r *resolver) newScope(parent *Scope, start, end token.Position) *Scope {
+	s := &Scope{Parent: parent, Start: start, End: end}
+	r.info.Scopes = append(r.info.Scopes, s)
+	return s
+}
+
+func (r *resolver) define(s *Scope, b *Binding) {
+	s.Bindings = append(s.Bindings, b)
+	r.info.Uses = append(r.info.Uses, Use{b.Ident, b})
+	r.info.bindings[b.Ident] = b
+}
+
+// refer resolves ident from s. Bindings defined after ident are only
+// visible beyond the function containing it.
+func (r *resolver) refer(ident *ast.Identifier, s *Scope) {
+	later := false
+	for ; s != nil; s = s.Parent {
+		if b := s.lookup(ident, later); b != nil {
+			b.Refs = append(b.Refs, ident)
+			r.info.Uses = append(r.info.Uses, Use{ident, b})
+			r.info.bindings[ident] = b
+			return
+		}
+		later = later || s.Function
+	}
+	r.info.Uses = append(r.info.Uses, Use{ident, nil})
+}
+
+func (r *resolver) walk(node ast.Node, s *Scope) {
+	switch n := node.(type) {
+	case *ast.LetStatement:
+		r.let(s, n, false)
+	case *ast.ExportStatement:
+		if n.Let != nil {
+			r.let(s, n.Let, true)
+		}
+	case *ast.ImportStatement:
+		if n.Name != nil {
+			r.define(s, &Binding{Kind: Import, Ident: n.Name, Node: n})
+		}
+	case *ast.FunctionLiteral:
+		end := token.Position{Line: math.MaxInt, Column: math.MaxInt}
+		if n.Body != nil {
+			end = n.Body.Rbrace
+		}
+		fs := r.newScope(s, n.Pos(), end)
+		fs.Function = true
+		for _, p := range n.Params {
+			r.define(fs, &Binding{Kind: Param, Ident: p, Node: n})
+		}
+		if n.Body != nil {
+			r.deferred = append(r.deferred, deferredBody{n.Body, fs})
+		}
+	case *ast.TryExpression:
+		if n.Block != nil {
+			r.walk(n.Block, s)
+		}
+		if n.Catch != nil {
+			cs := r.newScope(s, n.Catch.Pos(), n.Catch.Rbrace)
+			if n.Param != nil {
+				cs.Start = n.Param.Pos()
+				r.define(cs, &Binding{Kind: Catch, Ident: n.Param, Node: n})
+			}
+			r.walk(n.Catch, cs)
+		}
+		if n.Finally != nil {
+			r.walk(n.Finally, s)
+		}
+	case *ast.MemberExpression:
+		// The property is not a variable.
+		if n.Object != nil {
+			r.walk(n.Object, s)
+		}
+	case *ast.Identifier:
+		r.refer(n, s)
+	default:
+		for _, c := range ast.Children(node) {
+			r.walk(c, s)
+		}
+	}
+}
+
+func (r *resolver) let(s *Scope, let *ast.LetStatement, exported bool) {
+	if let.Value != nil {
+		r.walk(let.Value, s)
+	}
+	if let.Name != nil {
+		r.define(s, &Binding{Kind: Let, Ident: let.Name, Node: let, Exported: exported})
+	}
+}
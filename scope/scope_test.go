@@ -0,0 +1,67 @@
+package scope_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/scope"
+	"github.com/EmilLaursen/wiig/token"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	input := `
+import "m" as m;
+let f = fn(a) { g(a) + m.x + h };
+let g = fn(b) { try { b } catch (e) { e } };
+export let k = f(1) + g;
+let g = 2;
+`
+	p := parser.FromInput(input)
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors())
+
+	info := scope.Resolve(program)
+	var got []string
+	for _, u := range info.Uses {
+		if u.Binding == nil {
+			got = append(got, fmt.Sprintf("%s %s -", u.Ident.Pos(), u.Ident.Value))
+			continue
+		}
+		b := u.Binding
+		got = append(got, fmt.Sprintf("%s %s %s refs=%d exported=%t", u.Ident.Pos(), u.Ident.Value, b.Ident.Pos(), len(b.Refs), b.Exported))
+	}
+	require.Equal(t, []string{
+		"2:15 m 2:15 refs=1 exported=false",
+		"3:12 a 3:12 refs=1 exported=false",
+		"3:5 f 3:5 refs=1 exported=false",
+		"4:12 b 4:12 refs=1 exported=false",
+		"4:5 g 4:5 refs=2 exported=false",
+		"5:16 f 3:5 refs=1 exported=false",
+		// The first g, defined when k is, not the second.
+		"5:23 g 4:5 refs=2 exported=false",
+		"5:12 k 5:12 refs=0 exported=true",
+		"6:5 g 6:5 refs=0 exported=false",
+		// The body of f sees the g defined after it.
+		"3:17 g 4:5 refs=2 exported=false",
+		"3:19 a 3:12 refs=1 exported=false",
+		"3:24 m 2:15 refs=1 exported=false",
+		"3:30 h -",
+		"4:23 b 4:12 refs=1 exported=false",
+		"4:34 e 4:34 refs=1 exported=false",
+		"4:39 e 4:34 refs=1 exported=false",
+	}, got)
+
+	u := info.UseAt(token.Position{Line: 3, Column: 20})
+	require.NotNil(t, u)
+	require.Equal(t, "a", u.Ident.Value)
+	require.Equal(t, scope.Param, u.Binding.Kind)
+	require.Same(t, u.Binding, info.BindingOf(u.Ident))
+
+	var visible []string
+	for _, b := range info.Visible(token.Position{Line: 4, Column: 39}) {
+		visible = append(visible, b.Ident.Value)
+	}
+	require.Equal(t, []string{"e", "b", "m", "f", "g", "k", "g"}, visible)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/testrunner"
+)
+
+// testFiles implements `monkey test`, returning the exit status: 1 when a
+// test fails or a test file fails to parse.
+func testFiles(argv []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	allow := flags.String("allow", "", "comma separated capabilities granted to the tests")
+	verbose := flags.Bool("v", false, "print the passed tests and their output too")
+	match := flags.String("run", "", "run only the tests matching `regexp`")
+	junit := flags.String("junit", "", "write the results as JUnit XML to `file`")
+	if err := flags.Parse(argv); err != nil {
+		return 2
+	}
+	caps, err := eval.ParseCapabilities(*allow)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	runner := &testrunner.Runner{
+		NewInterpreter: func(out io.Writer) (*eval.Interpreter, error) {
+			return newInterpreter(caps, strings.NewReader(""), out, out)
+		},
+	}
+	if *match != "" {
+		if runner.Match, err = regexp.Compile(*match); err != nil {
+			fmt.Fprintf(stderr, "-run: %s\n", err)
+			return 2
+		}
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	files, err := findTestFiles(paths)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(stdout, "no test files")
+		return 0
+	}
+
+	status := 0
+	var suites []*testrunner.Suite
+	for _, file := range files {
+		name, src, err := readScript(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "read file: %s\n", err)
+			return 1
+		}
+		suite := runner.RunFile(name, src)
+		if !suite.Passed() {
+			status = 1
+		}
+		suites = append(suites, suite)
+	}
+
+	testrunner.WriteText(stdout, suites, *verbose)
+	if *junit != "" {
+		f, err := os.Create(*junit)
+		if err != nil {
+			fmt.Fprintf(stderr, "junit: %s\n", err)
+			return 1
+		}
+		err = testrunner.WriteJUnit(f, suites)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "junit: %s\n", err)
+			return 1
+		}
+	}
+	return status
+}
+
+// findTestFiles returns the files of paths, and the test files in the
+// directories of paths and below, skipping hidden directories.
+func findTestFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && path != p && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && strings.HasSuffix(d.Name(), testrunner.Suffix) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTest(t *testing.T) {
+	// Test files are read relative to the working directory.
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	dir, err := os.MkdirTemp(wd, "test")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	name := filepath.Base(dir)
+
+	write := func(file, src string) {
+		path := filepath.Join(dir, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+		require.NoError(t, os.WriteFile(path, []byte(src), 0o600))
+	}
+	write("a_test.mnk", "let test_ok = fn() { assert(true) };\nlet test_bad = fn() { assert(1 > 2, \"order\") }")
+	write("b.mnk", "let test_not_run = fn() { assert(false) }")
+	write(".hidden/c_test.mnk", "let test_hidden = fn() { assert(false) }")
+	write("sub/d_test.mnk", "let test_sub = fn() { puts(\"in sub\") }")
+
+	junit := filepath.Join(dir, "junit.xml")
+	tests := []struct {
+		argv   []string
+		status int
+		stdout string
+		stderr string
+	}{
+		{[]string{name}, 1, "--- FAIL: test_bad\n    " + name + "/a_test.mnk:2:29: assert failed: order\n    \tat assert (" + name + "/a_test.mnk:2:23)\nFAIL\t" + name + "/a_test.mnk\t1 passed, 1 failed\nok  \t" + name + "/sub/d_test.mnk\t1 passed\nFAIL: 2 passed, 1 failed\n", ""},
+		{[]string{"-v", "-run", "sub", name + "/sub"}, 0, "--- PASS: test_sub\n    in sub\nok  \t" + name + "/sub/d_test.mnk\t1 passed\nPASS: 1 passed, 0 failed\n", ""},
+		{[]string{"-run", "ok", "-junit", junit, name + "/a_test.mnk", name + "/b.mnk"}, 0, "ok  \t" + name + "/a_test.mnk\t1 passed\nok  \t" + name + "/b.mnk\t[no tests]\nPASS: 1 passed, 0 failed\n", ""},
+		{[]string{"examples"}, 0, "ok  \texamples/lib/collections_test.mnk\t3 passed\nPASS: 3 passed, 0 failed\n", ""},
+		{[]string{"benchmarks"}, 0, "no test files\n", ""},
+		{[]string{"missing"}, 1, "", "stat missing: no such file or directory\n"},
+		{[]string{"-run", "("}, 2, "", "-run: error parsing regexp: missing closing ): `(`\n"},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr strings.Builder
+		status := testFiles(tt.argv, &stdout, &stderr)
+		require.Equal(t, tt.status, status, tt.argv)
+		require.Equal(t, tt.stdout, stdout.String(), tt.argv)
+		require.Equal(t, tt.stderr, stderr.String(), tt.argv)
+	}
+
+	data, err := os.ReadFile(junit)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `<testcase name="test_ok" classname="`+name+`/a_test.mnk"`)
+}
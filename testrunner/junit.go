@@ -0,0 +1,106 @@
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// The JUnit XML format, as read by CI servers.
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results of suites to w as JUnit XML, a test suite
+// per file. A file failing to parse is reported as a test case with an
+// error, named after the file.
+func WriteJUnit(w io.Writer, suites []*Suite) error {
+	all := junitSuites{}
+	var total time.Duration
+	for _, s := range suites {
+		js := junitSuite{Name: s.File, Time: seconds(s.Time)}
+		if len(s.Errors) > 0 {
+			msg := strings.Join(s.Errors, "\n")
+			js.Cases = append(js.Cases, junitCase{
+				Name:      s.File,
+				Classname: s.File,
+				File:      s.File,
+				Time:      seconds(0),
+				Error:     &junitProblem{Message: "parse errors", Text: msg},
+			})
+			js.Errors++
+		}
+		for _, r := range s.Tests {
+			jc := junitCase{
+				Name:      r.Name,
+				Classname: s.File,
+				File:      s.File,
+				Line:      r.Pos.Line,
+				Time:      seconds(r.Time),
+				SystemOut: r.Output,
+			}
+			if !r.Passed() {
+				msg, _, _ := strings.Cut(r.Failure, "\n")
+				jc.Failure = &junitProblem{Message: msg, Text: r.Failure}
+				js.Failures++
+			}
+			js.Cases = append(js.Cases, jc)
+		}
+		js.Tests = len(js.Cases)
+
+		all.Suites = append(all.Suites, js)
+		all.Tests += js.Tests
+		all.Failures += js.Failures
+		all.Errors += js.Errors
+		total += s.Time
+	}
+	all.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(all); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
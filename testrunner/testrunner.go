@@ -0,0 +1,227 @@
+// Package testrunner runs the tests of Monkey test files, those named
+// *_test.mnk. A test is a top level function named test_*, taking no
+// arguments, which fails if it raises an error, e.g. by a failed assert,
+// assertEq or assertError.
+//
+// Tests are run in isolation: each with a fresh interpreter, evaluating
+// the top level of its file anew before calling it, so that no state is
+// shared between tests.
+package testrunner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+// Suffix is the suffix of the names of test files.
+const Suffix = "_test.mnk"
+
+// Runner runs the tests of test files.
+type Runner struct {
+	// NewInterpreter returns the interpreter a test is run with, its
+	// output going to out.
+	NewInterpreter func(out io.Writer) (*eval.Interpreter, error)
+	// Match selects the tests run by name, all of them if nil.
+	Match *regexp.Regexp
+}
+
+// Suite is the results of the tests of a file.
+type Suite struct {
+	File string
+	// Errors are the errors parsing the file, in which case no test is run.
+	Errors []string
+	Tests  []*Result
+	Time   time.Duration
+}
+
+// Result is the result of a test.
+type Result struct {
+	Name string
+	Pos  token.Position
+	// Failure is why the test failed, empty if it passed.
+	Failure string
+	// Output is what the test printed.
+	Output string
+	Time   time.Duration
+}
+
+// Passed reports whether the test passed.
+func (r *Result) Passed() bool { return r.Failure == "" }
+
+// Failed returns the number of failed tests of s.
+func (s *Suite) Failed() int {
+	n := 0
+	for _, r := range s.Tests {
+		if !r.Passed() {
+			n++
+		}
+	}
+	return n
+}
+
+// Passed reports whether the file parsed and all its tests passed.
+func (s *Suite) Passed() bool {
+	return len(s.Errors) == 0 && s.Failed() == 0
+}
+
+// Tests returns the definitions of the tests of program, in the order they
+// are defined.
+func Tests(program *ast.Program) []*ast.LetStatement {
+	var tests []*ast.LetStatement
+	for _, stmt := range program.Statements {
+		var let *ast.LetStatement
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			let = stmt
+		case *ast.ExportStatement:
+			let = stmt.Let
+		default:
+			continue
+		}
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok && strings.HasPrefix(let.Name.Value, "test_") {
+			tests = append(tests, let)
+		}
+	}
+	return tests
+}
+
+// RunFile runs the tests of the file name, in the filesystem of the
+// interpreters, with source src.
+func (r *Runner) RunFile(name, src string) *Suite {
+	start := time.Now()
+	suite := &Suite{File: name}
+	defer func() { suite.Time = time.Since(start) }()
+
+	p := parser.FromInput(src)
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		for _, err := range errs {
+			suite.Errors = append(suite.Errors, fmt.Sprintf("%s:%s", name, err))
+		}
+		return suite
+	}
+	for _, let := range Tests(program) {
+		if r.Match != nil && !r.Match.MatchString(let.Name.Value) {
+			continue
+		}
+		suite.Tests = append(suite.Tests, r.run(name, program, let))
+	}
+	return suite
+}
+
+// run runs the test defined by let in program.
+func (r *Runner) run(name string, program *ast.Program, let *ast.LetStatement) *Result {
+	start := time.Now()
+	res := &Result{Name: let.Name.Value, Pos: let.Name.Pos()}
+	defer func() { res.Time = time.Since(start) }()
+
+	if fn := let.Value.(*ast.FunctionLiteral); len(fn.Params) > 0 {
+		res.Failure = fmt.Sprintf("%s:%s: test functions take no arguments", name, res.Pos)
+		return res
+	}
+	var out bytes.Buffer
+	defer func() { res.Output = out.String() }()
+	interp, err := r.NewInterpreter(&out)
+	if err != nil {
+		res.Failure = err.Error()
+		return res
+	}
+
+	env := object.NewEnv()
+	env.Set("args", &object.Array{})
+	val := interp.EvalFile(name, program, env)
+	if !raised(val) {
+		fn, _ := env.Get(res.Name)
+		val = interp.Apply(fn)
+	}
+	switch val := val.(type) {
+	case *object.Error:
+		file := name
+		if val.File != "" {
+			file = val.File
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s:%s: %s", file, val.Pos, val.Msg)
+		for _, frame := range val.Stack {
+			fmt.Fprintf(&b, "\n\tat %s", frame)
+		}
+		res.Failure = b.String()
+	case *object.Exit:
+		res.Failure = fmt.Sprintf("%s: exit(%d) called", name, val.Code)
+	}
+	return res
+}
+
+// raised reports whether val is an error or exit, ending evaluation.
+func raised(val object.Object) bool {
+	switch val.(type) {
+	case *object.Error, *object.Exit:
+		return true
+	}
+	return false
+}
+
+// WriteText writes the results of suites to w, in the manner of go test:
+// the failed tests with their output and why they failed, a line per file
+// and a summary. With verbose the passed tests are written too.
+func WriteText(w io.Writer, suites []*Suite, verbose bool) {
+	passed, failed := 0, 0
+	for _, s := range suites {
+		for _, err := range s.Errors {
+			fmt.Fprintln(w, err)
+		}
+		for _, r := range s.Tests {
+			if r.Passed() && !verbose {
+				continue
+			}
+			status := "PASS"
+			if !r.Passed() {
+				status = "FAIL"
+			}
+			fmt.Fprintf(w, "--- %s: %s\n", status, r.Name)
+			writeIndented(w, r.Output)
+			writeIndented(w, r.Failure)
+		}
+
+		n := s.Failed()
+		passed += len(s.Tests) - n
+		failed += n
+		switch {
+		case len(s.Errors) > 0:
+			failed++
+			fmt.Fprintf(w, "FAIL\t%s\t[parse errors]\n", s.File)
+		case len(s.Tests) == 0:
+			fmt.Fprintf(w, "ok  \t%s\t[no tests]\n", s.File)
+		case n > 0:
+			fmt.Fprintf(w, "FAIL\t%s\t%d passed, %d failed\n", s.File, len(s.Tests)-n, n)
+		default:
+			fmt.Fprintf(w, "ok  \t%s\t%d passed\n", s.File, len(s.Tests))
+		}
+	}
+
+	status := "PASS"
+	if failed > 0 {
+		status = "FAIL"
+	}
+	fmt.Fprintf(w, "%s: %d passed, %d failed\n", status, passed, failed)
+}
+
+// writeIndented writes the lines of text to w, indented.
+func writeIndented(w io.Writer, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		fmt.Fprintf(w, "    %s\n", line)
+	}
+}
@@ -0,0 +1,151 @@
+package testrunner
+
+import (
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/stretchr/testify/require"
+)
+
+const mathTest = `let double = fn(x) { x * 2 };
+puts("setup");
+
+let test_double = fn() {
+  assertEq(double(2), 4);
+};
+
+let test_fails = fn() {
+  puts("checking");
+  assertEq([double(1), double(2)], [2, 5], "doubles");
+};
+
+export let test_error = fn() {
+  assertError(fn() { double("a") }, "type mismatch");
+};
+
+let test_args = fn(t) { t };
+let test_exit = fn() { exit(1) };
+let helper = fn() { assert(false) };
+`
+
+func newRunner() *Runner {
+	return &Runner{
+		NewInterpreter: func(out io.Writer) (*eval.Interpreter, error) {
+			interp := eval.New()
+			interp.Allow(eval.CapIO, eval.CapProcess)
+			interp.Stdout, interp.Stderr = out, out
+			return interp, nil
+		},
+	}
+}
+
+// clearTimes zeroes the times of suites, for comparing output.
+func clearTimes(suites []*Suite) {
+	for _, s := range suites {
+		s.Time = 0
+		for _, r := range s.Tests {
+			r.Time = 0
+		}
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	suite := newRunner().RunFile("math_test.mnk", mathTest)
+	require.Empty(t, suite.Errors)
+	require.False(t, suite.Passed())
+	require.Equal(t, 3, suite.Failed())
+
+	var got []Result
+	for _, r := range suite.Tests {
+		r.Time = 0
+		got = append(got, *r)
+	}
+	require.Equal(t, []Result{
+		{Name: "test_double", Pos: got[0].Pos, Output: "setup\n"},
+		{Name: "test_fails", Pos: got[1].Pos, Output: "setup\nchecking\n",
+			Failure: "math_test.mnk:10:11: assertEq failed: doubles\ngot:  [2, 4]\nwant: [2, 5]\n          ^\n\tat assertEq (math_test.mnk:10:3)"},
+		{Name: "test_error", Pos: got[2].Pos, Output: "setup\n"},
+		{Name: "test_args", Pos: got[3].Pos, Failure: "math_test.mnk:17:5: test functions take no arguments"},
+		{Name: "test_exit", Pos: got[4].Pos, Output: "setup\n", Failure: "math_test.mnk: exit(1) called"},
+	}, got)
+}
+
+func TestMatch(t *testing.T) {
+	r := newRunner()
+	r.Match = regexp.MustCompile("double|error")
+	suite := r.RunFile("math_test.mnk", mathTest)
+	require.True(t, suite.Passed())
+	require.Len(t, suite.Tests, 2)
+}
+
+func TestSetupError(t *testing.T) {
+	suite := newRunner().RunFile("a_test.mnk", "let x = 1 + true;\nlet test_a = fn() { x }")
+	require.Equal(t, 1, suite.Failed())
+	require.Equal(t, "a_test.mnk:1:11: type mismatch: INTEGER + BOOLEAN", suite.Tests[0].Failure)
+}
+
+func TestWriteText(t *testing.T) {
+	r := newRunner()
+	r.Match = regexp.MustCompile("double|fails")
+	suites := []*Suite{
+		r.RunFile("math_test.mnk", mathTest),
+		r.RunFile("empty_test.mnk", "let x = 1"),
+		r.RunFile("bad_test.mnk", "let = 1"),
+	}
+
+	var b strings.Builder
+	WriteText(&b, suites, false)
+	require.Equal(t, `--- FAIL: test_fails
+    setup
+    checking
+    math_test.mnk:10:11: assertEq failed: doubles
+    got:  [2, 4]
+    want: [2, 5]
+              ^
+    	at assertEq (math_test.mnk:10:3)
+FAIL	math_test.mnk	1 passed, 1 failed
+ok  	empty_test.mnk	[no tests]
+bad_test.mnk:1:5: expected next token to be IDENT, got = instead
+bad_test.mnk:1:5: no prefix parse function for = found
+FAIL	bad_test.mnk	[parse errors]
+FAIL: 1 passed, 2 failed
+`, b.String())
+
+	b.Reset()
+	WriteText(&b, suites[:1], true)
+	require.True(t, strings.HasPrefix(b.String(), "--- PASS: test_double\n    setup\n--- FAIL: test_fails\n"), b.String())
+}
+
+func TestWriteJUnit(t *testing.T) {
+	r := newRunner()
+	r.Match = regexp.MustCompile("double|exit")
+	suites := []*Suite{
+		r.RunFile("math_test.mnk", mathTest),
+		r.RunFile("bad_test.mnk", "let = 1"),
+	}
+	clearTimes(suites)
+
+	var b strings.Builder
+	require.NoError(t, WriteJUnit(&b, suites))
+	require.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="3" failures="1" errors="1" time="0.000">
+  <testsuite name="math_test.mnk" tests="2" failures="1" errors="0" time="0.000">
+    <testcase name="test_double" classname="math_test.mnk" file="math_test.mnk" line="4" time="0.000">
+      <system-out>setup&#xA;</system-out>
+    </testcase>
+    <testcase name="test_exit" classname="math_test.mnk" file="math_test.mnk" line="18" time="0.000">
+      <failure message="math_test.mnk: exit(1) called">math_test.mnk: exit(1) called</failure>
+      <system-out>setup&#xA;</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="bad_test.mnk" tests="1" failures="0" errors="1" time="0.000">
+    <testcase name="bad_test.mnk" classname="bad_test.mnk" file="bad_test.mnk" time="0.000">
+      <error message="parse errors">bad_test.mnk:1:5: expected next token to be IDENT, got = instead&#xA;bad_test.mnk:1:5: no prefix parse function for = found</error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, b.String())
+}
@@ -2,7 +2,13 @@ package testutils
 
 import (
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -22,3 +28,74 @@ func IsType[T any](t *testing.T, obj any, msgAndArgs ...any) T {
 	require.True(t, ok, "type of obj=%+v is not type=%T but %s: %s", obj, x, reflect.TypeOf(obj), msg)
 	return r
 }
+
+// Program is a Monkey program of the benchmarks directory.
+type Program struct {
+	Name string
+	Src  string
+}
+
+// BenchPrograms returns the programs of the benchmarks directory at the root
+// of the module by file name, sorted. It must be called from a package
+// directory, as tests are run.
+func BenchPrograms(tb testing.TB) []Program {
+	tb.Helper()
+	files, err := filepath.Glob("../benchmarks/*.mnk")
+	require.NoError(tb, err)
+	require.NotEmpty(tb, files)
+	programs := make([]Program, len(files))
+	for i, file := range files {
+		src, err := os.ReadFile(file)
+		require.NoError(tb, err)
+		programs[i] = Program{Name: filepath.Base(file), Src: string(src)}
+	}
+	return programs
+}
+
+// seedDirs are the directories, relative to the root of the module, whose
+// Monkey programs and test tables seed the fuzz targets.
+var seedDirs = []string{"lexer", "parser", "eval", "format", "optimizer", "resolver", "types", "vet"}
+
+// AddSeeds adds the seed corpus of the fuzz targets to f: the Monkey
+// programs of the examples, benchmarks and testdata directories, and the
+// string literals of the tests of the interpreter packages, which hold the
+// inputs of their test tables. Like BenchPrograms, it must be called from a
+// package directory.
+func AddSeeds(f *testing.F) {
+	f.Helper()
+	seen := map[string]bool{}
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			f.Add(s)
+		}
+	}
+
+	for _, pattern := range []string{"../examples/*.mnk", "../examples/*/*.mnk", "../benchmarks/*.mnk", "../testdata/*/*.mnk", "../testdata/*/*/*.mnk"} {
+		files, err := filepath.Glob(pattern)
+		require.NoError(f, err)
+		for _, file := range files {
+			src, err := os.ReadFile(file)
+			require.NoError(f, err)
+			add(string(src))
+		}
+	}
+
+	fset := token.NewFileSet()
+	for _, dir := range seedDirs {
+		files, err := filepath.Glob(filepath.Join("..", dir, "*_test.go"))
+		require.NoError(f, err)
+		for _, file := range files {
+			tree, err := parser.ParseFile(fset, file, nil, 0)
+			require.NoError(f, err)
+			ast.Inspect(tree, func(n ast.Node) bool {
+				if lit, ok := n.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					if s, err := strconv.Unquote(lit.Value); err == nil {
+						add(s)
+					}
+				}
+				return true
+			})
+		}
+	}
+}
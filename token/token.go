@@ -1,10 +1,32 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+}
+
+// Position is a 1-based line and column (in bytes) in the source. The zero
+// value means no position is known.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 const (
@@ -28,8 +50,17 @@ const (
 	LT     = "<"
 	GT     = ">"
 
+	// ARROW separates the parameters and the return type of a function
+	// type annotation.
+	ARROW = "->"
+
+	NULLISH  = "??"
+	OPTCHAIN = "?."
+	OPTINDEX = "?["
+
 	COMMA     = ","
 	SEMICOLON = ";"
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -47,16 +78,40 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	NULL     = "NULL"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":      FUNCTION,
+	"let":     LET,
+	"true":    TRUE,
+	"false":   FALSE,
+	"if":      IF,
+	"else":    ELSE,
+	"return":  RETURN,
+	"null":    NULL,
+	"try":     TRY,
+	"catch":   CATCH,
+	"finally": FINALLY,
+	"throw":   THROW,
+	"import":  IMPORT,
+	"export":  EXPORT,
+}
+
+// Keywords returns the keywords of the language, sorted.
+func Keywords() []string {
+	kws := make([]string, 0, len(keywords))
+	for kw := range keywords {
+		kws = append(kws, kw)
+	}
+	sort.Strings(kws)
+	return kws
 }
 
 func Ident(ident string) Token {
@@ -92,6 +147,8 @@ func Ch(ch string) Token {
 		tp = RPAREN
 	case ",":
 		tp = COMMA
+	case ".":
+		tp = DOT
 	case "+":
 		tp = PLUS
 	case "-":
@@ -0,0 +1,101 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+)
+
+// methodTypes are the types of the methods of arrays, strings and hashes,
+// without the receiver. T is the element type of arrays, K and V the key
+// and value types of hashes. merge is checked specially, and items only
+// applies to hashes whose keys and values have one type, like the elements
+// of the pairs it returns.
+var methodTypes = map[string]map[string]string{
+	"array": {
+		"len":    "fn() -> int",
+		"push":   "fn(T) -> array<T>",
+		"first":  "fn() -> T",
+		"last":   "fn() -> T",
+		"rest":   "fn() -> array<T>",
+		"map":    "fn(fn(T) -> U) -> array<U>",
+		"filter": "fn(fn(T) -> U) -> array<T>",
+		"reduce": "fn(U, fn(U, T) -> U) -> U",
+		"join":   "fn(string) -> string",
+	},
+	"string": {
+		"len":      "fn() -> int",
+		"upper":    "fn() -> string",
+		"lower":    "fn() -> string",
+		"trim":     "fn() -> string",
+		"split":    "fn(string) -> array<string>",
+		"contains": "fn(string) -> bool",
+	},
+	"hash": {
+		"keys":   "fn() -> array<K>",
+		"values": "fn() -> array<V>",
+		"items":  "fn() -> array<array<K>>",
+		"has":    "fn(K) -> bool",
+		"delete": "fn(K) -> hash<K, V>",
+		"merge":  "fn(hash<K, V>) -> hash<K, V>",
+		"size":   "fn() -> int",
+	},
+}
+
+// receiverVars name the type arguments of receivers in methodTypes.
+var receiverVars = map[string][]string{"array": {"T"}, "hash": {"K", "V"}}
+
+var (
+	// builtinExprs are the types of the builtins annotated with one.
+	builtinExprs = map[string]ast.TypeExpr{}
+	// variadics are the other builtins, such as those taking any number of
+	// arguments, which calls check specially.
+	variadics   = map[string]bool{}
+	methodExprs = map[string]map[string]ast.TypeExpr{}
+)
+
+func init() {
+	for _, name := range eval.BuiltinNames() {
+		b, _ := eval.LookupBuiltin(name)
+		switch b, ok := b.(*object.Builtin); {
+		case !ok:
+		case b.TypeAnnotation == "":
+			variadics[name] = true
+		default:
+			builtinExprs[name] = parseType(name, b.TypeAnnotation)
+		}
+	}
+	for recv, methods := range methodTypes {
+		methodExprs[recv] = make(map[string]ast.TypeExpr, len(methods))
+		for name, src := range methods {
+			methodExprs[recv][name] = parseType(name, src)
+		}
+	}
+}
+
+func parseType(name, src string) ast.TypeExpr {
+	p := parser.FromInput(src)
+	typ := p.ParseType()
+	if typ == nil {
+		panic(fmt.Sprintf("type of %s: %v", name, p.Errors()))
+	}
+	return typ
+}
+
+// methodOwner returns the only receiver type having the method name, or ""
+// if there is none or several.
+func methodOwner(name string) string {
+	owner := ""
+	for recv, methods := range methodTypes {
+		if _, ok := methods[name]; ok {
+			if owner != "" {
+				return ""
+			}
+			owner = recv
+		}
+	}
+	return owner
+}
@@ -0,0 +1,707 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+// Error is a type error.
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e Error) Error() string { return fmt.Sprintf("%s: %s", e.Pos, e.Msg) }
+
+// Binding is a let binding at the top level of a program, with its type.
+type Binding struct {
+	Name string
+	Pos  token.Position
+	Type *Scheme
+}
+
+// Check infers the types of program without evaluating it. It returns the
+// top level bindings in order, and the type errors sorted by position.
+func Check(program *ast.Program) ([]Binding, []Error) {
+	c := &checker{}
+	c.top = newScope(c.universe(), 0)
+	c.declare(c.top, program.Statements)
+	for _, stmt := range program.Statements {
+		c.statement(c.top, stmt)
+	}
+
+	sort.SliceStable(c.errs, func(i, j int) bool {
+		a, b := c.errs[i].Pos, c.errs[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return c.bindings, c.errs
+}
+
+// entry is a name bound in a scope.
+type entry struct {
+	// scheme is the type of the name, nil until its let is checked.
+	scheme *Scheme
+	// forward is the type of the uses of the name before its let is
+	// checked, which are references from functions or recursive ones.
+	forward *Var
+	// level is that of the scope.
+	level int
+	// variadic marks the builtins in variadics.
+	variadic bool
+}
+
+type scope struct {
+	names map[string]*entry
+	outer *scope
+	level int
+}
+
+func newScope(outer *scope, level int) *scope {
+	return &scope{names: map[string]*entry{}, outer: outer, level: level}
+}
+
+func (s *scope) lookup(name string) *entry {
+	for ; s != nil; s = s.outer {
+		if e, ok := s.names[name]; ok {
+			return e
+		}
+	}
+	return nil
+}
+
+type checker struct {
+	// level is the number of enclosing let values.
+	level int
+	// ret is the return type of the function being checked, nil at the top
+	// level.
+	ret      Type
+	top      *scope
+	bindings []Binding
+	errs     []Error
+}
+
+func (c *checker) errorf(pos token.Position, format string, args ...any) {
+	c.errs = append(c.errs, Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// unifyAt unifies want and got, reporting failure at pos.
+func (c *checker) unifyAt(pos token.Position, want, got Type) {
+	if err := unify(want, got); err != nil {
+		c.errorf(pos, "%s", err)
+	}
+}
+
+func (c *checker) fresh() *Var { return &Var{level: c.level} }
+
+// universe is the scope of the builtins and the names bound by monkey run.
+func (c *checker) universe() *scope {
+	s := newScope(nil, 0)
+	c.level++
+	for name, expr := range builtinExprs {
+		s.names[name] = &entry{scheme: c.generalize(c.convert(expr, map[string]*Var{}))}
+	}
+	c.level--
+	for name := range variadics {
+		v := &Var{level: 1}
+		s.names[name] = &entry{scheme: &Scheme{Vars: []*Var{v}, Type: v}, variadic: true}
+	}
+	s.names["json"] = &entry{scheme: mono(moduleType)}
+	s.names["args"] = &entry{scheme: mono(arrayOf(stringType))}
+	return s
+}
+
+// generalize quantifies t over its type variables created inside the let
+// being checked.
+func (c *checker) generalize(t Type) *Scheme {
+	s := &Scheme{Type: t}
+	seen := map[*Var]bool{}
+	var walk func(Type)
+	walk = func(t Type) {
+		switch t := prune(t).(type) {
+		case *Var:
+			if t.level > c.level && !seen[t] {
+				seen[t] = true
+				s.Vars = append(s.Vars, t)
+			}
+		case *Con:
+			for _, a := range t.Args {
+				walk(a)
+			}
+		case *Func:
+			for _, p := range t.Params {
+				walk(p)
+			}
+			walk(t.Return)
+		}
+	}
+	walk(t)
+	return s
+}
+
+// instantiate returns the type of s with fresh variables for its
+// quantified ones.
+func (c *checker) instantiate(s *Scheme) Type {
+	if len(s.Vars) == 0 {
+		return s.Type
+	}
+	subst := make(map[*Var]Type, len(s.Vars))
+	for _, v := range s.Vars {
+		subst[v] = &Var{level: c.level, addable: v.addable}
+	}
+	var copy func(Type) Type
+	copy = func(t Type) Type {
+		switch t := prune(t).(type) {
+		case *Var:
+			if u, ok := subst[t]; ok {
+				return u
+			}
+			return t
+		case *Con:
+			if len(t.Args) == 0 {
+				return t
+			}
+			args := make([]Type, len(t.Args))
+			for i, a := range t.Args {
+				args[i] = copy(a)
+			}
+			return &Con{Name: t.Name, Args: args}
+		case *Func:
+			params := make([]Type, len(t.Params))
+			for i, p := range t.Params {
+				params[i] = copy(p)
+			}
+			return &Func{Params: params, Return: copy(t.Return)}
+		}
+		return t
+	}
+	return copy(s.Type)
+}
+
+// convert returns the type annotated by expr. vars holds the type variables
+// of the annotation by name.
+func (c *checker) convert(expr ast.TypeExpr, vars map[string]*Var) Type {
+	switch expr := expr.(type) {
+	case *ast.FunctionType:
+		params := make([]Type, len(expr.Params))
+		for i, p := range expr.Params {
+			params[i] = c.convert(p, vars)
+		}
+		return &Func{Params: params, Return: c.convert(expr.Return, vars)}
+	case *ast.TypeName:
+		if len(expr.Name) == 1 && 'A' <= expr.Name[0] && expr.Name[0] <= 'Z' && len(expr.Args) == 0 {
+			if v, ok := vars[expr.Name]; ok {
+				return v
+			}
+			v := c.fresh()
+			vars[expr.Name] = v
+			return v
+		}
+		n, ok := arity[expr.Name]
+		if !ok {
+			c.errorf(expr.Pos(), "unknown type %s", expr.Name)
+			return c.fresh()
+		}
+		if len(expr.Args) != n {
+			c.errorf(expr.Pos(), "%s takes %d type arguments, got %d", expr.Name, n, len(expr.Args))
+			return c.fresh()
+		}
+		if n == 0 {
+			return &Con{Name: expr.Name}
+		}
+		args := make([]Type, n)
+		for i, a := range expr.Args {
+			args[i] = c.convert(a, vars)
+		}
+		return &Con{Name: expr.Name, Args: args}
+	}
+	return c.fresh()
+}
+
+// declare adds the names bound by stmts to s, so functions may refer to
+// bindings following them. Blocks other than function bodies and catch
+// blocks bind in the enclosing scope, as they do when evaluated.
+func (c *checker) declare(s *scope, stmts []ast.Statement) {
+	add := func(name string) {
+		if _, ok := s.names[name]; !ok {
+			s.names[name] = &entry{level: s.level}
+		}
+	}
+	var visit func(ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			if n != nil && n.Name != nil {
+				add(n.Name.Value)
+			}
+		case *ast.ImportStatement:
+			if n != nil && n.Name != nil {
+				add(n.Name.Value)
+			}
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.TryExpression:
+			ast.Inspect(n.Block, visit)
+			if n.Finally != nil {
+				ast.Inspect(n.Finally, visit)
+			}
+			return false
+		}
+		return true
+	}
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, visit)
+	}
+}
+
+// statement checks stmt, returning the type of its value.
+func (c *checker) statement(s *scope, stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(s, stmt)
+	case *ast.ExportStatement:
+		c.let(s, stmt.Let)
+	case *ast.ImportStatement:
+		c.define(s, stmt.Name, mono(moduleType))
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue != nil {
+			t := c.expr(s, stmt.ReturnValue)
+			if c.ret != nil {
+				c.unifyAt(stmt.ReturnValue.Pos(), c.ret, t)
+			}
+		}
+	case *ast.ThrowStatement:
+		c.expr(s, stmt.Value)
+	case *ast.ExpressionStatement:
+		return c.expr(s, stmt.Expression)
+	}
+	return c.fresh()
+}
+
+// block checks the statements of b in s, returning the type of the last.
+func (c *checker) block(s *scope, b *ast.BlockStatement) Type {
+	var t Type = c.fresh()
+	for _, stmt := range b.Statements {
+		t = c.statement(s, stmt)
+	}
+	return t
+}
+
+func (c *checker) let(s *scope, ls *ast.LetStatement) {
+	e := s.names[ls.Name.Value]
+	if e == nil {
+		e = &entry{level: s.level}
+		s.names[ls.Name.Value] = e
+	}
+	c.level++
+	if e.scheme == nil && e.forward == nil {
+		// Recursive references are monomorphic.
+		e.forward = c.fresh()
+	}
+	t := c.expr(s, ls.Value)
+	if ls.Type != nil {
+		annotated := c.convert(ls.Type, map[string]*Var{})
+		c.unifyAt(ls.Value.Pos(), annotated, t)
+		t = annotated
+	}
+	if e.forward != nil {
+		c.unifyAt(ls.Name.Pos(), e.forward, t)
+		e.forward = nil
+	}
+	c.level--
+	c.define(s, ls.Name, c.generalize(t))
+	if s == c.top {
+		c.bindings = append(c.bindings, Binding{Name: ls.Name.Value, Pos: ls.Name.Pos(), Type: e.scheme})
+	}
+}
+
+// define binds name to sc in s, checking it against the uses preceding it.
+func (c *checker) define(s *scope, name *ast.Identifier, sc *Scheme) {
+	e := s.names[name.Value]
+	if e == nil {
+		e = &entry{level: s.level}
+		s.names[name.Value] = e
+	}
+	if e.forward != nil {
+		c.unifyAt(name.Pos(), e.forward, c.instantiate(sc))
+		e.forward = nil
+	}
+	e.scheme = sc
+}
+
+// expr checks exp, returning its type.
+func (c *checker) expr(s *scope, exp ast.Expression) Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return intType
+	case *ast.Boolean:
+		return boolType
+	case *ast.StringLiteral:
+		return stringType
+	case *ast.Null:
+		return c.fresh()
+	case *ast.Identifier:
+		return c.identifier(s, exp)
+	case *ast.PrefixExpression:
+		t := c.expr(s, exp.Right)
+		if exp.Operator == "-" && unify(intType, t) != nil {
+			c.errorf(exp.Pos(), "unknown operator: -%s", t)
+		}
+		if exp.Operator == "!" {
+			return boolType
+		}
+		return t
+	case *ast.InfixExpression:
+		return c.infix(s, exp)
+	case *ast.IfExpression:
+		c.expr(s, exp.Condition)
+		t := c.block(s, exp.Consequence)
+		if exp.Alternative != nil {
+			alt := c.block(s, exp.Alternative)
+			if unify(t, alt) != nil {
+				n := newNamer()
+				c.errorf(exp.Pos(), "if branches have different types: %s and %s", typeString(n, t), typeString(n, alt))
+			}
+		}
+		return t
+	case *ast.TryExpression:
+		t := c.block(s, exp.Block)
+		if exp.Catch != nil {
+			cs := newScope(s, c.level)
+			if exp.Param != nil {
+				cs.names[exp.Param.Value] = &entry{scheme: mono(errorType)}
+			}
+			c.declare(cs, exp.Catch.Statements)
+			catch := c.block(cs, exp.Catch)
+			if unify(t, catch) != nil {
+				n := newNamer()
+				c.errorf(exp.Catch.Pos(), "try and catch have different types: %s and %s", typeString(n, t), typeString(n, catch))
+			}
+		}
+		if exp.Finally != nil {
+			c.block(s, exp.Finally)
+		}
+		return t
+	case *ast.FunctionLiteral:
+		return c.function(s, exp)
+	case *ast.ArrayLiteral:
+		elem := c.fresh()
+		for _, el := range exp.Elems {
+			c.unifyAt(el.Pos(), elem, c.expr(s, el))
+		}
+		return arrayOf(elem)
+	case *ast.HashLiteral:
+		key, value := c.fresh(), c.fresh()
+		for _, k := range exp.Keys {
+			c.unifyAt(k.Pos(), key, c.expr(s, k))
+			c.unifyAt(exp.Pairs[k].Pos(), value, c.expr(s, exp.Pairs[k]))
+		}
+		return hashOf(key, value)
+	case *ast.IndexExpression:
+		return c.index(s, exp)
+	case *ast.SliceExpression:
+		left := c.expr(s, exp.Left)
+		for _, bound := range []ast.Expression{exp.IndexLeft, exp.IndexRight} {
+			if bound != nil {
+				c.unifyAt(bound.Pos(), intType, c.expr(s, bound))
+			}
+		}
+		if dynamic(left) {
+			return c.fresh()
+		}
+		if unify(arrayOf(c.fresh()), left) != nil {
+			c.errorf(exp.Pos(), "slice operator not supported: %s", left)
+		}
+		return left
+	case *ast.MemberExpression:
+		return c.member(s, exp)
+	case *ast.CallExpression:
+		return c.call(s, exp)
+	}
+	return c.fresh()
+}
+
+func (c *checker) identifier(s *scope, ident *ast.Identifier) Type {
+	e := s.lookup(ident.Value)
+	switch {
+	case e == nil:
+		c.errorf(ident.Pos(), "undefined: %s", ident.Value)
+		return c.fresh()
+	case e.scheme != nil:
+		return c.instantiate(e.scheme)
+	case e.forward == nil:
+		e.forward = &Var{level: e.level}
+	}
+	return e.forward
+}
+
+func (c *checker) infix(s *scope, exp *ast.InfixExpression) Type {
+	left, right := c.expr(s, exp.Left), c.expr(s, exp.Right)
+	fail := func() {
+		n := newNamer()
+		l, r := typeString(n, left), typeString(n, right)
+		if l == r {
+			c.errorf(exp.Pos(), "unknown operator: %s %s %s", l, exp.Operator, r)
+		} else {
+			c.errorf(exp.Pos(), "type mismatch: %s %s %s", l, exp.Operator, r)
+		}
+	}
+	switch exp.Operator {
+	case "+":
+		if unify(left, right) != nil || unify(&Var{level: c.level, addable: true}, left) != nil {
+			fail()
+		}
+		return left
+	case "-", "*", "/":
+		if unify(intType, left) != nil || unify(intType, right) != nil {
+			fail()
+		}
+		return intType
+	case "<", ">":
+		if unify(intType, left) != nil || unify(intType, right) != nil {
+			fail()
+		}
+		return boolType
+	case "==", "!=":
+		if unify(left, right) != nil {
+			fail()
+		}
+		return boolType
+	default: // ??
+		if unify(left, right) != nil {
+			fail()
+		}
+		return left
+	}
+}
+
+// index checks an index expression. Values of unknown type indexed by a
+// string are taken to be hashes, and arrays otherwise.
+func (c *checker) index(s *scope, exp *ast.IndexExpression) Type {
+	left, index := c.expr(s, exp.Left), c.expr(s, exp.Index)
+	if v, ok := prune(left).(*Var); ok {
+		if key, ok := prune(index).(*Con); ok && key.Name == stringType.Name {
+			unify(v, hashOf(stringType, c.fresh()))
+		} else {
+			unify(v, arrayOf(c.fresh()))
+		}
+	}
+	switch t := prune(left).(type) {
+	case *Con:
+		switch {
+		case t.Name == "array":
+			c.unifyAt(exp.Index.Pos(), intType, index)
+			return t.Args[0]
+		case t.Name == "hash":
+			c.unifyAt(exp.Index.Pos(), t.Args[0], index)
+			return t.Args[1]
+		case dynamic(t):
+			return c.fresh()
+		}
+	}
+	c.errorf(exp.Pos(), "index operator not supported: %s", left)
+	return c.fresh()
+}
+
+// member checks a field access, which only hashes and modules have. Values
+// of unknown type are taken to be hashes.
+func (c *checker) member(s *scope, exp *ast.MemberExpression) Type {
+	obj := c.expr(s, exp.Object)
+	if dynamic(obj) {
+		return c.fresh()
+	}
+	value := c.fresh()
+	if unify(hashOf(stringType, value), obj) != nil {
+		c.errorf(exp.Pos(), "member access not supported: %s.%s", obj, exp.Property.Value)
+	}
+	return value
+}
+
+func (c *checker) call(s *scope, exp *ast.CallExpression) Type {
+	if member, ok := exp.Function.(*ast.MemberExpression); ok {
+		return c.method(s, member, exp.Arguments)
+	}
+	if ident, ok := exp.Function.(*ast.Identifier); ok {
+		if e := s.lookup(ident.Value); e != nil && e.variadic {
+			return c.variadic(s, ident, exp.Arguments)
+		}
+	}
+	fn := c.expr(s, exp.Function)
+	return c.apply(s, exp.Function.Pos(), fn, exp.Arguments)
+}
+
+// apply checks a call of a function of type fn with args.
+func (c *checker) apply(s *scope, pos token.Position, fn Type, args []ast.Expression) Type {
+	types := make([]Type, len(args))
+	for i, arg := range args {
+		types[i] = c.expr(s, arg)
+	}
+	switch f := prune(fn).(type) {
+	case *Func:
+		if len(f.Params) != len(args) {
+			c.errorf(pos, "wrong number of arguments: want %d, got %d", len(f.Params), len(args))
+			return f.Return
+		}
+		for i, arg := range args {
+			c.unifyAt(arg.Pos(), f.Params[i], types[i])
+		}
+		return f.Return
+	case *Var:
+		ret := c.fresh()
+		c.unifyAt(pos, f, &Func{Params: types, Return: ret})
+		return ret
+	}
+	if dynamic(fn) {
+		return c.fresh()
+	}
+	c.errorf(pos, "cannot call %s", fn)
+	return c.fresh()
+}
+
+// variadic checks a call of one of the variadics.
+func (c *checker) variadic(s *scope, ident *ast.Identifier, args []ast.Expression) Type {
+	switch ident.Value {
+	case "len":
+		if len(args) != 1 {
+			c.errorf(ident.Pos(), "wrong number of arguments: want 1, got %d", len(args))
+		}
+		for _, arg := range args {
+			// Arguments of unknown type are left unknown, as there is no
+			// type of strings or arrays.
+			switch t := prune(c.expr(s, arg)).(type) {
+			case *Con:
+				if t.Name != "string" && t.Name != "array" && !dynamic(t) {
+					c.errorf(arg.Pos(), "type mismatch: want string or array, got %s", t)
+				}
+			case *Func:
+				c.errorf(arg.Pos(), "type mismatch: want string or array, got %s", t)
+			}
+		}
+		return intType
+	case "merge":
+		hash := hashOf(c.fresh(), c.fresh())
+		for _, arg := range args {
+			c.unifyAt(arg.Pos(), hash, c.expr(s, arg))
+		}
+		return hash
+	case "exit":
+		if len(args) > 1 {
+			c.errorf(ident.Pos(), "wrong number of arguments: want at most 1, got %d", len(args))
+		}
+		for _, arg := range args {
+			c.unifyAt(arg.Pos(), intType, c.expr(s, arg))
+		}
+	case "assertEq":
+		if len(args) < 2 || len(args) > 3 {
+			c.errorf(ident.Pos(), "wrong number of arguments: want 2 or 3, got %d", len(args))
+			break
+		}
+		want := c.expr(s, args[1])
+		c.unifyAt(args[0].Pos(), want, c.expr(s, args[0]))
+		for _, arg := range args[2:] {
+			c.expr(s, arg)
+		}
+	default:
+		for _, arg := range args {
+			c.expr(s, arg)
+		}
+	}
+	return c.fresh()
+}
+
+// method checks a method call. Receivers of unknown type are taken to be
+// of the only type having the method, if any, and hashes otherwise.
+func (c *checker) method(s *scope, member *ast.MemberExpression, args []ast.Expression) Type {
+	recv := c.expr(s, member.Object)
+	name := member.Property.Value
+	if v, ok := prune(recv).(*Var); ok {
+		switch owner := methodOwner(name); {
+		case owner == "array":
+			unify(v, arrayOf(c.fresh()))
+		case owner == "hash":
+			unify(v, hashOf(c.fresh(), c.fresh()))
+		case owner == "string":
+			unify(v, stringType)
+		case !hasMethod(name):
+			unify(v, hashOf(stringType, c.fresh()))
+		}
+	}
+
+	t, ok := prune(recv).(*Con)
+	if ok && t.Name == "hash" && name == "items" {
+		c.unifyAt(member.Property.Pos(), t.Args[0], t.Args[1])
+	}
+	switch {
+	case !ok:
+		if _, isVar := prune(recv).(*Var); isVar {
+			// A method of several types, such as len.
+			return c.apply(s, member.Property.Pos(), c.fresh(), args)
+		}
+	case dynamic(t):
+		return c.apply(s, member.Property.Pos(), c.fresh(), args)
+	case t.Name == "hash" && name == "merge":
+		for _, arg := range args {
+			c.unifyAt(arg.Pos(), t, c.expr(s, arg))
+		}
+		return t
+	case methodExprs[t.Name][name] != nil:
+		vars := map[string]*Var{}
+		for i, v := range receiverVars[t.Name] {
+			vars[v] = c.fresh()
+			unify(vars[v], t.Args[i])
+		}
+		fn := c.convert(methodExprs[t.Name][name], vars)
+		return c.apply(s, member.Property.Pos(), fn, args)
+	case t.Name == "hash":
+		field := c.fresh()
+		c.unifyAt(member.Pos(), t, hashOf(stringType, field))
+		return c.apply(s, member.Property.Pos(), field, args)
+	}
+	for _, arg := range args {
+		c.expr(s, arg)
+	}
+	c.errorf(member.Property.Pos(), "unknown method: %s.%s", recv, name)
+	return c.fresh()
+}
+
+func hasMethod(name string) bool {
+	for _, methods := range methodTypes {
+		if _, ok := methods[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *checker) function(s *scope, lit *ast.FunctionLiteral) Type {
+	fs := newScope(s, c.level)
+	vars := map[string]*Var{}
+	params := make([]Type, len(lit.Params))
+	for i, p := range lit.Params {
+		if i < len(lit.ParamTypes) && lit.ParamTypes[i] != nil {
+			params[i] = c.convert(lit.ParamTypes[i], vars)
+		} else {
+			params[i] = c.fresh()
+		}
+		fs.names[p.Value] = &entry{scheme: mono(params[i])}
+	}
+	var ret Type = c.fresh()
+	if lit.ReturnType != nil {
+		ret = c.convert(lit.ReturnType, vars)
+	}
+	c.declare(fs, lit.Body.Statements)
+
+	outer := c.ret
+	c.ret = ret
+	body := c.block(fs, lit.Body)
+	c.ret = outer
+
+	pos := lit.Body.Pos()
+	if n := len(lit.Body.Statements); n > 0 {
+		pos = lit.Body.Statements[n-1].Pos()
+	}
+	c.unifyAt(pos, ret, body)
+	return &Func{Params: params, Return: ret}
+}
@@ -0,0 +1,168 @@
+package types
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func check(t *testing.T, input string) ([]Binding, []string) {
+	t.Helper()
+	p := parser.FromInput(input)
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors(), input)
+	bindings, errs := Check(program)
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return bindings, msgs
+}
+
+func TestInference(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`let x = 1`, "int"},
+		{`let x = "a" + "b"`, "string"},
+		{`let x = !1`, "bool"},
+		{`let x = [1, 2][0]`, "int"},
+		{`let x = {"a": true}`, "hash<string, bool>"},
+		{`let x = {"a": 1}.a`, "int"},
+		{`let x = null`, "T"},
+		{`let x = null ?? 1`, "int"},
+		{`let id = fn(x) { x }`, "fn(T) -> T"},
+		{`let add = fn(a, b) { a + b }`, "fn(T, T) -> T"},
+		{`let sub = fn(a, b) { a - b }`, "fn(int, int) -> int"},
+		{`let apply = fn(f, x) { f(x) }`, "fn(fn(T) -> U, T) -> U"},
+		{`let compose = fn(f, g) { fn(x) { f(g(x)) } }`, "fn(fn(T) -> U, fn(V) -> T) -> fn(V) -> U"},
+		{`let first = fn(arr) { arr[0] }`, "fn(array<T>) -> T"},
+		{`let get = fn(h) { h["a"] }`, "fn(hash<string, T>) -> T"},
+		{`let name = fn(h) { h.name }`, "fn(hash<string, T>) -> T"},
+		{`let fact = fn(n) { if (n < 2) { return 1 } n * fact(n - 1) }`, "fn(int) -> int"},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; let x = odd`, "fn(int) -> bool"},
+		{`let x = [1, 2].map(fn(x) { x > 1 })`, "array<bool>"},
+		{`let x = [1, 2].reduce("", fn(acc, x) { acc + "x" })`, "string"},
+		{`let x = "a b".split(" ")`, "array<string>"},
+		{`let x = {"a": 1}.keys()`, "array<string>"},
+		{`let up = fn(s) { s.upper() }`, "fn(string) -> string"},
+		{`let x = len("abc")`, "int"},
+		{`let n = fn(xs) { len(xs) }`, "fn(T) -> int"},
+		{`let x = items({"a": "b"})`, "array<array<string>>"},
+		{`let x = {1: 2}.items()`, "array<array<int>>"},
+		{`let x = push([], "a")`, "array<string>"},
+		{`let x = merge({1: 2}, {})`, "hash<int, int>"},
+		{`let x = try { 1 } catch (e) { e.code }`, "int"},
+		{`let x = json.parse("1")`, "T"},
+		{`let x = args`, "array<string>"},
+		{`let x = fn(a: int, b) -> string { b }`, "fn(int, string) -> string"},
+		{`let id: fn(T) -> T = fn(x) { x }`, "fn(T) -> T"},
+		{`let xs: array<int> = []`, "array<int>"},
+	}
+
+	for _, tt := range tests {
+		bindings, errs := check(t, tt.input)
+		require.Empty(t, errs, tt.input)
+		last := bindings[len(bindings)-1]
+		require.Equal(t, tt.want, last.Type.String(), tt.input)
+	}
+}
+
+func TestPolymorphism(t *testing.T) {
+	bindings, errs := check(t, `
+let id = fn(x) { x };
+let a = id(1);
+let b = id("b");
+let pair = fn(x) { [id(x), x] };
+`)
+	require.Empty(t, errs)
+	want := []string{"id: fn(T) -> T", "a: int", "b: string", "pair: fn(T) -> array<T>"}
+	var got []string
+	for _, b := range bindings {
+		got = append(got, b.Name+": "+b.Type.String())
+	}
+	require.Equal(t, want, got)
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{`"a" + 1`, []string{"1:5: type mismatch: string + int"}},
+		{`true + true`, []string{"1:6: unknown operator: bool + bool"}},
+		{`"a" - "b"`, []string{"1:5: unknown operator: string - string"}},
+		{`-"a"`, []string{"1:1: unknown operator: -string"}},
+		{`1 == "a"`, []string{"1:3: type mismatch: int == string"}},
+		{`[1, "a"]`, []string{"1:5: type mismatch: want int, got string"}},
+		{`{"a": 1, "b": "c"}`, []string{"1:15: type mismatch: want int, got string"}},
+		{`let x: int = "a"`, []string{"1:14: type mismatch: want int, got string"}},
+		{`let x: integer = 1`, []string{"1:8: unknown type integer"}},
+		{`let x: array<int, int> = []`, []string{"1:8: array takes 1 type arguments, got 2"}},
+		{`let f = fn(x: int) { x }; f("a")`, []string{"1:29: type mismatch: want int, got string"}},
+		{`let f = fn(x) -> string { x + 1 }`, []string{"1:27: type mismatch: want string, got int"}},
+		{`let f = fn(x, y) { x }; f(1)`, []string{"1:25: wrong number of arguments: want 2, got 1"}},
+		{`let x = 1; x(2)`, []string{"1:12: cannot call int"}},
+		{`1[0]`, []string{"1:2: index operator not supported: int"}},
+		{`"abc"[0:1]`, []string{"1:6: slice operator not supported: string"}},
+		{`[1][true]`, []string{"1:5: type mismatch: want int, got bool"}},
+		{`1.a`, []string{"1:2: member access not supported: int.a"}},
+		{`1.foo()`, []string{"1:3: unknown method: int.foo"}},
+		{`"a".push(1)`, []string{"1:5: unknown method: string.push"}},
+		{`[1].push("a")`, []string{"1:10: type mismatch: want int, got string"}},
+		{`if (true) { 1 } else { "a" }`, []string{"1:1: if branches have different types: int and string"}},
+		{`try { 1 } catch (e) { "a" }`, []string{"1:21: try and catch have different types: int and string"}},
+		{`let f = fn(x) { return 1; "a" }`, []string{"1:27: type mismatch: want int, got string"}},
+		{`let f = fn(x) { x(x) }`, []string{"1:17: infinite type: T = fn(T) -> U"}},
+		{`len(y)`, []string{"1:5: undefined: y"}},
+		{`len(1)`, []string{"1:5: type mismatch: want string or array, got int"}},
+		{`len(fn() { 1 })`, []string{"1:5: type mismatch: want string or array, got fn() -> int"}},
+		{`len("a", "b")`, []string{"1:1: wrong number of arguments: want 1, got 2"}},
+		{`items({"a": 1})`, []string{"1:7: type mismatch: want hash<string, string>, got hash<string, int>"}},
+		{`{"a": 1}.items()`, []string{"1:10: type mismatch: want string, got int"}},
+		{`let x: int = items({"a": "b"})[0][1]`, []string{"1:34: type mismatch: want int, got string"}},
+		{`exit(1, 2)`, []string{"1:1: wrong number of arguments: want at most 1, got 2"}},
+		{`assertEq(1, "a")`, []string{"1:10: type mismatch: want string, got int"}},
+		{`assertEq(1)`, []string{"1:1: wrong number of arguments: want 2 or 3, got 1"}},
+		{`let id = fn(x) { x }; id(1) + id("a")`, []string{"1:29: type mismatch: int + string"}},
+		{"let x = 1;\nlet y = x + \"a\";\nlet z = y - 1", []string{"2:11: type mismatch: int + string"}},
+	}
+
+	for _, tt := range tests {
+		_, errs := check(t, tt.input)
+		require.Equal(t, tt.want, errs, tt.input)
+	}
+}
+
+// TestBuiltinTypes checks the builtins typed by their annotation take the
+// number of arguments of their type.
+func TestBuiltinTypes(t *testing.T) {
+	for name, expr := range builtinExprs {
+		obj, _ := eval.LookupBuiltin(name)
+		b := obj.(*object.Builtin)
+		n := len(expr.(*ast.FunctionType).Params)
+		require.Equal(t, [2]int{n, n}, [2]int{b.MinArgs, b.MaxArgs}, name)
+	}
+	require.NotEmpty(t, builtinExprs)
+	require.True(t, variadics["puts"])
+}
+
+func TestExamples(t *testing.T) {
+	for _, file := range []string{"../examples/map_reduce.mnk", "../examples/lib/collections.mnk"} {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		bindings, errs := check(t, string(data))
+		require.Empty(t, errs, file)
+		if strings.HasSuffix(file, "collections.mnk") {
+			require.Equal(t, "fn(array<T>, U, fn(U, T) -> U) -> U", bindings[0].Type.String())
+			require.Equal(t, "fn(array<T>, fn(T) -> U) -> array<U>", bindings[1].Type.String())
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Package types is an optional static type checker for Monkey programs. It
+// infers types Hindley-Milner style, with let-polymorphism, and checks them
+// against the annotations of let bindings and function literals.
+//
+// The types are int, bool, string, array<T>, hash<K, V> and functions
+// fn(A, B) -> R. null has every type. Modules and caught errors are dynamic:
+// their members may have any type. In annotations, single uppercase letters
+// such as T are type variables.
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the type of a Monkey value.
+type Type interface {
+	String() string
+}
+
+// Con is a type constructor applied to its type arguments, e.g. int or
+// hash<string, int>.
+type Con struct {
+	Name string
+	Args []Type
+}
+
+// Func is the type of functions.
+type Func struct {
+	Params []Type
+	Return Type
+}
+
+// Var is a type variable. Unification binds it to a type.
+type Var struct {
+	id  int
+	ref Type
+	// level is the let nesting depth the variable was created at. Variables
+	// deeper than a let are generalized by it.
+	level int
+	// addable restricts the variable to types supporting +.
+	addable bool
+}
+
+var (
+	intType    = &Con{Name: "int"}
+	boolType   = &Con{Name: "bool"}
+	stringType = &Con{Name: "string"}
+	// moduleType and errorType are dynamic records.
+	moduleType = &Con{Name: "module"}
+	errorType  = &Con{Name: "error"}
+)
+
+func arrayOf(elem Type) *Con { return &Con{Name: "array", Args: []Type{elem}} }
+
+func hashOf(key, value Type) *Con { return &Con{Name: "hash", Args: []Type{key, value}} }
+
+// arity is the number of type arguments of the type constructors.
+var arity = map[string]int{"int": 0, "bool": 0, "string": 0, "array": 1, "hash": 2}
+
+// dynamic reports whether t is a type whose members have any type.
+func dynamic(t Type) bool {
+	c, ok := t.(*Con)
+	return ok && (c.Name == moduleType.Name || c.Name == errorType.Name)
+}
+
+// prune follows the bindings of type variables.
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.ref == nil {
+			return t
+		}
+		t = v.ref
+	}
+}
+
+func (c *Con) String() string  { return typeString(newNamer(), c) }
+func (f *Func) String() string { return typeString(newNamer(), f) }
+func (v *Var) String() string  { return typeString(newNamer(), v) }
+
+// namer names type variables T, U, V, ... in order of appearance, so that
+// types printed with the same namer agree on their names.
+type namer map[*Var]string
+
+func newNamer() namer { return namer{} }
+
+var varNames = "TUVWXYZABCDEFGHIJKLMNOPQRS"
+
+func (n namer) name(v *Var) string {
+	if name, ok := n[v]; ok {
+		return name
+	}
+	i := len(n)
+	name := string(varNames[i%len(varNames)])
+	if i >= len(varNames) {
+		name += fmt.Sprint(i / len(varNames))
+	}
+	n[v] = name
+	return name
+}
+
+func typeString(n namer, t Type) string {
+	switch t := prune(t).(type) {
+	case *Var:
+		return n.name(t)
+	case *Func:
+		params := make([]string, len(t.Params))
+		for i, p := range t.Params {
+			params[i] = typeString(n, p)
+		}
+		return "fn(" + strings.Join(params, ", ") + ") -> " + typeString(n, t.Return)
+	case *Con:
+		if len(t.Args) == 0 {
+			return t.Name
+		}
+		args := make([]string, len(t.Args))
+		for i, a := range t.Args {
+			args[i] = typeString(n, a)
+		}
+		return t.Name + "<" + strings.Join(args, ", ") + ">"
+	}
+	return "?"
+}
+
+// Scheme is a type generalized over its type variables Vars.
+type Scheme struct {
+	Vars []*Var
+	Type Type
+}
+
+func (s *Scheme) String() string { return typeString(newNamer(), s.Type) }
+
+// mono is the scheme of a type without type variables to instantiate.
+func mono(t Type) *Scheme { return &Scheme{Type: t} }
+
+// unifyError is a failure to unify two types.
+type unifyError struct {
+	want, got Type
+	// infinite is set by the occurs check.
+	infinite bool
+}
+
+func (e *unifyError) Error() string {
+	n := newNamer()
+	want, got := typeString(n, e.want), typeString(n, e.got)
+	if e.infinite {
+		return fmt.Sprintf("infinite type: %s = %s", want, got)
+	}
+	return fmt.Sprintf("type mismatch: want %s, got %s", want, got)
+}
+
+// unify makes want and got the same type by binding type variables.
+func unify(want, got Type) error {
+	want, got = prune(want), prune(got)
+	if v, ok := want.(*Var); ok {
+		return bind(v, got)
+	}
+	if v, ok := got.(*Var); ok {
+		if err := bind(v, want); err != nil {
+			err.(*unifyError).want, err.(*unifyError).got = want, got
+			return err
+		}
+		return nil
+	}
+	switch w := want.(type) {
+	case *Con:
+		g, ok := got.(*Con)
+		if !ok || g.Name != w.Name || len(g.Args) != len(w.Args) {
+			return &unifyError{want: want, got: got}
+		}
+		for i := range w.Args {
+			if err := unify(w.Args[i], g.Args[i]); err != nil {
+				return &unifyError{want: want, got: got}
+			}
+		}
+	case *Func:
+		g, ok := got.(*Func)
+		if !ok || len(g.Params) != len(w.Params) {
+			return &unifyError{want: want, got: got}
+		}
+		for i := range w.Params {
+			if err := unify(w.Params[i], g.Params[i]); err != nil {
+				return &unifyError{want: want, got: got}
+			}
+		}
+		if err := unify(w.Return, g.Return); err != nil {
+			return &unifyError{want: want, got: got}
+		}
+	}
+	return nil
+}
+
+// bind binds the unbound variable v to t.
+func bind(v *Var, t Type) error {
+	if t == Type(v) {
+		return nil
+	}
+	if occurs(v, t) {
+		return &unifyError{want: v, got: t, infinite: true}
+	}
+	if w, ok := t.(*Var); ok {
+		w.addable = w.addable || v.addable
+	} else if v.addable && !addable(t) {
+		return &unifyError{want: v, got: t}
+	}
+	v.ref = t
+	return nil
+}
+
+// occurs reports whether v occurs in t, lowering the level of the variables
+// of t to that of v on the way, as t now takes the place of v.
+func occurs(v *Var, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Var:
+		if t == v {
+			return true
+		}
+		t.level = min(t.level, v.level)
+	case *Con:
+		for _, a := range t.Args {
+			if occurs(v, a) {
+				return true
+			}
+		}
+	case *Func:
+		for _, p := range t.Params {
+			if occurs(v, p) {
+				return true
+			}
+		}
+		return occurs(v, t.Return)
+	}
+	return false
+}
+
+// addable reports whether values of type t support +.
+func addable(t Type) bool {
+	c, ok := prune(t).(*Con)
+	return ok && (c.Name == intType.Name || c.Name == stringType.Name)
+}
@@ -0,0 +1,230 @@
+// Package vet reports suspicious constructs in Monkey programs: undefined
+// identifiers, unused bindings, shadowed builtins, calls with the wrong
+// number of arguments, unreachable code and constant if conditions.
+package vet
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/EmilLaursen/wiig/ast"
+	"github.com/EmilLaursen/wiig/eval"
+	"github.com/EmilLaursen/wiig/object"
+	"github.com/EmilLaursen/wiig/parser"
+	"github.com/EmilLaursen/wiig/scope"
+	"github.com/EmilLaursen/wiig/token"
+)
+
+// Check is a check run by Program.
+type Check struct {
+	Name string
+	Doc  string
+}
+
+// Checks lists the available checks.
+var Checks = []Check{
+	{"undefined", "identifiers not bound by a let, parameter, import or builtin"},
+	{"unused", "let bindings and parameters never used; exports and names starting with _ are exempt"},
+	{"shadow", "bindings named like a builtin"},
+	{"argcount", "calls of known functions and builtins with the wrong number of arguments"},
+	{"unreachable", "statements following a return or throw"},
+	{"constcond", "if conditions that are constant"},
+}
+
+// predeclared are bound by `monkey run` besides the builtins.
+var predeclared = map[string]bool{"args": true}
+
+// Diagnostic is a problem found by a check. File is left for the caller to
+// fill in.
+type Diagnostic struct {
+	File    string         `json:"file,omitempty"`
+	Pos     token.Position `json:"-"`
+	Line    int            `json:"line"`
+	Column  int            `json:"column"`
+	Check   string         `json:"check"`
+	Message string         `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.File != "" {
+		return fmt.Sprintf("%s:%s: %s [%s]", d.File, d.Pos, d.Message, d.Check)
+	}
+	return fmt.Sprintf("%s: %s [%s]", d.Pos, d.Message, d.Check)
+}
+
+// Source parses src and runs the checks named, returning the diagnostics
+// sorted by position. Parse errors are reported by the check "syntax", and
+// stop the other checks.
+func Source(src string, checks []string) []Diagnostic {
+	p := parser.FromInput(src)
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		diags := make([]Diagnostic, len(errs))
+		for i, err := range errs {
+			diags[i] = newDiagnostic(err.Pos, "syntax", err.Msg)
+		}
+		return diags
+	}
+	return Program(program, checks)
+}
+
+// Program runs the checks named on program, returning the diagnostics
+// sorted by position.
+func Program(program *ast.Program, checks []string) []Diagnostic {
+	v := &vetter{enabled: map[string]bool{}, builtins: map[string]bool{}}
+	for _, c := range checks {
+		v.enabled[c] = true
+	}
+	for _, name := range eval.BuiltinNames() {
+		v.builtins[name] = true
+	}
+	v.run(program)
+
+	sort.SliceStable(v.diags, func(i, j int) bool {
+		a, b := v.diags[i].Pos, v.diags[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return v.diags
+}
+
+func newDiagnostic(pos token.Position, check, msg string) Diagnostic {
+	return Diagnostic{Pos: pos, Line: pos.Line, Column: pos.Column, Check: check, Message: msg}
+}
+
+type vetter struct {
+	enabled  map[string]bool
+	builtins map[string]bool
+	diags    []Diagnostic
+}
+
+func (v *vetter) report(pos token.Position, check, format string, args ...any) {
+	if v.enabled[check] {
+		v.diags = append(v.diags, newDiagnostic(pos, check, fmt.Sprintf(format, args...)))
+	}
+}
+
+func (v *vetter) run(program *ast.Program) {
+	info := scope.Resolve(program)
+	for _, u := range info.Uses {
+		if u.Binding == nil {
+			if !v.builtins[u.Ident.Value] && !predeclared[u.Ident.Value] {
+				v.report(u.Ident.Pos(), "undefined", "undefined: %s", u.Ident.Value)
+			}
+			continue
+		}
+		b := u.Binding
+		if u.Ident != b.Ident {
+			continue
+		}
+		if v.builtins[b.Ident.Value] {
+			v.report(b.Ident.Pos(), "shadow", "%s shadows the builtin %s", b.Ident.Value, b.Ident.Value)
+		}
+		// The error of a catch clause is often deliberately ignored.
+		if len(b.Refs) > 0 || b.Exported || b.Kind == scope.Catch || b.Ident.Value[0] == '_' {
+			continue
+		}
+		switch b.Kind {
+		case scope.Param:
+			v.report(b.Ident.Pos(), "unused", "parameter %s is unused", b.Ident.Value)
+		case scope.Let:
+			v.report(b.Ident.Pos(), "unused", "%s is declared and not used", b.Ident.Value)
+		}
+	}
+
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Program:
+			v.checkUnreachable(n.Statements)
+		case *ast.BlockStatement:
+			v.checkUnreachable(n.Statements)
+		case *ast.CallExpression:
+			v.checkCall(n, info)
+		case *ast.IfExpression:
+			if isConstant(n.Condition) {
+				v.report(n.Condition.Pos(), "constcond", "condition is constant")
+			}
+		}
+		return true
+	})
+}
+
+// checkCall checks the number of arguments of call, when calling a
+// function literal, a let bound to one, or a builtin.
+func (v *vetter) checkCall(call *ast.CallExpression, info *scope.Info) {
+	var b *scope.Binding
+	if ident, ok := call.Function.(*ast.Identifier); ok {
+		b = info.BindingOf(ident)
+	}
+	var name string
+	var arity [2]int
+	switch {
+	case b != nil && b.Kind == scope.Let:
+		fn, ok := b.Node.(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+		if !ok {
+			return
+		}
+		name, arity = b.Ident.Value, [2]int{len(fn.Params), len(fn.Params)}
+	case b == nil:
+		switch fn := call.Function.(type) {
+		case *ast.Identifier:
+			builtin, ok := eval.LookupBuiltin(fn.Value)
+			if !ok {
+				return
+			}
+			bf, ok := builtin.(*object.Builtin)
+			if !ok {
+				return
+			}
+			name, arity = fn.Value, [2]int{bf.MinArgs, bf.MaxArgs}
+		case *ast.FunctionLiteral:
+			name, arity = "function", [2]int{len(fn.Params), len(fn.Params)}
+		default:
+			return
+		}
+	default:
+		return
+	}
+
+	n := len(call.Arguments)
+	if n >= arity[0] && (arity[1] < 0 || n <= arity[1]) {
+		return
+	}
+	var want string
+	switch {
+	case arity[0] == arity[1]:
+		want = fmt.Sprint(arity[0])
+	case arity[1] < 0:
+		want = fmt.Sprintf("at least %d", arity[0])
+	default:
+		want = fmt.Sprintf("%d to %d", arity[0], arity[1])
+	}
+	v.report(call.Function.Pos(), "argcount", "%s called with %d arguments, want %s", name, n, want)
+}
+
+// checkUnreachable reports the first statement following a return or throw
+// among stmts.
+func (v *vetter) checkUnreachable(stmts []ast.Statement) {
+	for i, stmt := range stmts[:max(len(stmts)-1, 0)] {
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			v.report(stmts[i+1].Pos(), "unreachable", "unreachable code")
+			return
+		}
+	}
+}
+
+// isConstant reports whether the truthiness of e is known without running
+// the program. Arrays, hashes and functions are always truthy.
+func isConstant(e ast.Expression) bool {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean, *ast.StringLiteral, *ast.Null,
+		*ast.ArrayLiteral, *ast.HashLiteral, *ast.FunctionLiteral:
+		return true
+	case *ast.PrefixExpression:
+		return isConstant(e.Right)
+	case *ast.InfixExpression:
+		return isConstant(e.Left) && isConstant(e.Right)
+	default:
+		return false
+	}
+}
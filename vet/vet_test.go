@@ -0,0 +1,87 @@
+package vet
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func allChecks() []string {
+	var names []string
+	for _, c := range Checks {
+		names = append(names, c.Name)
+	}
+	return names
+}
+
+func TestChecks(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		// undefined
+		{`let f = fn() { g() }; let g = fn() { 1 }; f()`, nil},
+		{`puts(x); let x = 1; puts(x, args, json, len)`, []string{"1:6: undefined: x [undefined]"}},
+		{`let f = fn() { let a = b; let b = 1; a + b }; f()`, []string{"1:24: undefined: b [undefined]"}},
+		{`let x = x + 1`, []string{"1:5: x is declared and not used [unused]", "1:9: undefined: x [undefined]"}},
+		{`try { 1 } catch (e) { 2 }; e`, []string{"1:28: undefined: e [undefined]"}},
+		{`let h = {"a": 1}; h.a + h.b`, nil},
+		// unused
+		{`let f = fn(a, _b) { 1 }; f(1, 2)`, []string{"1:12: parameter a is unused [unused]"}},
+		{`let _x = 1; export let y = 2; let z = 3;`, []string{"1:35: z is declared and not used [unused]"}},
+		{`import "m.mnk" as m; try { 1 } catch (e) { 2 }`, nil},
+		// shadow
+		{`let len = fn(puts) { puts }; len(1); let json = 1; json`,
+			[]string{"1:5: len shadows the builtin len [shadow]", "1:14: puts shadows the builtin puts [shadow]", "1:42: json shadows the builtin json [shadow]"}},
+		// argcount
+		{`let f = fn(a, b) { a + b }; f(1); f(1, 2); f(1, 2, 3)`,
+			[]string{"1:29: f called with 1 arguments, want 2 [argcount]", "1:44: f called with 3 arguments, want 2 [argcount]"}},
+		{`len(); push([], 1); merge(); exit(1, 2); puts(); fn(x) { x }()`, []string{
+			"1:1: len called with 0 arguments, want 1 [argcount]",
+			"1:21: merge called with 0 arguments, want at least 1 [argcount]",
+			"1:30: exit called with 2 arguments, want 0 to 1 [argcount]",
+			"1:50: function called with 0 arguments, want 1 [argcount]",
+		}},
+		{`let len = fn() { 1 }; len(); let g = 1; g(1)`, []string{"1:5: len shadows the builtin len [shadow]"}},
+		// unreachable
+		{"let f = fn() {\n  return 1;\n  puts(2);\n  puts(3)\n}; f()", []string{"3:3: unreachable code [unreachable]"}},
+		{`let f = fn() { if (f) { throw "x"; 1 } else { return 2 } }; f()`, []string{"1:36: unreachable code [unreachable]"}},
+		// constcond
+		{`if (true) { 1 }; if (!(1 < 2)) { 1 }; if ([]) { 1 }; if (args) { 1 }; if (-1 + len(args)) { 1 }`, []string{
+			"1:5: condition is constant [constcond]",
+			"1:22: condition is constant [constcond]",
+			"1:43: condition is constant [constcond]",
+		}},
+		// syntax
+		{`let = 1`, []string{"1:5: expected next token to be IDENT, got = instead [syntax]", "1:5: no prefix parse function for = found [syntax]"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, d := range Source(tt.input, allChecks()) {
+			got = append(got, d.String())
+		}
+		require.Equal(t, tt.want, got, tt.input)
+	}
+}
+
+func TestDisabledChecks(t *testing.T) {
+	input := `let len = 1; if (true) { return x; 1 }`
+	require.Len(t, Source(input, allChecks()), 5)
+
+	diags := Source(input, []string{"undefined", "unreachable"})
+	require.Equal(t, []Diagnostic{
+		{Pos: diags[0].Pos, Line: 1, Column: 33, Check: "undefined", Message: "undefined: x"},
+		{Pos: diags[1].Pos, Line: 1, Column: 36, Check: "unreachable", Message: "unreachable code"},
+	}, diags)
+	require.Empty(t, Source(input, nil))
+}
+
+func TestExamples(t *testing.T) {
+	for _, file := range []string{"../examples/map_reduce.mnk", "../examples/lib/collections.mnk"} {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.Empty(t, Source(string(data), allChecks()), file)
+	}
+}